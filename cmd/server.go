@@ -1,19 +1,34 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/leonideliseev/ozonTestTask/graph"
+	"github.com/leonideliseev/ozonTestTask/pkg/auth"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	"github.com/leonideliseev/ozonTestTask/pkg/moderation"
+	"github.com/leonideliseev/ozonTestTask/pkg/outbox"
+	"github.com/leonideliseev/ozonTestTask/pkg/ratelimit"
+	"github.com/leonideliseev/ozonTestTask/pkg/retention"
 	"github.com/leonideliseev/ozonTestTask/pkg/storage"
+	"github.com/leonideliseev/ozonTestTask/pkg/webhook"
 
 	"github.com/joho/godotenv"
 	"github.com/leonideliseev/ozonTestTask/pkg/storage/in_memory"
 	"github.com/leonideliseev/ozonTestTask/pkg/storage/postgresql"
+	"github.com/leonideliseev/ozonTestTask/pkg/storage/sqlite"
 	"github.com/sirupsen/logrus"
 
 	"time"
@@ -36,14 +51,34 @@ func main() {
 		fmt.Println(".env file does not exist")
 	}
 
+	// проверка токенов пользователей запросов
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		logrus.Fatalf("failed init auth: %s", err.Error())
+	}
+
+	// выдача токена существующему пользователю, например модератору:
+	// server token <глобальный id пользователя>. Токен печатается последней строкой
+	if len(os.Args) == 3 && os.Args[1] == "token" {
+		if _, ok := os.LookupEnv("AUTH_SECRET"); !ok {
+			logrus.Fatalf("need to set AUTH_SECRET to issue tokens")
+		}
+		id, err := globalid.DecodeAs(os.Args[2], globalid.User)
+		if err != nil {
+			logrus.Fatalf("failed parse user id: %s", err.Error())
+		}
+		fmt.Println(authenticator.Issue(id))
+		return
+	}
+
 	// получение переменных окружения
 	PORT := getEnv("APP_PORT", "8080")
 	HOST_PORT := getEnv("HOST_PORT", "8080")
 	dbStore := getEnv("DB_STORE", "false")
 
 	var store storage.Storage
-	var err error
-	if dbStore == "true" { // подключение к бд
+	switch dbStore {
+	case "true": // подключение к бд
 		connectionString := getEnv("DATABASE_URL", "")
 		if connectionString == "" {
 			logrus.Fatalf("need to set DATABASE_URL in environment")
@@ -53,16 +88,120 @@ func main() {
 		if err != nil {
 			logrus.Fatalf("failed init db: %s", err.Error())
 		}
-	} else { // in-memory
-		store = memory.NewInMemoryStore()
+	case "sqlite": // встроенная бд в файле
+		store, err = sqlite.NewSQLiteStore(getEnv("SQLITE_PATH", "ozon.db"))
+		if err != nil {
+			logrus.Fatalf("failed init sqlite: %s", err.Error())
+		}
+	default: // in-memory
+		store, err = newMemoryStore()
+		if err != nil {
+			logrus.Fatalf("failed init in-memory storage: %s", err.Error())
+		}
+	}
+	// хранилище, которое нужно закрыть при остановке
+	closer, _ := store.(io.Closer)
+	// хранилище, которое пишет события в outbox в транзакции изменения
+	outboxStore, _ := store.(outbox.Store)
+
+	// таймауты операций хранилища: общий и для отдельных методов
+	timeouts, err := storage.ParseTimeouts(getEnv("STORAGE_TIMEOUT", "5s"), getEnv("STORAGE_TIMEOUTS", ""))
+	if err != nil {
+		logrus.Fatalf("failed parse storage timeouts: %s", err.Error())
+	}
+	store = storage.WithTimeouts(store, timeouts)
+
+	// реакции, которые можно ставить на посты и комментарии
+	reactions, err := graph.ParseReactions(getEnv("REACTIONS", graph.DefaultReactions))
+	if err != nil {
+		logrus.Fatalf("failed parse reactions: %s", err.Error())
+	}
+
+	// этапы модерации постов и комментариев и запрещённые слова
+	pipeline, err := moderation.Parse(getEnv("MODERATION", graph.DefaultModeration), strings.Split(getEnv("BANNED_WORDS", ""), ","))
+	if err != nil {
+		logrus.Fatalf("failed parse moderation: %s", err.Error())
+	}
+
+	// глобальные id модераторов через запятую
+	moderators, err := graph.ParseModerators(getEnv("MODERATOR_IDS", ""))
+	if err != nil {
+		logrus.Fatalf("failed parse moderators: %s", err.Error())
+	}
+
+	// ограничения частоты мутаций вида "createComment:user=5/1m,createComment:ip=30/1m"
+	limits, err := ratelimit.ParseLimits(getEnv("RATE_LIMITS", graph.DefaultRateLimits))
+	if err != nil {
+		logrus.Fatalf("failed parse rate limits: %s", err.Error())
 	}
 
-	newResolver := graph.NewResolver(store)
+	// сколько хранятся ключи идемпотентности мутаций создания
+	idempotencyTTL, err := time.ParseDuration(getEnv("IDEMPOTENCY_TTL", graph.DefaultIdempotencyTTL.String()))
+	if err != nil {
+		logrus.Fatalf("failed parse idempotency ttl: %s", err.Error())
+	}
+	if idempotencyTTL <= 0 {
+		logrus.Fatalf("idempotency ttl must be positive, got %s", idempotencyTTL)
+	}
+
+	// отправка событий вебхукам в фоне до остановки приложения
+	webhookConfig, err := newWebhookConfig()
+	if err != nil {
+		logrus.Fatalf("failed parse webhook config: %s", err.Error())
+	}
+	dispatcher := webhook.NewDispatcher(store, webhookConfig)
+	dispatcherCtx, stopDispatcher := context.WithCancel(context.Background())
+	dispatcherDone := make(chan struct{})
+	go func() {
+		dispatcher.Run(dispatcherCtx)
+		close(dispatcherDone)
+	}()
+
+	// рассылка событий outbox подписчикам и вебхукам в фоне
+	var relay *outbox.Relay
+	relayDone := make(chan struct{})
+	if outboxStore != nil {
+		relayConfig := outbox.DefaultConfig()
+		relayConfig.Retention, err = time.ParseDuration(getEnv("OUTBOX_RETENTION", relayConfig.Retention.String()))
+		if err != nil {
+			logrus.Fatalf("failed parse outbox retention: %s", err.Error())
+		}
+		relay = outbox.NewRelay(outboxStore, relayConfig)
+	} else {
+		close(relayDone)
+	}
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+
+	// очистка удалённых записей старше окна хранения в фоне
+	retentionConfig, err := newRetentionConfig()
+	if err != nil {
+		logrus.Fatalf("failed parse retention config: %s", err.Error())
+	}
+	purger := retention.NewPurger(store, retentionConfig)
+	purgerCtx, stopPurger := context.WithCancel(context.Background())
+	purgerDone := make(chan struct{})
+	go func() {
+		purger.Run(purgerCtx)
+		close(purgerDone)
+	}()
+
+	newResolver := graph.NewResolver(store, reactions, pipeline, moderators, idempotencyTTL, dispatcher, relay, retentionConfig.Window, authenticator)
+	// потребители добавлены в NewResolver
+	if relay != nil {
+		go func() {
+			relay.Run(relayCtx)
+			close(relayDone)
+		}()
+	}
 	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: newResolver}))
+	srv.AroundFields(graph.RateLimit(ratelimit.NewMemoryLimiter(), limits))
+	srv.SetErrorPresenter(graph.ErrorPresenter)
 
 	srv.AddTransport(transport.POST{})
     srv.AddTransport(transport.Websocket{
         KeepAlivePingInterval: 10 * time.Second,
+        // пользователь подписки из токена в параметрах connection_init
+        InitFunc: authenticator.WebsocketInit,
         Upgrader: websocket.Upgrader{
             CheckOrigin: func(r *http.Request) bool {
                 return true
@@ -74,13 +213,167 @@ func main() {
 	c := cors.New(cors.Options{
         AllowedOrigins:   []string{"http://localhost:" + HOST_PORT},
         AllowCredentials: true,
+        // стандартные заголовки и токен пользователя запроса
+        AllowedHeaders:   []string{"Accept", "Content-Type", "X-Requested-With", auth.Header},
     })
 	
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", c.Handler(srv))
+	http.Handle("/query", c.Handler(ratelimit.Middleware(authenticator.Middleware(srv))))
+
+	server := &http.Server{Addr: ":" + PORT}
+	go func() {
+		log.Printf("connect to http://localhost:%s/ for GraphQL playground", HOST_PORT)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// остановка по сигналу: сначала дорабатывают начатые запросы,
+	// затем хранилище сохраняет данные и закрывается
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logrus.Errorf("failed shutdown server: %s", err.Error())
+	}
+
+	// необработанные события outbox и неотправленные доставки остаются
+	// в хранилище и обработаются после запуска
+	stopRelay()
+	<-relayDone
+	stopDispatcher()
+	<-dispatcherDone
+	stopPurger()
+	<-purgerDone
+
+	if closer != nil {
+		if err := closer.Close(); err != nil {
+			logrus.Errorf("failed close storage: %s", err.Error())
+		}
+	}
+}
+
+// in-memory хранилище, с сохранением на диск если задан MEMORY_DATA_DIR
+func newMemoryStore() (*memory.MemoryStorage, error) {
+	dir := getEnv("MEMORY_DATA_DIR", "")
+	if dir == "" {
+		return memory.NewInMemoryStore()
+	}
+
+	fsyncInterval, err := time.ParseDuration(getEnv("MEMORY_FSYNC_INTERVAL", "1s"))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotInterval, err := time.ParseDuration(getEnv("MEMORY_SNAPSHOT_INTERVAL", "5m"))
+	if err != nil {
+		return nil, err
+	}
+
+	fsync := memory.FsyncPolicy(getEnv("MEMORY_FSYNC", string(memory.FsyncInterval)))
+	switch fsync {
+	case memory.FsyncAlways, memory.FsyncInterval, memory.FsyncNever:
+	default:
+		return nil, fmt.Errorf("unknown MEMORY_FSYNC %q", fsync)
+	}
+
+	return memory.NewInMemoryStore(memory.WithPersistence(memory.Persistence{
+		Dir:              dir,
+		Fsync:            fsync,
+		FsyncInterval:    fsyncInterval,
+		SnapshotInterval: snapshotInterval,
+	}))
+}
+
+// настройки отправки вебхуков, по умолчанию webhook.DefaultConfig
+func newWebhookConfig() (webhook.Config, error) {
+	cfg := webhook.DefaultConfig()
+
+	maxAttempts, err := strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", strconv.Itoa(cfg.MaxAttempts)))
+	if err != nil {
+		return cfg, err
+	}
+	if maxAttempts < 1 {
+		return cfg, fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be positive, got %d", maxAttempts)
+	}
+	cfg.MaxAttempts = maxAttempts
+
+	durations := []struct {
+		key   string
+		value *time.Duration
+	}{
+		{"WEBHOOK_RETRY_DELAY", &cfg.BaseDelay},
+		{"WEBHOOK_MAX_RETRY_DELAY", &cfg.MaxDelay},
+		{"WEBHOOK_TIMEOUT", &cfg.Timeout},
+	}
+	for _, d := range durations {
+		value, err := time.ParseDuration(getEnv(d.key, d.value.String()))
+		if err != nil {
+			return cfg, err
+		}
+		if value <= 0 {
+			return cfg, fmt.Errorf("%s must be positive, got %s", d.key, value)
+		}
+		*d.value = value
+	}
+
+	allowPrivate, err := strconv.ParseBool(getEnv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", strconv.FormatBool(cfg.AllowPrivateNetworks)))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.AllowPrivateNetworks = allowPrivate
+
+	return cfg, nil
+}
+
+// проверка токенов пользователей. Без AUTH_SECRET секрет выбирается случайно,
+// и выданные токены перестают действовать после перезапуска
+func newAuthenticator() (*auth.Authenticator, error) {
+	ttl, err := time.ParseDuration(getEnv("AUTH_TOKEN_TTL", auth.DefaultTokenTTL.String()))
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("AUTH_TOKEN_TTL must be positive, got %s", ttl)
+	}
+
+	secret := []byte(getEnv("AUTH_SECRET", ""))
+	if len(secret) == 0 {
+		logrus.Warn("AUTH_SECRET is not set, tokens will stop working after restart")
+		if secret, err = auth.RandomSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	return auth.NewAuthenticator(secret, ttl), nil
+}
+
+// настройки очистки удалённых записей, по умолчанию retention.DefaultConfig
+func newRetentionConfig() (retention.Config, error) {
+	cfg := retention.DefaultConfig()
+
+	durations := []struct {
+		key   string
+		value *time.Duration
+	}{
+		{"DELETED_RETENTION", &cfg.Window},
+		{"DELETED_PURGE_INTERVAL", &cfg.Interval},
+	}
+	for _, d := range durations {
+		value, err := time.ParseDuration(getEnv(d.key, d.value.String()))
+		if err != nil {
+			return cfg, err
+		}
+		if value <= 0 {
+			return cfg, fmt.Errorf("%s must be positive, got %s", d.key, value)
+		}
+		*d.value = value
+	}
 
-	log.Printf("connect to http://localhost:%s/ for GraphQL playground", HOST_PORT)
-	log.Fatal(http.ListenAndServe(":"+PORT, nil))
+	return cfg, nil
 }
 
 // получение значения из окружения
@@ -0,0 +1,170 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/auth"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// сколько постов и комментариев читается из хранилища за раз при выгрузке
+const exportPageSize = 100
+
+// Выгрузка данных и удаление аккаунта необратимо раскрывают или стирают
+// данные, поэтому выполняются только для пользователя, чей подписанный токен
+// проверил auth.Authenticator. Заголовки с id пользователя не учитываются
+var errNoAccount = errors.New("account data is available only to the authenticated user, pass their token in " + auth.Header)
+
+// архив данных пользователя, id - глобальные
+type userExport struct {
+	ExportedAt time.Time      `json:"exportedAt"`
+	User       exportedUser   `json:"user"`
+	Posts      []exportedPost `json:"posts"`
+	Comments   []exportedComm `json:"comments"`
+}
+
+type exportedUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	// когда аккаунт удалён
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type exportedPost struct {
+	ID              string     `json:"id"`
+	BoardID         *string    `json:"boardId,omitempty"`
+	Title           string     `json:"title"`
+	Content         string     `json:"content"`
+	Tags            []string   `json:"tags"`
+	CommentsEnabled bool       `json:"commentsEnabled"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	EditedAt        *time.Time `json:"editedAt,omitempty"`
+	DeletedAt       *time.Time `json:"deletedAt,omitempty"`
+}
+
+type exportedComm struct {
+	ID              string     `json:"id"`
+	PostID          string     `json:"postId"`
+	ParentCommentID *string    `json:"parentCommentId,omitempty"`
+	Content         string     `json:"content"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	EditedAt        *time.Time `json:"editedAt,omitempty"`
+	DeletedAt       *time.Time `json:"deletedAt,omitempty"`
+}
+
+// exportMyData возвращает JSON-архив профиля, постов и комментариев
+// пользователя запроса, сначала новые записи
+func (r *Resolver) exportMyData(ctx context.Context) (string, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return "", errNoAccount
+	}
+
+	user, err := r.storage.GetUser(ctx, viewer)
+	if err != nil {
+		return "", err
+	}
+
+	export := userExport{
+		ExportedAt: time.Now(),
+		User: exportedUser{
+			ID:        globalid.Encode(globalid.User, user.ID),
+			Username:  user.Username,
+			DeletedAt: user.AnonymizedAt,
+		},
+		Posts:    []exportedPost{},
+		Comments: []exportedComm{},
+	}
+
+	for {
+		page, err := r.storage.GetUserPosts(ctx, exportPageSize, len(export.Posts), viewer, true)
+		if err != nil {
+			return "", err
+		}
+		for _, post := range page.Posts {
+			export.Posts = append(export.Posts, exportPost(post))
+		}
+		if len(page.Posts) == 0 || len(export.Posts) >= page.TotalCount {
+			break
+		}
+	}
+
+	for {
+		page, err := r.storage.GetUserComments(ctx, exportPageSize, len(export.Comments), viewer, true)
+		if err != nil {
+			return "", err
+		}
+		for _, comm := range page.Comms {
+			export.Comments = append(export.Comments, exportComment(comm))
+		}
+		if len(page.Comms) == 0 || len(export.Comments) >= page.TotalCount {
+			break
+		}
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func exportPost(post *smodel.Post) exportedPost {
+	// глобальные id и теги берутся из представления для GraphQL
+	gql := post.ToGraphQL()
+
+	return exportedPost{
+		ID:              gql.ID,
+		BoardID:         gql.BoardID,
+		Title:           post.Title,
+		Content:         post.Content,
+		Tags:            gql.Tags,
+		CommentsEnabled: post.CommentsEnabled,
+		Status:          string(gql.Status),
+		CreatedAt:       post.CreatedAt,
+		EditedAt:        post.EditedAt,
+		DeletedAt:       post.RemovedAt,
+	}
+}
+
+func exportComment(comm *smodel.Comment) exportedComm {
+	gql := comm.ToGraphQL()
+
+	return exportedComm{
+		ID:              gql.ID,
+		PostID:          gql.PostID,
+		ParentCommentID: gql.ParentCommentID,
+		Content:         comm.Content,
+		Status:          string(gql.Status),
+		CreatedAt:       comm.CreatedAt,
+		EditedAt:        comm.EditedAt,
+		DeletedAt:       comm.RemovedAt,
+	}
+}
+
+// deleteMyAccount удаляет аккаунт пользователя запроса, его записи
+// остаются или очищаются в зависимости от mode
+func (r *Resolver) deleteMyAccount(ctx context.Context, mode model.DeleteAccountMode) (*model.User, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return nil, errNoAccount
+	}
+
+	user, err := r.storage.AnonymizeUser(ctx, smodel.AnonymizeUser{
+		UserId:        viewer,
+		RemoveContent: mode == model.DeleteAccountModeRemoveContent,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user.ToGraphQL(), nil
+}
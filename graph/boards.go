@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// максимальная длина комментария в символах, доска может ограничить её сильнее
+const maxCommentLength = 2000
+
+// getPosts возвращает страницу постов для getPosts и постов доски
+func (r *Resolver) getPosts(ctx context.Context, limit, offset *int, sort *model.PostSort, tags []string, match *model.TagMatch, boardId *uint) (*model.PostPage, error) {
+	lim, off := setLimOff(limit, offset)
+
+	// значения enum совпадают со значениями smodel.PostSort
+	var postSort smodel.PostSort
+	if sort != nil {
+		postSort = smodel.PostSort(*sort)
+	}
+
+	filterTags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	filter := smodel.PostFilter{
+		BoardId: boardId,
+		Tags:    filterTags,
+		AllTags: match != nil && *match == model.TagMatchAll,
+	}
+	// удалённые посты видят только модераторы
+	if _, ok := r.moderator(ctx); ok {
+		filter.WithDeleted = true
+	}
+
+	badPostPage, err := r.storage.GetPosts(ctx, lim, off, postSort, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]*model.Post, 0, len(badPostPage.Posts))
+	for _, dirtyPost := range badPostPage.Posts {
+		posts = append(posts, dirtyPost.ToGraphQL())
+	}
+
+	return &model.PostPage{
+		Posts:      posts,
+		TotalCount: badPostPage.TotalCount,
+	}, nil
+}
+
+// newBoard проверяет настройки доски и переводит глобальные id пользователей
+func newBoard(input model.CreateBoardInput) (smodel.CreateBoard, error) {
+	if input.MaxCommentLength < 0 || input.MaxCommentLength > maxCommentLength {
+		return smodel.CreateBoard{}, fmt.Errorf("max comment length must be from 0 to %d, got %d", maxCommentLength, input.MaxCommentLength)
+	}
+
+	posters := make([]uint, 0, len(input.AllowedPosterIds))
+	seen := make(map[uint]bool, len(input.AllowedPosterIds))
+	for _, id := range input.AllowedPosterIds {
+		uid, err := globalid.DecodeAs(id, globalid.User)
+		if err != nil {
+			return smodel.CreateBoard{}, err
+		}
+
+		if !seen[uid] {
+			seen[uid] = true
+			posters = append(posters, uid)
+		}
+	}
+
+	return smodel.CreateBoard{
+		Name:                     input.Name,
+		Description:              input.Description,
+		CommentsEnabledByDefault: input.CommentsEnabledByDefault,
+		MaxCommentLength:         input.MaxCommentLength,
+		AllowedPosters:           posters,
+	}, nil
+}
+
+// commentsEnabled - включены ли комментарии у нового поста: явно указанное
+// значение, настройка доски или true для поста без доски
+func (r *Resolver) commentsEnabled(ctx context.Context, enabled *bool, boardId *uint) (bool, error) {
+	if enabled != nil {
+		return *enabled, nil
+	}
+	if boardId == nil {
+		return true, nil
+	}
+
+	board, err := r.storage.GetBoard(ctx, *boardId)
+	if err != nil {
+		return false, err
+	}
+
+	return board.CommentsEnabledByDefault, nil
+}
@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Курсоры страниц (first, after) непрозрачны для клиента,
+// внутри - номер записи в выдаче, base64("cursor:12")
+
+const cursorPrefix = "cursor:"
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// pageAfter возвращает limit и offset страницы из first записей после курсора after
+func pageAfter(first *int, after *string) (int, int, error) {
+	limit, _ := setLimOff(first, nil)
+	if limit < 0 {
+		return 0, 0, fmt.Errorf("first must be non-negative, got %d", limit)
+	}
+
+	if after == nil {
+		return limit, 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(*after)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, 0, fmt.Errorf("invalid cursor %q", *after)
+	}
+
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid cursor %q", *after)
+	}
+
+	return limit, offset + 1, nil
+}
@@ -0,0 +1,114 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/auth"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Удалённые автором или модератором записи видят только модераторы.
+// Остальным удалённый пост и корневой комментарий getComments не выдаются,
+// а удалённые ответы остаются в дереве заглушками, чтобы не терялись ответы на них
+
+// заглушка вместо текста записи, удалённой автором
+const removedPlaceholder = "[deleted]"
+
+var errNotDeleter = errors.New("only the author or a moderator can delete, pass their token in " + auth.Header)
+
+// deleteTarget помечает удалённой запись с глобальным id типа typ
+func (r *Resolver) deleteTarget(ctx context.Context, id string, typ string) (bool, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return false, errNotDeleter
+	}
+
+	target, err := decodeTarget(id, typ)
+	if err != nil {
+		return false, err
+	}
+
+	err = r.storage.SoftDelete(ctx, smodel.SoftDelete{
+		Target:    target,
+		UserId:    viewer,
+		Moderator: r.moderators[viewer],
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// restoreTarget снимает пометку об удалении с записи, удалённой
+// не раньше окна хранения
+func (r *Resolver) restoreTarget(ctx context.Context, id string, typ string) (bool, error) {
+	if _, ok := r.moderator(ctx); !ok {
+		return false, errNotModerator
+	}
+
+	target, err := decodeTarget(id, typ)
+	if err != nil {
+		return false, err
+	}
+
+	err = r.storage.Restore(ctx, smodel.Restore{
+		Target:       target,
+		DeletedAfter: time.Now().Add(-r.retention),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func decodeTarget(id string, typ string) (smodel.Target, error) {
+	sid, err := globalid.DecodeAs(id, typ)
+	if err != nil {
+		return smodel.Target{}, err
+	}
+
+	if typ == globalid.Post {
+		return smodel.Target{Type: smodel.TargetPost, ID: sid}, nil
+	}
+	return smodel.Target{Type: smodel.TargetComment, ID: sid}, nil
+}
+
+// checkRemoved возвращает ошибку "не найдено", если запись удалена,
+// а пользователь запроса не модератор
+func (r *Resolver) checkRemoved(ctx context.Context, target smodel.Target, removedAt *time.Time) error {
+	if removedAt == nil {
+		return nil
+	}
+	if _, ok := r.moderator(ctx); ok {
+		return nil
+	}
+
+	if target.Type == smodel.TargetPost {
+		return errors.New(u.ErrorPostId(target.ID))
+	}
+	return errors.New(u.ErrorCommId(target.ID))
+}
+
+// checkCommentRemoved как checkRemoved, но комментарий под удалённым постом
+// тоже считается удалённым
+func (r *Resolver) checkCommentRemoved(ctx context.Context, comm *smodel.Comment) error {
+	target := smodel.Target{Type: smodel.TargetComment, ID: comm.ID}
+	if err := r.checkRemoved(ctx, target, comm.RemovedAt); err != nil {
+		return err
+	}
+	if _, ok := r.moderator(ctx); ok {
+		return nil
+	}
+
+	post, err := r.storage.GetPost(ctx, 0, 0, smodel.CommentSortDefault, comm.PostID)
+	if err != nil {
+		return err
+	}
+	return r.checkRemoved(ctx, target, post.RemovedAt)
+}
@@ -0,0 +1,89 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/outbox"
+)
+
+// События о новых постах и комментариях получают подписчики и вебхуки.
+// Если хранилище пишет события в outbox (r.relay != nil), то их рассылает
+// relay: событие не теряется при падении процесса после записи. Иначе
+// события рассылаются сразу после записи в хранилище
+
+// потребители outbox
+const (
+	consumerSubscriptions = "subscriptions"
+	consumerWebhooks      = "webhooks"
+)
+
+// addConsumers подписывает рассылку на события outbox.
+// Подписки живут в памяти процесса, поэтому события до запуска им не нужны,
+// а вебхукам передаются все события, доставки которых ещё не созданы
+func (r *Resolver) addConsumers(relay *outbox.Relay) {
+	relay.Add(outbox.Consumer{Name: consumerSubscriptions, Handle: r.notifyEvent})
+	if r.webhooks != nil {
+		relay.Add(outbox.Consumer{Name: consumerWebhooks, Durable: true, Handle: r.publishEvent})
+	}
+}
+
+func (r *Resolver) postCreated(ctx context.Context, post *smodel.Post) {
+	if r.relay != nil {
+		r.relay.Wake()
+		return
+	}
+
+	r.publish(ctx, smodel.WebhookPostCreated, post.UserID, post.ToGraphQL())
+}
+
+func (r *Resolver) commentCreated(ctx context.Context, comm *smodel.Comment) {
+	if r.relay != nil {
+		r.relay.Wake()
+		return
+	}
+
+	// уведомление подписчиков о новом комментарии под постом
+	r.NotifySubscribers(comm.PostID, comm.ToGraphQL())
+	// уведомления упомянутым пользователям и автору родительского комментария
+	r.NotifyNotificationSubscribers(comm.Notifications)
+	r.publish(ctx, smodel.WebhookCommentCreated, comm.UserID, comm.ToGraphQL())
+}
+
+// notifyEvent передаёт событие outbox подписчикам
+func (r *Resolver) notifyEvent(ctx context.Context, event *smodel.OutboxEvent) error {
+	if event.Type != smodel.EventCommentCreated {
+		return nil
+	}
+
+	var data smodel.CommentCreated
+	if err := json.Unmarshal([]byte(event.Payload), &data); err != nil {
+		return err
+	}
+
+	r.NotifySubscribers(data.Comment.PostID, data.Comment.ToGraphQL())
+	r.NotifyNotificationSubscribers(data.Notifications)
+	return nil
+}
+
+// publishEvent создаёт доставки события outbox вебхукам.
+// Повтор события после сбоя новых доставок не создаёт
+func (r *Resolver) publishEvent(ctx context.Context, event *smodel.OutboxEvent) error {
+	switch event.Type {
+	case smodel.EventPostCreated:
+		var post smodel.Post
+		if err := json.Unmarshal([]byte(event.Payload), &post); err != nil {
+			return err
+		}
+		return r.webhooks.PublishOnce(ctx, event.ID, smodel.WebhookPostCreated, post.UserID, post.ToGraphQL())
+	case smodel.EventCommentCreated:
+		var data smodel.CommentCreated
+		if err := json.Unmarshal([]byte(event.Payload), &data); err != nil {
+			return err
+		}
+		return r.webhooks.PublishOnce(ctx, event.ID, smodel.WebhookCommentCreated, data.Comment.UserID, data.Comment.ToGraphQL())
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// DefaultIdempotencyTTL - сколько хранятся ключи идемпотентности по умолчанию
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// максимальная длина ключа идемпотентности
+const maxIdempotencyKeyLength = 255
+
+// idempotency возвращает ключ идемпотентности мутации op, nil - ключа нет.
+// request - данные для хранилища без ключа, по их хэшу повтор
+// с другими данными отличается от настоящего повтора
+func (r *Resolver) idempotency(key *string, op string, request interface{}) (*smodel.Idempotency, error) {
+	if key == nil {
+		return nil, nil
+	}
+	if *key == "" || len(*key) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("idempotency key must be from 1 to %d bytes, got %d", maxIdempotencyKeyLength, len(*key))
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(append([]byte(op+"\n"), data...))
+
+	return &smodel.Idempotency{
+		Key:  *key,
+		Hash: hex.EncodeToString(hash[:]),
+		TTL:  r.idempotencyTTL,
+	}, nil
+}
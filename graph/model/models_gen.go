@@ -2,19 +2,78 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type Node interface {
+	IsNode()
+	GetID() string
+}
+
+type SearchResult interface {
+	IsSearchResult()
+}
+
+type Board struct {
+	ID                       string    `json:"id"`
+	Name                     string    `json:"name"`
+	Description              string    `json:"description"`
+	CommentsEnabledByDefault bool      `json:"commentsEnabledByDefault"`
+	MaxCommentLength         int       `json:"maxCommentLength"`
+	AllowedPosters           []*User   `json:"allowedPosters"`
+	CreatedAt                time.Time `json:"createdAt"`
+	Posts                    *PostPage `json:"posts"`
+}
+
+func (Board) IsNode()            {}
+func (this Board) GetID() string { return this.ID }
+
+type BoardPage struct {
+	Boards     []*Board `json:"boards"`
+	TotalCount int      `json:"totalCount"`
+}
+
 type CommPage struct {
 	Comments   []*Comment `json:"comments"`
 	TotalCount int        `json:"totalCount"`
 }
 
 type Comment struct {
-	ID              string    `json:"id"`
-	PostID          string    `json:"postId"`
-	UserID          string    `json:"userId"`
-	Author          *User     `json:"author"`
-	Content         string    `json:"content"`
-	ParentCommentID *string   `json:"parentCommentId,omitempty"`
-	ReplyPage       *CommPage `json:"replyPage"`
+	ID              string              `json:"id"`
+	PostID          string              `json:"postId"`
+	UserID          string              `json:"userId"`
+	Author          *User               `json:"author"`
+	Content         string              `json:"content"`
+	ParentCommentID *string             `json:"parentCommentId,omitempty"`
+	CreatedAt       time.Time           `json:"createdAt"`
+	Score           int                 `json:"score"`
+	Upvotes         int                 `json:"upvotes"`
+	Downvotes       int                 `json:"downvotes"`
+	ViewerVote      VoteValue           `json:"viewerVote"`
+	Reactions       []*ReactionCount    `json:"reactions"`
+	ViewerReactions []string            `json:"viewerReactions"`
+	Status          ContentStatus       `json:"status"`
+	EditedAt        *time.Time          `json:"editedAt,omitempty"`
+	DeletedAt       *time.Time          `json:"deletedAt,omitempty"`
+	Revisions       *RevisionConnection `json:"revisions"`
+	ReplyPage       *CommPage           `json:"replyPage"`
+}
+
+func (Comment) IsNode()            {}
+func (this Comment) GetID() string { return this.ID }
+
+func (Comment) IsSearchResult() {}
+
+type CreateBoardInput struct {
+	Name                     string   `json:"name"`
+	Description              string   `json:"description"`
+	CommentsEnabledByDefault bool     `json:"commentsEnabledByDefault"`
+	MaxCommentLength         int      `json:"maxCommentLength"`
+	AllowedPosterIds         []string `json:"allowedPosterIds,omitempty"`
 }
 
 type CreateCommentInput struct {
@@ -22,28 +81,88 @@ type CreateCommentInput struct {
 	PostID          string  `json:"postId"`
 	Content         string  `json:"content"`
 	ParentCommentID *string `json:"parentCommentId,omitempty"`
+	IdempotencyKey  *string `json:"idempotencyKey,omitempty"`
 }
 
 type CreatePostInput struct {
-	UserID          string `json:"userId"`
-	Title           string `json:"title"`
-	Content         string `json:"content"`
-	CommentsEnabled bool   `json:"commentsEnabled"`
+	UserID          string   `json:"userId"`
+	Title           string   `json:"title"`
+	Content         string   `json:"content"`
+	CommentsEnabled *bool    `json:"commentsEnabled,omitempty"`
+	BoardID         *string  `json:"boardId,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	SlowModeSeconds int      `json:"slowModeSeconds"`
+	IdempotencyKey  *string  `json:"idempotencyKey,omitempty"`
+}
+
+type CreateWebhookInput struct {
+	URL    string         `json:"url"`
+	Events []WebhookEvent `json:"events"`
+	Secret string         `json:"secret"`
 }
 
 type Mutation struct {
 }
 
+type Notification struct {
+	ID        string           `json:"id"`
+	Kind      NotificationKind `json:"kind"`
+	ActorID   string           `json:"actorId"`
+	PostID    string           `json:"postId"`
+	CommentID string           `json:"commentId"`
+	Read      bool             `json:"read"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+type NotificationConnection struct {
+	Edges       []*NotificationEdge `json:"edges"`
+	PageInfo    *PageInfo           `json:"pageInfo"`
+	TotalCount  int                 `json:"totalCount"`
+	UnreadCount int                 `json:"unreadCount"`
+}
+
+type NotificationEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   *Notification `json:"node"`
+}
+
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
 type Post struct {
-	ID              string    `json:"id"`
-	Title           string    `json:"title"`
-	Content         string    `json:"content"`
-	UserID          string    `json:"userId"`
-	Author          *User     `json:"author"`
-	CommentsEnabled bool      `json:"commentsEnabled"`
-	CommPage        *CommPage `json:"commPage"`
+	ID                   string              `json:"id"`
+	Title                string              `json:"title"`
+	Content              string              `json:"content"`
+	UserID               string              `json:"userId"`
+	Author               *User               `json:"author"`
+	CommentsEnabled      bool                `json:"commentsEnabled"`
+	BoardID              *string             `json:"boardId,omitempty"`
+	CreatedAt            time.Time           `json:"createdAt"`
+	CommentCount         int                 `json:"commentCount"`
+	TopLevelCommentCount int                 `json:"topLevelCommentCount"`
+	LastCommentAt        *time.Time          `json:"lastCommentAt,omitempty"`
+	Score                int                 `json:"score"`
+	Upvotes              int                 `json:"upvotes"`
+	Downvotes            int                 `json:"downvotes"`
+	ViewerVote           VoteValue           `json:"viewerVote"`
+	Tags                 []string            `json:"tags"`
+	Reactions            []*ReactionCount    `json:"reactions"`
+	ViewerReactions      []string            `json:"viewerReactions"`
+	Status               ContentStatus       `json:"status"`
+	SlowModeSeconds      int                 `json:"slowModeSeconds"`
+	EditedAt             *time.Time          `json:"editedAt,omitempty"`
+	DeletedAt            *time.Time          `json:"deletedAt,omitempty"`
+	Revisions            *RevisionConnection `json:"revisions"`
+	CommPage             *CommPage           `json:"commPage"`
 }
 
+func (Post) IsNode()            {}
+func (this Post) GetID() string { return this.ID }
+
+func (Post) IsSearchResult() {}
+
 type PostPage struct {
 	Posts      []*Post `json:"posts"`
 	TotalCount int     `json:"totalCount"`
@@ -52,10 +171,682 @@ type PostPage struct {
 type Query struct {
 }
 
+type ReactionCount struct {
+	Reaction string `json:"reaction"`
+	Count    int    `json:"count"`
+}
+
+type ReactionEvent struct {
+	TargetID  string           `json:"targetId"`
+	UserID    string           `json:"userId"`
+	Reaction  string           `json:"reaction"`
+	Added     bool             `json:"added"`
+	Reactions []*ReactionCount `json:"reactions"`
+}
+
+type ReactionInput struct {
+	TargetID string `json:"targetId"`
+	Reaction string `json:"reaction"`
+}
+
+type Registration struct {
+	User  *User  `json:"user"`
+	Token string `json:"token"`
+}
+
+type Report struct {
+	ID          string       `json:"id"`
+	TargetID    string       `json:"targetId"`
+	PostID      string       `json:"postId"`
+	ReporterID  *string      `json:"reporterId,omitempty"`
+	Reason      string       `json:"reason"`
+	Status      ReportStatus `json:"status"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	ModeratorID *string      `json:"moderatorId,omitempty"`
+	Resolution  *string      `json:"resolution,omitempty"`
+	ResolvedAt  *time.Time   `json:"resolvedAt,omitempty"`
+}
+
+type ReportInput struct {
+	TargetID string `json:"targetId"`
+	Reason   string `json:"reason"`
+}
+
+type ReportPage struct {
+	Reports    []*Report `json:"reports"`
+	TotalCount int       `json:"totalCount"`
+}
+
+type ResolveReportInput struct {
+	ReportID string           `json:"reportId"`
+	Action   ModerationAction `json:"action"`
+	Reason   string           `json:"reason"`
+}
+
+type Revision struct {
+	Number    int       `json:"number"`
+	Title     *string   `json:"title,omitempty"`
+	Content   string    `json:"content"`
+	EditorID  string    `json:"editorId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type RevisionConnection struct {
+	Edges      []*RevisionEdge `json:"edges"`
+	PageInfo   *PageInfo       `json:"pageInfo"`
+	TotalCount int             `json:"totalCount"`
+}
+
+type RevisionDiff struct {
+	From *Revision `json:"from"`
+	To   *Revision `json:"to"`
+	Diff string    `json:"diff"`
+}
+
+type RevisionEdge struct {
+	Cursor string    `json:"cursor"`
+	Node   *Revision `json:"node"`
+}
+
+type SearchConnection struct {
+	Edges      []*SearchEdge `json:"edges"`
+	PageInfo   *PageInfo     `json:"pageInfo"`
+	TotalCount int           `json:"totalCount"`
+}
+
+type SearchEdge struct {
+	Cursor  string       `json:"cursor"`
+	Node    SearchResult `json:"node"`
+	Rank    float64      `json:"rank"`
+	Snippet string       `json:"snippet"`
+}
+
+type SearchFilter struct {
+	AuthorID *string    `json:"authorId,omitempty"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+}
+
+type SlowModeInput struct {
+	PostID  string `json:"postId"`
+	Seconds int    `json:"seconds"`
+}
+
 type Subscription struct {
 }
 
+type Tag struct {
+	Name      string `json:"name"`
+	PostCount int    `json:"postCount"`
+}
+
+type UpdateCommentInput struct {
+	CommentID string `json:"commentId"`
+	Content   string `json:"content"`
+}
+
+type UpdatePostInput struct {
+	PostID  string  `json:"postId"`
+	Title   *string `json:"title,omitempty"`
+	Content *string `json:"content,omitempty"`
+}
+
 type User struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
+	ID       string    `json:"id"`
+	Username string    `json:"username"`
+	Deleted  bool      `json:"deleted"`
+	Posts    *PostPage `json:"posts"`
+	Comments *CommPage `json:"comments"`
+}
+
+func (User) IsNode()            {}
+func (this User) GetID() string { return this.ID }
+
+type VoteInput struct {
+	TargetID string    `json:"targetId"`
+	Value    VoteValue `json:"value"`
+}
+
+type VoteResult struct {
+	TargetID  string    `json:"targetId"`
+	Value     VoteValue `json:"value"`
+	Score     int       `json:"score"`
+	Upvotes   int       `json:"upvotes"`
+	Downvotes int       `json:"downvotes"`
+}
+
+type Webhook struct {
+	ID        string         `json:"id"`
+	URL       string         `json:"url"`
+	Events    []WebhookEvent `json:"events"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	WebhookID      string                `json:"webhookId"`
+	Event          WebhookEvent          `json:"event"`
+	Payload        string                `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"nextAttemptAt"`
+	ResponseStatus *int                  `json:"responseStatus,omitempty"`
+	LastError      string                `json:"lastError"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty"`
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+	TotalCount int                `json:"totalCount"`
+}
+
+type CommentSort string
+
+const (
+	CommentSortTop CommentSort = "TOP"
+	CommentSortHot CommentSort = "HOT"
+)
+
+var AllCommentSort = []CommentSort{
+	CommentSortTop,
+	CommentSortHot,
+}
+
+func (e CommentSort) IsValid() bool {
+	switch e {
+	case CommentSortTop, CommentSortHot:
+		return true
+	}
+	return false
+}
+
+func (e CommentSort) String() string {
+	return string(e)
+}
+
+func (e *CommentSort) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentSort", str)
+	}
+	return nil
+}
+
+func (e CommentSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ContentStatus string
+
+const (
+	ContentStatusVisible ContentStatus = "VISIBLE"
+	ContentStatusHidden  ContentStatus = "HIDDEN"
+	ContentStatusDeleted ContentStatus = "DELETED"
+)
+
+var AllContentStatus = []ContentStatus{
+	ContentStatusVisible,
+	ContentStatusHidden,
+	ContentStatusDeleted,
+}
+
+func (e ContentStatus) IsValid() bool {
+	switch e {
+	case ContentStatusVisible, ContentStatusHidden, ContentStatusDeleted:
+		return true
+	}
+	return false
+}
+
+func (e ContentStatus) String() string {
+	return string(e)
+}
+
+func (e *ContentStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ContentStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ContentStatus", str)
+	}
+	return nil
+}
+
+func (e ContentStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type DeleteAccountMode string
+
+const (
+	DeleteAccountModeKeepContent   DeleteAccountMode = "KEEP_CONTENT"
+	DeleteAccountModeRemoveContent DeleteAccountMode = "REMOVE_CONTENT"
+)
+
+var AllDeleteAccountMode = []DeleteAccountMode{
+	DeleteAccountModeKeepContent,
+	DeleteAccountModeRemoveContent,
+}
+
+func (e DeleteAccountMode) IsValid() bool {
+	switch e {
+	case DeleteAccountModeKeepContent, DeleteAccountModeRemoveContent:
+		return true
+	}
+	return false
+}
+
+func (e DeleteAccountMode) String() string {
+	return string(e)
+}
+
+func (e *DeleteAccountMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DeleteAccountMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DeleteAccountMode", str)
+	}
+	return nil
+}
+
+func (e DeleteAccountMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ModerationAction string
+
+const (
+	ModerationActionApprove ModerationAction = "APPROVE"
+	ModerationActionHide    ModerationAction = "HIDE"
+	ModerationActionDelete  ModerationAction = "DELETE"
+)
+
+var AllModerationAction = []ModerationAction{
+	ModerationActionApprove,
+	ModerationActionHide,
+	ModerationActionDelete,
+}
+
+func (e ModerationAction) IsValid() bool {
+	switch e {
+	case ModerationActionApprove, ModerationActionHide, ModerationActionDelete:
+		return true
+	}
+	return false
+}
+
+func (e ModerationAction) String() string {
+	return string(e)
+}
+
+func (e *ModerationAction) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ModerationAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ModerationAction", str)
+	}
+	return nil
+}
+
+func (e ModerationAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type NotificationKind string
+
+const (
+	NotificationKindMention NotificationKind = "MENTION"
+	NotificationKindReply   NotificationKind = "REPLY"
+)
+
+var AllNotificationKind = []NotificationKind{
+	NotificationKindMention,
+	NotificationKindReply,
+}
+
+func (e NotificationKind) IsValid() bool {
+	switch e {
+	case NotificationKindMention, NotificationKindReply:
+		return true
+	}
+	return false
+}
+
+func (e NotificationKind) String() string {
+	return string(e)
+}
+
+func (e *NotificationKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationKind", str)
+	}
+	return nil
+}
+
+func (e NotificationKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PostSort string
+
+const (
+	PostSortCommentCount         PostSort = "COMMENT_COUNT"
+	PostSortTopLevelCommentCount PostSort = "TOP_LEVEL_COMMENT_COUNT"
+	PostSortLastCommentAt        PostSort = "LAST_COMMENT_AT"
+	PostSortTop                  PostSort = "TOP"
+	PostSortHot                  PostSort = "HOT"
+)
+
+var AllPostSort = []PostSort{
+	PostSortCommentCount,
+	PostSortTopLevelCommentCount,
+	PostSortLastCommentAt,
+	PostSortTop,
+	PostSortHot,
+}
+
+func (e PostSort) IsValid() bool {
+	switch e {
+	case PostSortCommentCount, PostSortTopLevelCommentCount, PostSortLastCommentAt, PostSortTop, PostSortHot:
+		return true
+	}
+	return false
+}
+
+func (e PostSort) String() string {
+	return string(e)
+}
+
+func (e *PostSort) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostSort", str)
+	}
+	return nil
+}
+
+func (e PostSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportStatus string
+
+const (
+	ReportStatusOpen     ReportStatus = "OPEN"
+	ReportStatusApproved ReportStatus = "APPROVED"
+	ReportStatusHidden   ReportStatus = "HIDDEN"
+	ReportStatusDeleted  ReportStatus = "DELETED"
+)
+
+var AllReportStatus = []ReportStatus{
+	ReportStatusOpen,
+	ReportStatusApproved,
+	ReportStatusHidden,
+	ReportStatusDeleted,
+}
+
+func (e ReportStatus) IsValid() bool {
+	switch e {
+	case ReportStatusOpen, ReportStatusApproved, ReportStatusHidden, ReportStatusDeleted:
+		return true
+	}
+	return false
+}
+
+func (e ReportStatus) String() string {
+	return string(e)
+}
+
+func (e *ReportStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportStatus", str)
+	}
+	return nil
+}
+
+func (e ReportStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type SearchType string
+
+const (
+	SearchTypePost    SearchType = "POST"
+	SearchTypeComment SearchType = "COMMENT"
+)
+
+var AllSearchType = []SearchType{
+	SearchTypePost,
+	SearchTypeComment,
+}
+
+func (e SearchType) IsValid() bool {
+	switch e {
+	case SearchTypePost, SearchTypeComment:
+		return true
+	}
+	return false
+}
+
+func (e SearchType) String() string {
+	return string(e)
+}
+
+func (e *SearchType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SearchType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SearchType", str)
+	}
+	return nil
+}
+
+func (e SearchType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type TagMatch string
+
+const (
+	TagMatchAny TagMatch = "ANY"
+	TagMatchAll TagMatch = "ALL"
+)
+
+var AllTagMatch = []TagMatch{
+	TagMatchAny,
+	TagMatchAll,
+}
+
+func (e TagMatch) IsValid() bool {
+	switch e {
+	case TagMatchAny, TagMatchAll:
+		return true
+	}
+	return false
+}
+
+func (e TagMatch) String() string {
+	return string(e)
+}
+
+func (e *TagMatch) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TagMatch(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TagMatch", str)
+	}
+	return nil
+}
+
+func (e TagMatch) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type VoteValue string
+
+const (
+	VoteValueUp   VoteValue = "UP"
+	VoteValueDown VoteValue = "DOWN"
+	VoteValueNone VoteValue = "NONE"
+)
+
+var AllVoteValue = []VoteValue{
+	VoteValueUp,
+	VoteValueDown,
+	VoteValueNone,
+}
+
+func (e VoteValue) IsValid() bool {
+	switch e {
+	case VoteValueUp, VoteValueDown, VoteValueNone:
+		return true
+	}
+	return false
+}
+
+func (e VoteValue) String() string {
+	return string(e)
+}
+
+func (e *VoteValue) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = VoteValue(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid VoteValue", str)
+	}
+	return nil
+}
+
+func (e VoteValue) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryStatusDead      WebhookDeliveryStatus = "DEAD"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusDelivered,
+	WebhookDeliveryStatusDead,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusDelivered, WebhookDeliveryStatusDead:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookEvent string
+
+const (
+	WebhookEventPostCreated    WebhookEvent = "POST_CREATED"
+	WebhookEventCommentCreated WebhookEvent = "COMMENT_CREATED"
+)
+
+var AllWebhookEvent = []WebhookEvent{
+	WebhookEventPostCreated,
+	WebhookEventCommentCreated,
+}
+
+func (e WebhookEvent) IsValid() bool {
+	switch e {
+	case WebhookEventPostCreated, WebhookEventCommentCreated:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEvent) String() string {
+	return string(e)
+}
+
+func (e *WebhookEvent) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEvent", str)
+	}
+	return nil
+}
+
+func (e WebhookEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
@@ -0,0 +1,200 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/auth"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/moderation"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultModeration - этапы модерации по умолчанию. Без запрещённых слов
+// этап banned_words ничего не находит
+const DefaultModeration = "banned_words=reject,links=queue:5,spam=queue:20,length=reject:20000"
+
+// заглушки вместо текста скрытых и удалённых записей
+const (
+	hiddenPlaceholder  = "[hidden by moderator]"
+	deletedPlaceholder = "[deleted by moderator]"
+)
+
+// максимальная длина причины жалобы и решения модератора в символах
+const maxReasonLength = 500
+
+var (
+	errNotModerator = errors.New("only moderators can do this, pass a moderator token in " + auth.Header)
+	errNoReporter   = errors.New("reports are available only to the authenticated user, pass their token in " + auth.Header)
+)
+
+// ParseModerators разбирает глобальные id модераторов через запятую
+func ParseModerators(list string) (map[uint]bool, error) {
+	moderators := make(map[uint]bool)
+	for _, gid := range strings.Split(list, ",") {
+		gid = strings.TrimSpace(gid)
+		if gid == "" {
+			continue
+		}
+
+		id, err := globalid.DecodeAs(gid, globalid.User)
+		if err != nil {
+			return nil, err
+		}
+		moderators[id] = true
+	}
+
+	return moderators, nil
+}
+
+// moderator возвращает пользователя запроса, если он модератор
+func (r *Resolver) moderator(ctx context.Context) (uint, bool) {
+	viewer, ok := auth.Viewer(ctx)
+	return viewer, ok && r.moderators[viewer]
+}
+
+// moderate проверяет запись перед созданием, для отклонённой возвращает ошибку
+func (r *Resolver) moderate(item moderation.Item) (moderation.Verdict, error) {
+	verdict := r.moderation.Check(item)
+	return verdict, verdict.Err()
+}
+
+// enqueue отправляет созданную запись в очередь модерации, если этого требует verdict.
+// Запись уже создана, поэтому ошибка только пишется в лог
+func (r *Resolver) enqueue(ctx context.Context, target smodel.Target, verdict moderation.Verdict) {
+	if verdict.Action != moderation.Queue {
+		return
+	}
+
+	_, err := r.storage.CreateReport(ctx, smodel.CreateReport{
+		Target: target,
+		Reason: verdict.Stage + ": " + verdict.Reason,
+	})
+	if err != nil {
+		logrus.Errorf("failed queue %s %d for moderation: %s", target.Type, target.ID, err.Error())
+	}
+}
+
+// visibleText возвращает текст записи или заглушку, если запись скрыта
+// или удалена автором, а пользователь запроса не модератор
+func (r *Resolver) visibleText(ctx context.Context, status model.ContentStatus, deletedAt *time.Time, text string) string {
+	if status == model.ContentStatusDeleted {
+		return deletedPlaceholder
+	}
+	if _, ok := r.moderator(ctx); ok {
+		return text
+	}
+
+	switch {
+	case deletedAt != nil:
+		return removedPlaceholder
+	case status == model.ContentStatusHidden:
+		return hiddenPlaceholder
+	}
+	return text
+}
+
+// report создаёт жалобу пользователя запроса на запись с глобальным id типа typ
+func (r *Resolver) report(ctx context.Context, input model.ReportInput, typ string) (*model.Report, error) {
+	reporter, ok := auth.Viewer(ctx)
+	if !ok {
+		return nil, errNoReporter
+	}
+
+	if _, err := globalid.DecodeAs(input.TargetID, typ); err != nil {
+		return nil, err
+	}
+	target, err := parseTarget(input.TargetID)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := checkReason(input.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := r.storage.CreateReport(ctx, smodel.CreateReport{
+		ReporterId: &reporter,
+		Target:     target,
+		Reason:     reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report.ToGraphQL(), nil
+}
+
+func (r *Resolver) resolveReport(ctx context.Context, input model.ResolveReportInput) (*model.Report, error) {
+	moderator, ok := r.moderator(ctx)
+	if !ok {
+		return nil, errNotModerator
+	}
+
+	rid, err := globalid.DecodeAs(input.ReportID, globalid.Report)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := checkReason(input.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := r.storage.ResolveReport(ctx, smodel.ResolveReport{
+		ReportId:    rid,
+		ModeratorId: moderator,
+		Action:      smodel.ModerationAction(input.Action),
+		Reason:      reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report.ToGraphQL(), nil
+}
+
+func (r *Resolver) moderationQueue(ctx context.Context, status *model.ReportStatus, limit, offset *int) (*model.ReportPage, error) {
+	if _, ok := r.moderator(ctx); !ok {
+		return nil, errNotModerator
+	}
+
+	reportStatus := smodel.ReportOpen
+	if status != nil {
+		reportStatus = smodel.ReportStatus(*status)
+	}
+	lim, off := setLimOff(limit, offset)
+
+	page, err := r.storage.GetReports(ctx, lim, off, reportStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*model.Report, 0, len(page.Reports))
+	for _, report := range page.Reports {
+		reports = append(reports, report.ToGraphQL())
+	}
+
+	return &model.ReportPage{
+		Reports:    reports,
+		TotalCount: page.TotalCount,
+	}, nil
+}
+
+// checkReason проверяет причину жалобы или решения
+func checkReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", fmt.Errorf("empty reason")
+	}
+	if length := len([]rune(reason)); length > maxReasonLength {
+		return "", fmt.Errorf("very long reason, simvol lenght = %d > %d", length, maxReasonLength)
+	}
+	return reason, nil
+}
@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// node находит объект по глобальному id, тип объекта берётся из id.
+// Страницы комментариев поста и ответов комментария возвращаются
+// с limit и offset по умолчанию
+func (r *Resolver) node(ctx context.Context, id string) (model.Node, error) {
+	typ, sid, err := globalid.Decode(id)
+	if err != nil {
+		return nil, err
+	}
+
+	lim, off := setLimOff(nil, nil)
+
+	switch typ {
+	case globalid.Post:
+		post, err := r.storage.GetPost(ctx, lim, off, smodel.CommentSortDefault, sid)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.checkRemoved(ctx, smodel.Target{Type: smodel.TargetPost, ID: sid}, post.RemovedAt); err != nil {
+			return nil, err
+		}
+		return post.ToGraphQL(), nil
+	case globalid.Comment:
+		comm, err := r.storage.GetComments(ctx, lim, off, smodel.CommentSortDefault, sid)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.checkCommentRemoved(ctx, comm); err != nil {
+			return nil, err
+		}
+		return comm.ToGraphQL(), nil
+	case globalid.User:
+		user, err := r.storage.GetUser(ctx, sid)
+		if err != nil {
+			return nil, err
+		}
+		return user.ToGraphQL(), nil
+	case globalid.Board:
+		board, err := r.storage.GetBoard(ctx, sid)
+		if err != nil {
+			return nil, err
+		}
+		return board.ToGraphQL(), nil
+	}
+
+	return nil, fmt.Errorf("unknown type %q of id %q", typ, id)
+}
+
+// nodes находит объекты по глобальным id. Если объект не найден,
+// то на его месте null, а ошибка добавляется в ответ
+func (r *Resolver) nodes(ctx context.Context, ids []string) ([]model.Node, error) {
+	nodes := make([]model.Node, len(ids))
+	for i, id := range ids {
+		node, err := r.node(ctx, id)
+		if err != nil {
+			// запрос отменён, искать остальные объекты нет смысла
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			graphql.AddError(ctx, err)
+			continue
+		}
+		nodes[i] = node
+	}
+
+	return nodes, nil
+}
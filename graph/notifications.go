@@ -0,0 +1,135 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/auth"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+var errNoViewer = errors.New("notifications are available only to the authenticated user, pass their token in " + auth.Header)
+
+// notifications возвращает страницу уведомлений пользователя запроса, новые первыми
+func (r *Resolver) notifications(ctx context.Context, first *int, after *string, unreadOnly bool) (*model.NotificationConnection, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return nil, errNoViewer
+	}
+
+	lim, off, err := pageAfter(first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := r.storage.GetNotifications(ctx, viewer, lim, off, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make([]*model.NotificationEdge, 0, len(page.Notifications))
+	for i, n := range page.Notifications {
+		edges = append(edges, &model.NotificationEdge{
+			Cursor: encodeCursor(off + i),
+			Node:   n.ToGraphQL(),
+		})
+	}
+
+	pageInfo := &model.PageInfo{
+		HasNextPage: off+len(edges) < page.TotalCount,
+	}
+	if len(edges) > 0 {
+		pageInfo.EndCursor = &edges[len(edges)-1].Cursor
+	}
+
+	return &model.NotificationConnection{
+		Edges:       edges,
+		PageInfo:    pageInfo,
+		TotalCount:  page.TotalCount,
+		UnreadCount: page.UnreadCount,
+	}, nil
+}
+
+// markNotificationsRead отмечает уведомления прочитанными, без ids - все уведомления.
+// Возвращает количество уведомлений, которые были непрочитанными
+func (r *Resolver) markNotificationsRead(ctx context.Context, ids []string) (int, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return 0, errNoViewer
+	}
+
+	var nids []uint
+	if ids != nil {
+		nids = make([]uint, 0, len(ids))
+		for _, id := range ids {
+			nid, err := globalid.DecodeAs(id, globalid.Notification)
+			if err != nil {
+				return 0, err
+			}
+			nids = append(nids, nid)
+		}
+	}
+
+	return r.storage.MarkNotificationsRead(ctx, viewer, nids)
+}
+
+// notificationAdded подписывает пользователя запроса на его новые уведомления
+func (r *Resolver) notificationAdded(ctx context.Context) (<-chan *model.Notification, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return nil, errNoViewer
+	}
+
+	notifications := make(chan *model.Notification, 1)
+
+	// у пользователя может быть несколько подписок, например в разных вкладках
+	r.mu.Lock()
+	r.notificationSubscribers[viewer] = append(r.notificationSubscribers[viewer], notifications)
+	r.mu.Unlock()
+
+	// когда контекст завершится, то произойдёт удаление этой подписки
+	go func() {
+		<-ctx.Done()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		subscribers := r.notificationSubscribers[viewer]
+		for i, subscriber := range subscribers {
+			if subscriber == notifications {
+				subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+				break
+			}
+		}
+		if len(subscribers) == 0 {
+			delete(r.notificationSubscribers, viewer)
+		} else {
+			r.notificationSubscribers[viewer] = subscribers
+		}
+	}()
+
+	return notifications, nil
+}
+
+// NotifyNotificationSubscribers отправляет новые уведомления подписанным получателям.
+// Отправка не блокирует создание комментария: если подписчик не успевает читать,
+// уведомление остаётся только в списке notifications
+func (r *Resolver) NotifyNotificationSubscribers(notifications []smodel.Notification) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i := range notifications {
+		subscribers := r.notificationSubscribers[notifications[i].UserID]
+		if len(subscribers) == 0 {
+			continue
+		}
+		notification := notifications[i].ToGraphQL()
+		for _, subscriber := range subscribers {
+			select {
+			case subscriber <- notification:
+			default:
+			}
+		}
+	}
+}
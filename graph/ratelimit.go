@@ -0,0 +1,116 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/auth"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/ratelimit"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// DefaultRateLimits - ограничения мутаций по умолчанию
+const DefaultRateLimits = "createComment:user=10/1m,createComment:ip=60/1m,createPost:user=5/1m,createPost:ip=30/1m"
+
+// максимальный интервал медленного режима поста
+const maxSlowModeSeconds = 24 * 60 * 60
+
+// код ошибки в extensions для отклонённых ограничением запросов
+const rateLimitedCode = "RATE_LIMITED"
+
+var errNotPostAuthor = errors.New("only the post author or moderators can do this, pass their token in " + auth.Header)
+
+// RateLimit ограничивает частоту мутаций по правилам limits: отдельно для
+// пользователя запроса и для IP
+func RateLimit(limiter ratelimit.Limiter, limits ratelimit.Limits) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if fc == nil || fc.Object != "Mutation" {
+			return next(ctx)
+		}
+		rules, ok := limits[fc.Field.Name]
+		if !ok {
+			return next(ctx)
+		}
+
+		// userId из input задаёт клиент, поэтому лимит считается только по токену,
+		// а анонимный запрос ограничивается лимитом IP
+		if user, ok := auth.Viewer(ctx); ok && rules.User != nil {
+			key := fc.Field.Name + ":user:" + strconv.FormatUint(uint64(user), 10)
+			if err := limiter.Take(ctx, key, *rules.User); err != nil {
+				return nil, err
+			}
+		}
+
+		if ip := ratelimit.IP(ctx); ip != "" && rules.IP != nil {
+			if err := limiter.Take(ctx, fc.Field.Name+":ip:"+ip, *rules.IP); err != nil {
+				return nil, err
+			}
+		}
+
+		return next(ctx)
+	}
+}
+
+// ErrorPresenter добавляет к ошибкам ограничения частоты код RATE_LIMITED
+// и retryAfter - через сколько секунд можно повторить запрос
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	var rlErr *ratelimit.Error
+	if errors.As(err, &rlErr) {
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = make(map[string]interface{})
+		}
+		gqlErr.Extensions["code"] = rateLimitedCode
+		gqlErr.Extensions["retryAfter"] = rlErr.RetryAfterSeconds()
+	}
+	return gqlErr
+}
+
+func checkSlowMode(seconds int) error {
+	if seconds < 0 || seconds > maxSlowModeSeconds {
+		return fmt.Errorf("slow mode must be from 0 to %d seconds, got %d", maxSlowModeSeconds, seconds)
+	}
+	return nil
+}
+
+// setSlowMode меняет медленный режим поста, доступно автору поста и модераторам
+func (r *Resolver) setSlowMode(ctx context.Context, input model.SlowModeInput) (*model.Post, error) {
+	pid, err := globalid.DecodeAs(input.PostID, globalid.Post)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSlowMode(input.Seconds); err != nil {
+		return nil, err
+	}
+
+	if _, ok := r.moderator(ctx); !ok {
+		viewer, ok := auth.Viewer(ctx)
+		if !ok {
+			return nil, errNotPostAuthor
+		}
+
+		post, err := r.storage.GetPost(ctx, 0, 0, smodel.CommentSortDefault, pid)
+		if err != nil {
+			return nil, err
+		}
+		if post.UserID != viewer {
+			return nil, errNotPostAuthor
+		}
+	}
+
+	post, err := r.storage.SetSlowMode(ctx, smodel.SetSlowMode{PostId: pid, Seconds: input.Seconds})
+	if err != nil {
+		return nil, err
+	}
+
+	return post.ToGraphQL(), nil
+}
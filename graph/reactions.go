@@ -0,0 +1,216 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/auth"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// DefaultReactions - реакции, если список не задан в окружении
+const DefaultReactions = "👍,👎,❤️,😂,😮,😢"
+
+var errNoReactor = errors.New("reactions are available only to the authenticated user, pass their token in " + auth.Header)
+
+// ParseReactions разбирает список реакций через запятую
+func ParseReactions(list string) ([]string, error) {
+	reactions := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, reaction := range strings.Split(list, ",") {
+		reaction = strings.TrimSpace(reaction)
+		if reaction == "" {
+			continue
+		}
+		if seen[reaction] {
+			return nil, fmt.Errorf("duplicate reaction %q", reaction)
+		}
+		seen[reaction] = true
+		reactions = append(reactions, reaction)
+	}
+
+	if len(reactions) == 0 {
+		return nil, fmt.Errorf("empty reactions list")
+	}
+
+	return reactions, nil
+}
+
+// reactionTarget переводит глобальный id поста или комментария в цель реакции
+func parseTarget(id string) (smodel.Target, error) {
+	typ, sid, err := globalid.Decode(id)
+	if err != nil {
+		return smodel.Target{}, err
+	}
+
+	switch typ {
+	case globalid.Post:
+		return smodel.Target{Type: smodel.TargetPost, ID: sid}, nil
+	case globalid.Comment:
+		return smodel.Target{Type: smodel.TargetComment, ID: sid}, nil
+	}
+
+	return smodel.Target{}, fmt.Errorf("id %q is %s id, expected %s or %s id", id, typ, globalid.Post, globalid.Comment)
+}
+
+// changeReaction ставит (add) или снимает реакцию пользователя запроса
+// и уведомляет подписчиков поста
+func (r *Resolver) changeReaction(ctx context.Context, input model.ReactionInput, add bool) (*model.ReactionEvent, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return nil, errNoReactor
+	}
+
+	target, err := parseTarget(input.TargetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := r.reactionOrder[input.Reaction]; !ok {
+		return nil, fmt.Errorf("unknown reaction %q", input.Reaction)
+	}
+
+	react := smodel.React{
+		UserId:   viewer,
+		Target:   target,
+		Reaction: input.Reaction,
+	}
+
+	var summary *smodel.ReactionSummary
+	if add {
+		summary, err = r.storage.React(ctx, react)
+	} else {
+		summary, err = r.storage.Unreact(ctx, react)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	event := &model.ReactionEvent{
+		TargetID:  input.TargetID,
+		UserID:    globalid.Encode(globalid.User, viewer),
+		Reaction:  input.Reaction,
+		Added:     add,
+		Reactions: r.reactionCounts(summary.Counts),
+	}
+
+	// повтор ничего не изменил, уведомлять не о чем
+	if summary.Changed {
+		r.NotifyReactionSubscribers(summary.PostID, event)
+	}
+
+	return event, nil
+}
+
+// reactionCounts возвращает количества реакций в порядке списка реакций.
+// Реакции, убранные из списка, остаются в конце в порядке имён
+func (r *Resolver) reactionCounts(counts []*smodel.ReactionCount) []*model.ReactionCount {
+	res := make([]*model.ReactionCount, 0, len(counts))
+	for _, count := range counts {
+		res = append(res, &model.ReactionCount{
+			Reaction: count.Reaction,
+			Count:    count.Count,
+		})
+	}
+	// counts уже в порядке имён
+	sort.SliceStable(res, func(i, j int) bool { return r.order(res[i].Reaction) < r.order(res[j].Reaction) })
+
+	return res
+}
+
+// reactions возвращает количества реакций на пост или комментарий
+func (r *Resolver) reactions(ctx context.Context, id string) ([]*model.ReactionCount, error) {
+	target, err := parseTarget(id)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := r.storage.GetReactions(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.reactionCounts(counts), nil
+}
+
+// viewerReactions возвращает реакции пользователя запроса, для анонимного запроса - пусто
+func (r *Resolver) viewerReactions(ctx context.Context, id string) ([]string, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return []string{}, nil
+	}
+
+	target, err := parseTarget(id)
+	if err != nil {
+		return nil, err
+	}
+
+	reactions, err := r.storage.GetUserReactions(ctx, viewer, target)
+	if err != nil {
+		return nil, err
+	}
+	// в порядке списка реакций, как количества
+	sort.SliceStable(reactions, func(i, j int) bool { return r.order(reactions[i]) < r.order(reactions[j]) })
+
+	return reactions, nil
+}
+
+// order - место реакции в списке реакций, убранные из списка - в конце
+func (r *Resolver) order(reaction string) int {
+	if i, ok := r.reactionOrder[reaction]; ok {
+		return i
+	}
+	return len(r.reactionOrder)
+}
+
+// reactionChanged подписывает на изменения реакций поста postId
+func (r *Resolver) reactionChanged(ctx context.Context, postId uint) <-chan *model.ReactionEvent {
+	events := make(chan *model.ReactionEvent, 1)
+
+	r.mu.Lock()
+	r.reactionSubscribers[postId] = append(r.reactionSubscribers[postId], events)
+	r.mu.Unlock()
+
+	// когда контекст завершится, то произойдёт удаление подписки к посту
+	go func() {
+		<-ctx.Done()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		subscribers := r.reactionSubscribers[postId]
+		for i, subscriber := range subscribers {
+			if subscriber == events {
+				subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+				break
+			}
+		}
+		if len(subscribers) == 0 {
+			delete(r.reactionSubscribers, postId)
+		} else {
+			r.reactionSubscribers[postId] = subscribers
+		}
+	}()
+
+	return events
+}
+
+// NotifyReactionSubscribers отправляет событие всем подписчикам поста.
+// Отправка не блокирует реакцию: если подписчик не успевает читать,
+// событие ему не доставляется, количества он получит со следующим
+func (r *Resolver) NotifyReactionSubscribers(postId uint, event *model.ReactionEvent) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, subscriber := range r.reactionSubscribers[postId] {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
@@ -2,9 +2,14 @@ package graph
 
 import (
 	"sync"
+	"time"
 
 	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/auth"
+	"github.com/leonideliseev/ozonTestTask/pkg/moderation"
+	"github.com/leonideliseev/ozonTestTask/pkg/outbox"
 	"github.com/leonideliseev/ozonTestTask/pkg/storage"
+	"github.com/leonideliseev/ozonTestTask/pkg/webhook"
 )
 
 // This file will not be regenerated automatically.
@@ -13,13 +18,57 @@ import (
 
 type Resolver struct{
 	storage storage.Storage
-	subscribers  map[string]chan *model.Comment
+	subscribers  map[uint][]chan *model.Comment
+	// подписки на реакции, на один пост может быть подписано несколько клиентов
+	reactionSubscribers map[uint][]chan *model.ReactionEvent
+	// подписки пользователей на их уведомления
+	notificationSubscribers map[uint][]chan *model.Notification
 	mu           sync.RWMutex
+
+	// реакции, которые можно поставить, и их порядок в списке
+	reactionList  []string
+	reactionOrder map[string]int
+
+	// проверка постов и комментариев перед записью в хранилище
+	moderation *moderation.Pipeline
+	// пользователи, которые разбирают жалобы и видят скрытые записи
+	moderators map[uint]bool
+	// сколько хранятся ключи идемпотентности
+	idempotencyTTL time.Duration
+	// отправка событий вебхукам, nil - без вебхуков
+	webhooks *webhook.Dispatcher
+	// рассылка событий из outbox хранилища, nil - события рассылаются сразу
+	relay *outbox.Relay
+	// сколько удалённые записи можно восстановить
+	retention time.Duration
+	// выдача токенов пользователям
+	authenticator *auth.Authenticator
 }
 
-func NewResolver(store storage.Storage) *Resolver {
-    return &Resolver{
+func NewResolver(store storage.Storage, reactions []string, pipeline *moderation.Pipeline, moderators map[uint]bool, idempotencyTTL time.Duration, webhooks *webhook.Dispatcher, relay *outbox.Relay, retention time.Duration, authenticator *auth.Authenticator) *Resolver {
+	order := make(map[string]int, len(reactions))
+	for i, reaction := range reactions {
+		order[reaction] = i
+	}
+
+    r := &Resolver{
 		storage: store,
-		subscribers: make(map[string]chan *model.Comment),
+		subscribers: make(map[uint][]chan *model.Comment),
+		reactionSubscribers: make(map[uint][]chan *model.ReactionEvent),
+		notificationSubscribers: make(map[uint][]chan *model.Notification),
+		reactionList: reactions,
+		reactionOrder: order,
+		moderation: pipeline,
+		moderators: moderators,
+		idempotencyTTL: idempotencyTTL,
+		webhooks: webhooks,
+		relay: relay,
+		retention: retention,
+		authenticator: authenticator,
+	}
+	if relay != nil {
+		r.addConsumers(relay)
 	}
+
+	return r
 }
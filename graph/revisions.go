@@ -0,0 +1,224 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/auth"
+	"github.com/leonideliseev/ozonTestTask/pkg/diff"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/moderation"
+)
+
+// История версий скрытых и удалённых записей доступна только модераторам:
+// остальным список версий отдаётся пустым, а diff - с ошибкой
+
+var (
+	errNotAuthor       = errors.New("only the author can edit, pass their token in " + auth.Header)
+	errRevisionsHidden = errors.New("revisions of hidden and deleted records are available only to moderators")
+)
+
+// updatePost меняет заголовок и текст поста его автором.
+// Новый текст проходит модерацию, как при создании
+func (r *Resolver) updatePost(ctx context.Context, input model.UpdatePostInput) (*model.Post, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return nil, errNotAuthor
+	}
+
+	pid, err := globalid.DecodeAs(input.PostID, globalid.Post)
+	if err != nil {
+		return nil, err
+	}
+
+	edit := smodel.EditPost{
+		PostId:   pid,
+		EditorId: viewer,
+		Title:    input.Title,
+		Content:  input.Content,
+	}
+
+	// модерация проверяет пост целиком, неизменённые поля берутся из текущего.
+	// Хранилище сохраняет изменение, только если они не изменились после чтения,
+	// поэтому сохраняется тот текст, который проверила модерация
+	item := moderation.Item{Kind: moderation.KindPost, UserID: viewer}
+	if input.Title == nil || input.Content == nil {
+		post, err := r.storage.GetPost(ctx, 0, 0, smodel.CommentSortDefault, pid)
+		if err != nil {
+			return nil, err
+		}
+		item.Title, item.Content = post.Title, post.Content
+		edit.BaseTitle, edit.BaseContent = &post.Title, &post.Content
+	}
+	if input.Title != nil {
+		item.Title = *input.Title
+	}
+	if input.Content != nil {
+		item.Content = *input.Content
+	}
+
+	verdict, err := r.moderate(item)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := r.storage.EditPost(ctx, edit)
+	if err != nil {
+		return nil, err
+	}
+
+	r.enqueue(ctx, smodel.Target{Type: smodel.TargetPost, ID: post.ID}, verdict)
+
+	return post.ToGraphQL(), nil
+}
+
+// updateComment меняет текст комментария его автором
+func (r *Resolver) updateComment(ctx context.Context, input model.UpdateCommentInput) (*model.Comment, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return nil, errNotAuthor
+	}
+
+	cid, err := globalid.DecodeAs(input.CommentID, globalid.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	// проверка на длину комментария
+	if length := len([]rune(input.Content)); length > maxCommentLength {
+		return nil, fmt.Errorf("very long comment, simvol lenght = %d > %d", length, maxCommentLength)
+	}
+
+	verdict, err := r.moderate(moderation.Item{Kind: moderation.KindComment, UserID: viewer, Content: input.Content})
+	if err != nil {
+		return nil, err
+	}
+
+	comm, err := r.storage.EditComment(ctx, smodel.EditComment{
+		CommentId: cid,
+		EditorId:  viewer,
+		Content:   input.Content,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.enqueue(ctx, smodel.Target{Type: smodel.TargetComment, ID: comm.ID}, verdict)
+
+	return comm.ToGraphQL(), nil
+}
+
+// canViewRevisions проверяет, что пользователь запроса может видеть версии записи
+func (r *Resolver) canViewRevisions(ctx context.Context, status model.ContentStatus, deletedAt *time.Time) bool {
+	if status == model.ContentStatusVisible && deletedAt == nil {
+		return true
+	}
+	_, ok := r.moderator(ctx)
+	return ok
+}
+
+// revisions возвращает страницу версий записи, от старых к новым
+func (r *Resolver) revisions(ctx context.Context, target smodel.Target, status model.ContentStatus, deletedAt *time.Time, first *int, after *string) (*model.RevisionConnection, error) {
+	lim, off, err := pageAfter(first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.canViewRevisions(ctx, status, deletedAt) {
+		return &model.RevisionConnection{Edges: []*model.RevisionEdge{}, PageInfo: &model.PageInfo{}}, nil
+	}
+
+	page, err := r.storage.GetRevisions(ctx, target, lim, off)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make([]*model.RevisionEdge, 0, len(page.Revisions))
+	for i, revision := range page.Revisions {
+		edges = append(edges, &model.RevisionEdge{
+			Cursor: encodeCursor(off + i),
+			Node:   revision.ToGraphQL(),
+		})
+	}
+
+	pageInfo := &model.PageInfo{
+		HasNextPage: off+len(edges) < page.TotalCount,
+	}
+	if len(edges) > 0 {
+		pageInfo.EndCursor = &edges[len(edges)-1].Cursor
+	}
+
+	return &model.RevisionConnection{
+		Edges:      edges,
+		PageInfo:   pageInfo,
+		TotalCount: page.TotalCount,
+	}, nil
+}
+
+// postRevisionDiff возвращает diff версий поста
+func (r *Resolver) postRevisionDiff(ctx context.Context, postID string, from, to int) (*model.RevisionDiff, error) {
+	pid, err := globalid.DecodeAs(postID, globalid.Post)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := r.storage.GetPost(ctx, 0, 0, smodel.CommentSortDefault, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.revisionDiff(ctx, smodel.Target{Type: smodel.TargetPost, ID: pid}, post.Status.ToGraphQL(), post.RemovedAt, from, to)
+}
+
+// commentRevisionDiff возвращает diff версий комментария
+func (r *Resolver) commentRevisionDiff(ctx context.Context, commentID string, from, to int) (*model.RevisionDiff, error) {
+	cid, err := globalid.DecodeAs(commentID, globalid.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	comm, err := r.storage.GetComments(ctx, 0, 0, smodel.CommentSortDefault, cid)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.revisionDiff(ctx, smodel.Target{Type: smodel.TargetComment, ID: cid}, comm.Status.ToGraphQL(), comm.RemovedAt, from, to)
+}
+
+func (r *Resolver) revisionDiff(ctx context.Context, target smodel.Target, status model.ContentStatus, deletedAt *time.Time, from, to int) (*model.RevisionDiff, error) {
+	if !r.canViewRevisions(ctx, status, deletedAt) {
+		return nil, errRevisionsHidden
+	}
+
+	fromRevision, err := r.storage.GetRevision(ctx, target, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := r.storage.GetRevision(ctx, target, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.RevisionDiff{
+		From: fromRevision.ToGraphQL(),
+		To:   toRevision.ToGraphQL(),
+		Diff: diff.Unified(
+			fmt.Sprintf("revision %d", from),
+			fmt.Sprintf("revision %d", to),
+			revisionText(fromRevision),
+			revisionText(toRevision),
+		),
+	}, nil
+}
+
+// revisionText - текст версии для diff, у поста первая строка - заголовок
+func revisionText(revision *smodel.Revision) string {
+	if revision.TargetType == smodel.TargetPost {
+		return revision.Title + "\n\n" + revision.Content
+	}
+	return revision.Content
+}
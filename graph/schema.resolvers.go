@@ -7,15 +7,87 @@ package graph
 import (
 	"context"
 	"fmt"
-	"strconv"
 
 	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
 	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/moderation"
 )
 
+// Posts is the resolver for the posts field.
+func (r *boardResolver) Posts(ctx context.Context, obj *model.Board, limit *int, offset *int, sort *model.PostSort, tags []string, match *model.TagMatch) (*model.PostPage, error) {
+	bid, err := globalid.DecodeAs(obj.ID, globalid.Board)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.getPosts(ctx, limit, offset, sort, tags, match, &bid)
+}
+
+// Content is the resolver for the content field.
+func (r *commentResolver) Content(ctx context.Context, obj *model.Comment) (string, error) {
+	return r.visibleText(ctx, obj.Status, obj.DeletedAt, obj.Content), nil
+}
+
+// ViewerVote is the resolver for the viewerVote field.
+func (r *commentResolver) ViewerVote(ctx context.Context, obj *model.Comment) (model.VoteValue, error) {
+	return r.viewerVote(ctx, obj.ID)
+}
+
+// Reactions is the resolver for the reactions field.
+func (r *commentResolver) Reactions(ctx context.Context, obj *model.Comment) ([]*model.ReactionCount, error) {
+	return r.reactions(ctx, obj.ID)
+}
+
+// ViewerReactions is the resolver for the viewerReactions field.
+func (r *commentResolver) ViewerReactions(ctx context.Context, obj *model.Comment) ([]string, error) {
+	return r.viewerReactions(ctx, obj.ID)
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *commentResolver) Revisions(ctx context.Context, obj *model.Comment, first *int, after *string) (*model.RevisionConnection, error) {
+	cid, err := globalid.DecodeAs(obj.ID, globalid.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.revisions(ctx, smodel.Target{Type: smodel.TargetComment, ID: cid}, obj.Status, obj.DeletedAt, first, after)
+}
+
 // CreatePost is the resolver for the createPost field.
 func (r *mutationResolver) CreatePost(ctx context.Context, input model.CreatePostInput) (*model.Post, error) {
-	uid, err := strconv.ParseUint(input.UserID, 10, 32)
+	uid, err := globalid.DecodeAs(input.UserID, globalid.User)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := normalizeTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("too many tags, %d > %d", len(tags), maxTags)
+	}
+
+	var bid *uint
+	if input.BoardID != nil {
+		bidu, err := globalid.DecodeAs(*input.BoardID, globalid.Board)
+		if err != nil {
+			return nil, err
+		}
+		bid = &bidu
+	}
+
+	commentsEnabled, err := r.commentsEnabled(ctx, input.CommentsEnabled, bid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSlowMode(input.SlowModeSeconds); err != nil {
+		return nil, err
+	}
+
+	verdict, err := r.moderate(moderation.Item{Kind: moderation.KindPost, UserID: uid, Title: input.Title, Content: input.Content})
 	if err != nil {
 		return nil, err
 	}
@@ -23,60 +95,92 @@ func (r *mutationResolver) CreatePost(ctx context.Context, input model.CreatePos
 	newPost := smodel.CreatePost{
 		Title:           input.Title,
 		Content:         input.Content,
-		UserId:          uint(uid),
-		CommentsEnabled: input.CommentsEnabled,
+		UserId:          uid,
+		CommentsEnabled: commentsEnabled,
+		BoardId:         bid,
+		Tags:            tags,
+		SlowModeSeconds: input.SlowModeSeconds,
 	}
 
-	post, err := r.storage.CreatePost(newPost)
+	newPost.Idempotency, err = r.idempotency(input.IdempotencyKey, "createPost", newPost)
 	if err != nil {
 		return nil, err
 	}
 
+	post, err := r.storage.CreatePost(ctx, newPost)
+	if err != nil {
+		return nil, err
+	}
+
+	// повтор по ключу идемпотентности: пост уже обработан
+	if post.Replayed {
+		return post.ToGraphQL(), nil
+	}
+
+	r.enqueue(ctx, smodel.Target{Type: smodel.TargetPost, ID: post.ID}, verdict)
+
+	r.postCreated(ctx, post)
+
 	return post.ToGraphQL(), nil
 }
 
 // CreateComment is the resolver for the createComment field.
 func (r *mutationResolver) CreateComment(ctx context.Context, input model.CreateCommentInput) (*model.Comment, error) {
-	uid, err := strconv.ParseUint(input.UserID, 10, 32)
+	uid, err := globalid.DecodeAs(input.UserID, globalid.User)
 	if err != nil {
 		return nil, err
 	}
 
-	pid, err := strconv.ParseUint(input.PostID, 10, 32)
+	pid, err := globalid.DecodeAs(input.PostID, globalid.Post)
 	if err != nil {
 		return nil, err
 	}
 
 	var parid *uint
 	if input.ParentCommentID != nil {
-		parid64, err := strconv.ParseUint(*input.ParentCommentID, 10, 32)
+		paridu, err := globalid.DecodeAs(*input.ParentCommentID, globalid.Comment)
 		if err != nil {
 			return nil, err
 		}
-		paridu := uint(parid64)
 		parid = &paridu
 	}
 
 	// проверка на длину комментария
 	text := []rune(input.Content)
-	if len(text) > 2000 {
-		return nil, fmt.Errorf("very long comment, simvol lenght = %d > 2000", len(text))
+	if len(text) > maxCommentLength {
+		return nil, fmt.Errorf("very long comment, simvol lenght = %d > %d", len(text), maxCommentLength)
+	}
+
+	verdict, err := r.moderate(moderation.Item{Kind: moderation.KindComment, UserID: uid, Content: input.Content})
+	if err != nil {
+		return nil, err
 	}
 
 	newComment := smodel.CreateComment{
 		Content:  input.Content,
-		UserId:   uint(uid),
-		PostId:   uint(pid),
+		UserId:   uid,
+		PostId:   pid,
 		ParentId: parid,
 	}
 
-	comm, err := r.storage.CreateComment(newComment)
+	newComment.Idempotency, err = r.idempotency(input.IdempotencyKey, "createComment", newComment)
+	if err != nil {
+		return nil, err
+	}
+
+	comm, err := r.storage.CreateComment(ctx, newComment)
 	if err != nil {
 		return nil, err
 	}
 
-	// уведомление подписчиков о новом комментарии под постом
-	r.NotifySubscribers(input.PostID, comm.ToGraphQL())
+	// повтор по ключу идемпотентности: подписчики уже уведомлены
+	if comm.Replayed {
+		return comm.ToGraphQL(), nil
+	}
+
+	r.enqueue(ctx, smodel.Target{Type: smodel.TargetComment, ID: comm.ID}, verdict)
+
+	r.commentCreated(ctx, comm)
 
 	return comm.ToGraphQL(), nil
 }
@@ -87,7 +191,7 @@ func (r *mutationResolver) CreateUser(ctx context.Context, username string) (*mo
 		Username: username,
 	}
 
-	user, err := r.storage.CreateUser(newUser)
+	user, err := r.storage.CreateUser(ctx, newUser)
 	if err != nil {
 		return nil, err
 	}
@@ -95,60 +199,215 @@ func (r *mutationResolver) CreateUser(ctx context.Context, username string) (*mo
 	return user.ToGraphQL(), err
 }
 
-// GetPosts is the resolver for the getPosts field.
-func (r *queryResolver) GetPosts(ctx context.Context, limit *int, offset *int) (*model.PostPage, error) {
-	lim, off := setLimOff(limit, offset)
+// Register is the resolver for the register field.
+func (r *mutationResolver) Register(ctx context.Context, username string) (*model.Registration, error) {
+	user, err := r.storage.CreateUser(ctx, smodel.CreateUser{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Registration{
+		User:  user.ToGraphQL(),
+		Token: r.authenticator.Issue(user.ID),
+	}, nil
+}
 
-	badPostPage, err := r.storage.GetPosts(lim, off)
+// CreateBoard is the resolver for the createBoard field.
+func (r *mutationResolver) CreateBoard(ctx context.Context, input model.CreateBoardInput) (*model.Board, error) {
+	newBoard, err := newBoard(input)
 	if err != nil {
 		return nil, err
 	}
 
-	dirtyPosts := badPostPage.Posts
-	posts := make([]*model.Post, 0, len(dirtyPosts))
-	for _, dirtyPost := range dirtyPosts {
-		post := dirtyPost.ToGraphQL()
-		posts = append(posts, post)
+	board, err := r.storage.CreateBoard(ctx, newBoard)
+	if err != nil {
+		return nil, err
 	}
 
-	postPage := &model.PostPage{
-		Posts:      posts,
-		TotalCount: badPostPage.TotalCount,
+	return board.ToGraphQL(), nil
+}
+
+// React is the resolver for the react field.
+func (r *mutationResolver) React(ctx context.Context, input model.ReactionInput) (*model.ReactionEvent, error) {
+	return r.changeReaction(ctx, input, true)
+}
+
+// Unreact is the resolver for the unreact field.
+func (r *mutationResolver) Unreact(ctx context.Context, input model.ReactionInput) (*model.ReactionEvent, error) {
+	return r.changeReaction(ctx, input, false)
+}
+
+// Vote is the resolver for the vote field.
+func (r *mutationResolver) Vote(ctx context.Context, input model.VoteInput) (*model.VoteResult, error) {
+	return r.vote(ctx, input)
+}
+
+// ReportPost is the resolver for the reportPost field.
+func (r *mutationResolver) ReportPost(ctx context.Context, input model.ReportInput) (*model.Report, error) {
+	return r.report(ctx, input, globalid.Post)
+}
+
+// ReportComment is the resolver for the reportComment field.
+func (r *mutationResolver) ReportComment(ctx context.Context, input model.ReportInput) (*model.Report, error) {
+	return r.report(ctx, input, globalid.Comment)
+}
+
+// ResolveReport is the resolver for the resolveReport field.
+func (r *mutationResolver) ResolveReport(ctx context.Context, input model.ResolveReportInput) (*model.Report, error) {
+	return r.resolveReport(ctx, input)
+}
+
+// SetSlowMode is the resolver for the setSlowMode field.
+func (r *mutationResolver) SetSlowMode(ctx context.Context, input model.SlowModeInput) (*model.Post, error) {
+	return r.setSlowMode(ctx, input)
+}
+
+// MarkNotificationsRead is the resolver for the markNotificationsRead field.
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, ids []string) (int, error) {
+	return r.markNotificationsRead(ctx, ids)
+}
+
+// CreateWebhook is the resolver for the createWebhook field.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, input model.CreateWebhookInput) (*model.Webhook, error) {
+	return r.createWebhook(ctx, input)
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook field.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	return r.deleteWebhook(ctx, id)
+}
+
+// UpdatePost is the resolver for the updatePost field.
+func (r *mutationResolver) UpdatePost(ctx context.Context, input model.UpdatePostInput) (*model.Post, error) {
+	return r.updatePost(ctx, input)
+}
+
+// UpdateComment is the resolver for the updateComment field.
+func (r *mutationResolver) UpdateComment(ctx context.Context, input model.UpdateCommentInput) (*model.Comment, error) {
+	return r.updateComment(ctx, input)
+}
+
+// DeletePost is the resolver for the deletePost field.
+func (r *mutationResolver) DeletePost(ctx context.Context, id string) (bool, error) {
+	return r.deleteTarget(ctx, id, globalid.Post)
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string) (bool, error) {
+	return r.deleteTarget(ctx, id, globalid.Comment)
+}
+
+// RestorePost is the resolver for the restorePost field.
+func (r *mutationResolver) RestorePost(ctx context.Context, id string) (bool, error) {
+	return r.restoreTarget(ctx, id, globalid.Post)
+}
+
+// RestoreComment is the resolver for the restoreComment field.
+func (r *mutationResolver) RestoreComment(ctx context.Context, id string) (bool, error) {
+	return r.restoreTarget(ctx, id, globalid.Comment)
+}
+
+// DeleteMyAccount is the resolver for the deleteMyAccount field.
+func (r *mutationResolver) DeleteMyAccount(ctx context.Context, mode model.DeleteAccountMode) (*model.User, error) {
+	return r.deleteMyAccount(ctx, mode)
+}
+
+// Title is the resolver for the title field.
+func (r *postResolver) Title(ctx context.Context, obj *model.Post) (string, error) {
+	return r.visibleText(ctx, obj.Status, obj.DeletedAt, obj.Title), nil
+}
+
+// Content is the resolver for the content field.
+func (r *postResolver) Content(ctx context.Context, obj *model.Post) (string, error) {
+	return r.visibleText(ctx, obj.Status, obj.DeletedAt, obj.Content), nil
+}
+
+// ViewerVote is the resolver for the viewerVote field.
+func (r *postResolver) ViewerVote(ctx context.Context, obj *model.Post) (model.VoteValue, error) {
+	return r.viewerVote(ctx, obj.ID)
+}
+
+// Reactions is the resolver for the reactions field.
+func (r *postResolver) Reactions(ctx context.Context, obj *model.Post) ([]*model.ReactionCount, error) {
+	return r.reactions(ctx, obj.ID)
+}
+
+// ViewerReactions is the resolver for the viewerReactions field.
+func (r *postResolver) ViewerReactions(ctx context.Context, obj *model.Post) ([]string, error) {
+	return r.viewerReactions(ctx, obj.ID)
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post, first *int, after *string) (*model.RevisionConnection, error) {
+	pid, err := globalid.DecodeAs(obj.ID, globalid.Post)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.revisions(ctx, smodel.Target{Type: smodel.TargetPost, ID: pid}, obj.Status, obj.DeletedAt, first, after)
+}
+
+// GetPosts is the resolver for the getPosts field.
+func (r *queryResolver) GetPosts(ctx context.Context, limit *int, offset *int, sort *model.PostSort, tags []string, match *model.TagMatch) (*model.PostPage, error) {
+	return r.getPosts(ctx, limit, offset, sort, tags, match, nil)
+}
+
+// Tags is the resolver for the tags field.
+func (r *queryResolver) Tags(ctx context.Context, limit *int, offset *int) ([]*model.Tag, error) {
+	lim, off := setLimOff(limit, offset)
+
+	tagCounts, err := r.storage.GetTags(ctx, lim, off)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]*model.Tag, 0, len(tagCounts))
+	for _, tag := range tagCounts {
+		tags = append(tags, &model.Tag{
+			Name:      tag.Name,
+			PostCount: tag.PostCount,
+		})
 	}
 
-	return postPage, nil
+	return tags, nil
 }
 
 // GetPost is the resolver for the getPost field.
-func (r *queryResolver) GetPost(ctx context.Context, id string, limit *int, offset *int) (*model.Post, error) {
+func (r *queryResolver) GetPost(ctx context.Context, id string, limit *int, offset *int, sort *model.CommentSort) (*model.Post, error) {
 	lim, off := setLimOff(limit, offset)
 
-	pid, err := strconv.ParseUint(id, 10, 32)
+	pid, err := globalid.DecodeAs(id, globalid.Post)
 	if err != nil {
 		return nil, err
 	}
 
-	post, err := r.storage.GetPost(lim, off, uint(pid))
+	post, err := r.storage.GetPost(ctx, lim, off, commentSort(sort), pid)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.checkRemoved(ctx, smodel.Target{Type: smodel.TargetPost, ID: pid}, post.RemovedAt); err != nil {
+		return nil, err
+	}
 
 	return post.ToGraphQL(), nil
 }
 
 // GetComments is the resolver for the getComments field.
-func (r *queryResolver) GetComments(ctx context.Context, commID string, limit *int, offset *int) (*model.Comment, error) {
+func (r *queryResolver) GetComments(ctx context.Context, commID string, limit *int, offset *int, sort *model.CommentSort) (*model.Comment, error) {
 	lim, off := setLimOff(limit, offset)
 
-	cid, err := strconv.ParseUint(commID, 10, 32)
+	cid, err := globalid.DecodeAs(commID, globalid.Comment)
 	if err != nil {
 		return nil, err
 	}
 
-	comm, err := r.storage.GetComments(lim, off, uint(cid))
+	comm, err := r.storage.GetComments(ctx, lim, off, commentSort(sort), cid)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.checkCommentRemoved(ctx, comm); err != nil {
+		return nil, err
+	}
 
 	/*dirtyComms := dirtyComm.Replies.Comms
 	comms := make([]*model.Comment, 0)
@@ -160,37 +419,311 @@ func (r *queryResolver) GetComments(ctx context.Context, commID string, limit *i
 	return comm.ToGraphQL(), nil
 }
 
+// Node is the resolver for the node field.
+func (r *queryResolver) Node(ctx context.Context, id string) (model.Node, error) {
+	return r.node(ctx, id)
+}
+
+// Nodes is the resolver for the nodes field.
+func (r *queryResolver) Nodes(ctx context.Context, ids []string) ([]model.Node, error) {
+	return r.nodes(ctx, ids)
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, id string) (*model.User, error) {
+	uid, err := globalid.DecodeAs(id, globalid.User)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := r.storage.GetUser(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.ToGraphQL(), nil
+}
+
+// UserByUsername is the resolver for the userByUsername field.
+func (r *queryResolver) UserByUsername(ctx context.Context, username string) (*model.User, error) {
+	user, err := r.storage.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.ToGraphQL(), nil
+}
+
+// Board is the resolver for the board field.
+func (r *queryResolver) Board(ctx context.Context, id string) (*model.Board, error) {
+	bid, err := globalid.DecodeAs(id, globalid.Board)
+	if err != nil {
+		return nil, err
+	}
+
+	board, err := r.storage.GetBoard(ctx, bid)
+	if err != nil {
+		return nil, err
+	}
+
+	return board.ToGraphQL(), nil
+}
+
+// Boards is the resolver for the boards field.
+func (r *queryResolver) Boards(ctx context.Context, limit *int, offset *int) (*model.BoardPage, error) {
+	lim, off := setLimOff(limit, offset)
+
+	boardPage, err := r.storage.GetBoards(ctx, lim, off)
+	if err != nil {
+		return nil, err
+	}
+
+	boards := make([]*model.Board, 0, len(boardPage.Boards))
+	for _, board := range boardPage.Boards {
+		boards = append(boards, board.ToGraphQL())
+	}
+
+	return &model.BoardPage{
+		Boards:     boards,
+		TotalCount: boardPage.TotalCount,
+	}, nil
+}
+
+// Search is the resolver for the search field.
+func (r *queryResolver) Search(ctx context.Context, query string, typeArg *model.SearchType, first *int, after *string, filter *model.SearchFilter) (*model.SearchConnection, error) {
+	lim, off, err := pageAfter(first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	q := smodel.Search{
+		Query:  query,
+		Limit:  lim,
+		Offset: off,
+	}
+	// значения enum совпадают со значениями smodel.SearchType
+	if typeArg != nil {
+		q.Type = smodel.SearchType(*typeArg)
+	}
+	if filter != nil {
+		if filter.AuthorID != nil {
+			uid, err := globalid.DecodeAs(*filter.AuthorID, globalid.User)
+			if err != nil {
+				return nil, err
+			}
+			q.AuthorId = &uid
+		}
+		q.From = filter.From
+		q.To = filter.To
+	}
+
+	page, err := r.storage.Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make([]*model.SearchEdge, 0, len(page.Hits))
+	for i, hit := range page.Hits {
+		edge := &model.SearchEdge{
+			Cursor:  encodeCursor(off + i),
+			Rank:    hit.Rank,
+			Snippet: hit.Snippet,
+		}
+		if hit.Post != nil {
+			edge.Node = hit.Post.ToGraphQL()
+		} else {
+			edge.Node = hit.Comment.ToGraphQL()
+		}
+		edges = append(edges, edge)
+	}
+
+	pageInfo := &model.PageInfo{
+		HasNextPage: off+len(edges) < page.TotalCount,
+	}
+	if len(edges) > 0 {
+		pageInfo.EndCursor = &edges[len(edges)-1].Cursor
+	}
+
+	return &model.SearchConnection{
+		Edges:      edges,
+		PageInfo:   pageInfo,
+		TotalCount: page.TotalCount,
+	}, nil
+}
+
+// Reactions is the resolver for the reactions field.
+func (r *queryResolver) Reactions(ctx context.Context) ([]string, error) {
+	return r.reactionList, nil
+}
+
+// ModerationQueue is the resolver for the moderationQueue field.
+func (r *queryResolver) ModerationQueue(ctx context.Context, status *model.ReportStatus, limit *int, offset *int) (*model.ReportPage, error) {
+	return r.moderationQueue(ctx, status, limit, offset)
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *queryResolver) Notifications(ctx context.Context, first *int, after *string, unreadOnly bool) (*model.NotificationConnection, error) {
+	return r.notifications(ctx, first, after, unreadOnly)
+}
+
+// Webhooks is the resolver for the webhooks field.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	return r.webhookList(ctx)
+}
+
+// WebhookDeliveries is the resolver for the webhookDeliveries field.
+func (r *queryResolver) WebhookDeliveries(ctx context.Context, webhookID *string, status *model.WebhookDeliveryStatus, limit *int, offset *int) (*model.WebhookDeliveryPage, error) {
+	return r.webhookDeliveries(ctx, webhookID, status, limit, offset)
+}
+
+// RevisionDiff is the resolver for the revisionDiff field.
+func (r *queryResolver) RevisionDiff(ctx context.Context, postID string, from int, to int) (*model.RevisionDiff, error) {
+	return r.postRevisionDiff(ctx, postID, from, to)
+}
+
+// CommentRevisionDiff is the resolver for the commentRevisionDiff field.
+func (r *queryResolver) CommentRevisionDiff(ctx context.Context, commentID string, from int, to int) (*model.RevisionDiff, error) {
+	return r.commentRevisionDiff(ctx, commentID, from, to)
+}
+
+// ExportMyData is the resolver for the exportMyData field.
+func (r *queryResolver) ExportMyData(ctx context.Context) (string, error) {
+	return r.exportMyData(ctx)
+}
+
 // CommentAdded is the resolver for the commentAdded field.
 func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string) (<-chan *model.Comment, error) {
+	pid, err := globalid.DecodeAs(postID, globalid.Post)
+	if err != nil {
+		return nil, err
+	}
+
 	comments := make(chan *model.Comment, 1)
 
 	r.mu.Lock()
-	r.subscribers[postID] = comments
+	r.subscribers[pid] = append(r.subscribers[pid], comments)
 	r.mu.Unlock()
 
 	// когда контекст завершится, то произойдёт удаление подписки к посту
 	go func() {
 		<-ctx.Done()
 		r.mu.Lock()
-		delete(r.subscribers, postID)
-		r.mu.Unlock()
+		defer r.mu.Unlock()
+
+		subscribers := r.subscribers[pid]
+		for i, subscriber := range subscribers {
+			if subscriber == comments {
+				subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+				break
+			}
+		}
+		if len(subscribers) == 0 {
+			delete(r.subscribers, pid)
+		} else {
+			r.subscribers[pid] = subscribers
+		}
 	}()
 
 	return comments, nil
 }
 
+// ReactionChanged is the resolver for the reactionChanged field.
+func (r *subscriptionResolver) ReactionChanged(ctx context.Context, postID string) (<-chan *model.ReactionEvent, error) {
+	pid, err := globalid.DecodeAs(postID, globalid.Post)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.reactionChanged(ctx, pid), nil
+}
+
+// NotificationAdded is the resolver for the notificationAdded field.
+func (r *subscriptionResolver) NotificationAdded(ctx context.Context) (<-chan *model.Notification, error) {
+	return r.notificationAdded(ctx)
+}
+
+// Posts is the resolver for the posts field.
+func (r *userResolver) Posts(ctx context.Context, obj *model.User, limit *int, offset *int) (*model.PostPage, error) {
+	lim, off := setLimOff(limit, offset)
+
+	uid, err := globalid.DecodeAs(obj.ID, globalid.User)
+	if err != nil {
+		return nil, err
+	}
+
+	// удалённые посты видят только модераторы
+	_, withDeleted := r.moderator(ctx)
+	badPostPage, err := r.storage.GetUserPosts(ctx, lim, off, uid, withDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]*model.Post, 0, len(badPostPage.Posts))
+	for _, dirtyPost := range badPostPage.Posts {
+		posts = append(posts, dirtyPost.ToGraphQL())
+	}
+
+	return &model.PostPage{
+		Posts:      posts,
+		TotalCount: badPostPage.TotalCount,
+	}, nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *userResolver) Comments(ctx context.Context, obj *model.User, limit *int, offset *int) (*model.CommPage, error) {
+	lim, off := setLimOff(limit, offset)
+
+	uid, err := globalid.DecodeAs(obj.ID, globalid.User)
+	if err != nil {
+		return nil, err
+	}
+
+	// скрытые и удалённые комментарии и комментарии под удалёнными постами видят только модераторы
+	_, withDeleted := r.moderator(ctx)
+	badCommPage, err := r.storage.GetUserComments(ctx, lim, off, uid, withDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	comms := make([]*model.Comment, 0, len(badCommPage.Comms))
+	for _, dirtyComm := range badCommPage.Comms {
+		comms = append(comms, dirtyComm.ToGraphQL())
+	}
+
+	return &model.CommPage{
+		Comments:   comms,
+		TotalCount: badCommPage.TotalCount,
+	}, nil
+}
+
+// Board returns BoardResolver implementation.
+func (r *Resolver) Board() BoardResolver { return &boardResolver{r} }
+
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Post returns PostResolver implementation.
+func (r *Resolver) Post() PostResolver { return &postResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
 // Subscription returns SubscriptionResolver implementation.
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type boardResolver struct{ *Resolver }
+type commentResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type postResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
 
 // !!! WARNING !!!
 // The code below was going to be deleted when updating resolvers. It has been copied here so you have
@@ -198,12 +731,18 @@ type subscriptionResolver struct{ *Resolver }
 //   - When renaming or deleting a resolver the old code will be put in here. You can safely delete
 //     it when you're done.
 //   - You have helper methods in this file. Move them out to keep these resolver files clean.
-func (r *Resolver) NotifySubscribers(postId string, comment *model.Comment) {
+// NotifySubscribers отправляет комментарий всем подписчикам поста.
+// Отправка не блокирует relay outbox: если подписчик не успевает читать,
+// комментарий ему не доставляется
+func (r *Resolver) NotifySubscribers(postId uint, comment *model.Comment) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	if subscriber, ok := r.subscribers[postId]; ok {
-		subscriber <- comment
+	for _, subscriber := range r.subscribers[postId] {
+		select {
+		case subscriber <- comment:
+		default:
+		}
 	}
 }
 func setLimOff(limit, offset *int) (int, int) {
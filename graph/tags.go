@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// сколько тегов можно указать у поста
+	maxTags = 10
+	// длина имени тега в символах
+	maxTagLength = 50
+)
+
+// normalizeTags приводит имена тегов к нижнему регистру без пробелов
+// по краям и убирает повторы
+func normalizeTags(tags []string) ([]string, error) {
+	res := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			return nil, fmt.Errorf("empty tag name")
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("very long tag %q, simvol lenght > %d", tag, maxTagLength)
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			res = append(res, tag)
+		}
+	}
+
+	return res, nil
+}
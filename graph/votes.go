@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/auth"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+var errNoVoter = errors.New("voting is available only to the authenticated user, pass their token in " + auth.Header)
+
+// значения голоса в хранилище
+var voteValues = map[model.VoteValue]int{
+	model.VoteValueUp:   1,
+	model.VoteValueDown: -1,
+	model.VoteValueNone: 0,
+}
+
+func voteValue(value int) model.VoteValue {
+	switch value {
+	case 1:
+		return model.VoteValueUp
+	case -1:
+		return model.VoteValueDown
+	}
+	return model.VoteValueNone
+}
+
+// commentSort переводит порядок комментариев, значения enum совпадают
+// со значениями smodel.CommentSort
+func commentSort(sort *model.CommentSort) smodel.CommentSort {
+	if sort == nil {
+		return smodel.CommentSortDefault
+	}
+	return smodel.CommentSort(*sort)
+}
+
+// vote меняет голос пользователя запроса за пост или комментарий
+func (r *Resolver) vote(ctx context.Context, input model.VoteInput) (*model.VoteResult, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return nil, errNoVoter
+	}
+
+	target, err := parseTarget(input.TargetID)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := voteValues[input.Value]
+	if !ok {
+		return nil, fmt.Errorf("unknown vote value %q", input.Value)
+	}
+
+	summary, err := r.storage.Vote(ctx, smodel.CastVote{
+		UserId: viewer,
+		Target: target,
+		Value:  value,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.VoteResult{
+		TargetID:  input.TargetID,
+		Value:     input.Value,
+		Score:     summary.Score(),
+		Upvotes:   summary.Upvotes,
+		Downvotes: summary.Downvotes,
+	}, nil
+}
+
+// viewerVote возвращает голос пользователя запроса, для анонимного запроса - NONE
+func (r *Resolver) viewerVote(ctx context.Context, id string) (model.VoteValue, error) {
+	viewer, ok := auth.Viewer(ctx)
+	if !ok {
+		return model.VoteValueNone, nil
+	}
+
+	target, err := parseTarget(id)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := r.storage.GetUserVote(ctx, viewer, target)
+	if err != nil {
+		return "", err
+	}
+
+	return voteValue(value), nil
+}
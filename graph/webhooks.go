@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	smodel "github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+// ограничения регистрации вебхука
+const (
+	maxWebhookURLLength    = 2000
+	maxWebhookSecretLength = 256
+)
+
+// publish отправляет событие вебхукам. Запись уже создана,
+// поэтому ошибка только пишется в лог
+func (r *Resolver) publish(ctx context.Context, event smodel.WebhookEvent, userId uint, data interface{}) {
+	if r.webhooks == nil {
+		return
+	}
+
+	if err := r.webhooks.Publish(ctx, event, userId, data); err != nil {
+		logrus.Errorf("failed publish %s to webhooks: %s", event, err.Error())
+	}
+}
+
+func (r *Resolver) createWebhook(ctx context.Context, input model.CreateWebhookInput) (*model.Webhook, error) {
+	if _, ok := r.moderator(ctx); !ok {
+		return nil, errNotModerator
+	}
+
+	if len(input.URL) > maxWebhookURLLength {
+		return nil, fmt.Errorf("webhook url is longer than %d bytes", maxWebhookURLLength)
+	}
+	checkURL := webhook.CheckURL
+	if r.webhooks != nil {
+		checkURL = r.webhooks.CheckURL
+	}
+	if err := checkURL(ctx, input.URL); err != nil {
+		return nil, err
+	}
+
+	if input.Secret == "" || len(input.Secret) > maxWebhookSecretLength {
+		return nil, fmt.Errorf("webhook secret must be from 1 to %d bytes", maxWebhookSecretLength)
+	}
+
+	events := make([]smodel.WebhookEvent, 0, len(input.Events))
+	seen := make(map[model.WebhookEvent]bool)
+	for _, event := range input.Events {
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, smodel.WebhookEvent(event))
+		}
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("webhook must have at least one event")
+	}
+
+	webhook, err := r.storage.CreateWebhook(ctx, smodel.CreateWebhook{
+		URL:    input.URL,
+		Events: events,
+		Secret: input.Secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhook.ToGraphQL(), nil
+}
+
+func (r *Resolver) deleteWebhook(ctx context.Context, id string) (bool, error) {
+	if _, ok := r.moderator(ctx); !ok {
+		return false, errNotModerator
+	}
+
+	wid, err := globalid.DecodeAs(id, globalid.Webhook)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.storage.DeleteWebhook(ctx, wid); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *Resolver) webhookList(ctx context.Context) ([]*model.Webhook, error) {
+	if _, ok := r.moderator(ctx); !ok {
+		return nil, errNotModerator
+	}
+
+	webhooks, err := r.storage.GetWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*model.Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		res = append(res, webhook.ToGraphQL())
+	}
+
+	return res, nil
+}
+
+func (r *Resolver) webhookDeliveries(ctx context.Context, webhookID *string, status *model.WebhookDeliveryStatus, limit, offset *int) (*model.WebhookDeliveryPage, error) {
+	if _, ok := r.moderator(ctx); !ok {
+		return nil, errNotModerator
+	}
+
+	var filter smodel.DeliveryFilter
+	if webhookID != nil {
+		wid, err := globalid.DecodeAs(*webhookID, globalid.Webhook)
+		if err != nil {
+			return nil, err
+		}
+		filter.WebhookId = &wid
+	}
+	if status != nil {
+		deliveryStatus := smodel.DeliveryStatus(*status)
+		filter.Status = &deliveryStatus
+	}
+	lim, off := setLimOff(limit, offset)
+
+	page, err := r.storage.GetWebhookDeliveries(ctx, lim, off, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*model.WebhookDelivery, 0, len(page.Deliveries))
+	for _, delivery := range page.Deliveries {
+		deliveries = append(deliveries, delivery.ToGraphQL())
+	}
+
+	return &model.WebhookDeliveryPage{
+		Deliveries: deliveries,
+		TotalCount: page.TotalCount,
+	}, nil
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+)
+
+// Пользователь, от имени которого выполняется запрос (viewer).
+// Клиент передаёт токен, подписанный секретом сервера, в заголовке
+// Authorization: Bearer <токен>, а для websocket - в поле authToken
+// параметров connection_init. Токен содержит глобальный id пользователя
+// и время, до которого он действует, поэтому назваться другим пользователем
+// без секрета нельзя. Без токена запрос выполняется анонимно
+
+// Header - заголовок с токеном пользователя
+const Header = "Authorization"
+
+// схема токена в заголовке Authorization
+const bearer = "Bearer "
+
+// поле параметров connection_init с токеном пользователя
+const initPayloadKey = "authToken"
+
+// DefaultTokenTTL - сколько действует токен по умолчанию
+const DefaultTokenTTL = 30 * 24 * time.Hour
+
+var (
+	errMalformedToken = errors.New("malformed token")
+	errInvalidToken   = errors.New("invalid token signature")
+	errExpiredToken   = errors.New("token expired")
+)
+
+// Authenticator выдаёт и проверяет токены пользователей
+type Authenticator struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewAuthenticator создаёт Authenticator с секретом secret, токены действуют ttl
+func NewAuthenticator(secret []byte, ttl time.Duration) *Authenticator {
+	return &Authenticator{secret: secret, ttl: ttl}
+}
+
+// RandomSecret возвращает случайный секрет. Токены, подписанные им,
+// перестают действовать после перезапуска
+func RandomSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Issue возвращает токен пользователя id вида <глобальный id>.<срок>.<подпись>
+func (a *Authenticator) Issue(id uint) string {
+	payload := globalid.Encode(globalid.User, id) + "." + strconv.FormatInt(time.Now().Add(a.ttl).Unix(), 10)
+	return payload + "." + a.sign(payload)
+}
+
+// Verify проверяет подпись и срок токена и возвращает пользователя
+func (a *Authenticator) Verify(token string) (uint, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return 0, errMalformedToken
+	}
+	payload, sig := token[:i], token[i+1:]
+
+	if !hmac.Equal([]byte(sig), []byte(a.sign(payload))) {
+		return 0, errInvalidToken
+	}
+
+	gid, rawExpires, ok := strings.Cut(payload, ".")
+	if !ok {
+		return 0, errMalformedToken
+	}
+	expires, err := strconv.ParseInt(rawExpires, 10, 64)
+	if err != nil {
+		return 0, errMalformedToken
+	}
+	if time.Now().Unix() >= expires {
+		return 0, errExpiredToken
+	}
+
+	return globalid.DecodeAs(gid, globalid.User)
+}
+
+func (a *Authenticator) sign(payload string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type viewerKey struct{}
+
+// WithViewer возвращает контекст с пользователем id
+func WithViewer(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, viewerKey{}, id)
+}
+
+// Viewer возвращает пользователя запроса, false - запрос анонимный
+func Viewer(ctx context.Context) (uint, bool) {
+	id, ok := ctx.Value(viewerKey{}).(uint)
+	return id, ok
+}
+
+// Middleware добавляет в контекст пользователя из токена в заголовке Authorization
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(Header)
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, bearer)
+		if !ok {
+			http.Error(w, "invalid "+Header+": expected "+bearer+"token", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := a.Verify(token)
+		if err != nil {
+			http.Error(w, "invalid "+Header+": "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithViewer(r.Context(), id)))
+	})
+}
+
+// WebsocketInit добавляет в контекст подписки пользователя из токена в параметрах connection_init
+func (a *Authenticator) WebsocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	token := payload.GetString(initPayloadKey)
+	if token == "" {
+		return ctx, nil, nil
+	}
+
+	id, err := a.Verify(token)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	return WithViewer(ctx, id), nil, nil
+}
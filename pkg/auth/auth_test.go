@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	a := NewAuthenticator([]byte("secret"), time.Hour)
+
+	token := a.Issue(7)
+	if id, err := a.Verify(token); err != nil || id != 7 {
+		t.Fatal("expected user 7, got", id, err)
+	}
+
+	// подпись другим секретом
+	if _, err := NewAuthenticator([]byte("other"), time.Hour).Verify(token); err != errInvalidToken {
+		t.Error("expected invalid signature with other secret, got", err)
+	}
+
+	// чужой id с подписью токена пользователя 7
+	forged := NewAuthenticator([]byte("secret"), time.Hour).Issue(8)
+	forged = forged[:strings.LastIndexByte(forged, '.')] + token[strings.LastIndexByte(token, '.'):]
+	if _, err := a.Verify(forged); err != errInvalidToken {
+		t.Error("expected invalid signature for forged id, got", err)
+	}
+
+	expired := NewAuthenticator([]byte("secret"), -time.Second)
+	if _, err := expired.Verify(expired.Issue(7)); err != errExpiredToken {
+		t.Error("expected expired token, got", err)
+	}
+
+	for _, bad := range []string{"", "token", "a.b", "a.b.c"} {
+		if _, err := a.Verify(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a := NewAuthenticator([]byte("secret"), time.Hour)
+
+	var viewer uint
+	var authenticated bool
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		viewer, authenticated = Viewer(r.Context())
+	}))
+
+	serve := func(header string) int {
+		viewer, authenticated = 0, false
+		req := httptest.NewRequest(http.MethodPost, "/query", nil)
+		if header != "" {
+			req.Header.Set(Header, header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(""); code != http.StatusOK || authenticated {
+		t.Error("expected anonymous request, got", code, viewer)
+	}
+	if code := serve("Bearer " + a.Issue(3)); code != http.StatusOK || !authenticated || viewer != 3 {
+		t.Error("expected user 3, got", code, viewer, authenticated)
+	}
+	// глобальный id без подписи, как раньше в X-User-Id
+	if code := serve("Bearer VXNlcjoz"); code != http.StatusUnauthorized || authenticated {
+		t.Error("expected 401 for unsigned id, got", code)
+	}
+	if code := serve(a.Issue(3)); code != http.StatusUnauthorized {
+		t.Error("expected 401 without Bearer, got", code)
+	}
+}
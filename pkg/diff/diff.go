@@ -0,0 +1,217 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Построчный diff двух текстов в формате unified, как у diff -u и git diff.
+// Общие строки в начале и в конце отбрасываются сразу, а для остальных
+// ищется наибольшая общая подпоследовательность строк
+
+// сколько неизменённых строк показывается вокруг изменений
+const contextLines = 3
+
+// наибольший размер таблицы поиска общей подпоследовательности. Для текстов
+// больше этого изменённая часть показывается целиком удалённой и добавленной
+const maxCells = 4 << 20
+
+// вид строки diff
+const (
+	same    = ' '
+	removed = '-'
+	added   = '+'
+)
+
+type line struct {
+	kind byte
+	text string
+}
+
+// Unified возвращает diff текстов from и to с заголовками fromName и toName.
+// Для одинаковых текстов возвращает пустую строку
+func Unified(fromName, toName, from, to string) string {
+	lines := compare(split(from), split(to))
+
+	var b strings.Builder
+	for _, h := range hunks(lines) {
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		b.WriteString(h)
+	}
+
+	return b.String()
+}
+
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// compare возвращает строки обоих текстов по порядку с видом изменения
+func compare(a, b []string) []line {
+	var lines []line
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, text := range a[:prefix] {
+		lines = append(lines, line{same, text})
+	}
+	lines = append(lines, lcs(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, line{same, text})
+	}
+
+	return lines
+}
+
+// lcs сравнивает строки через таблицу длин наибольших общих подпоследовательностей
+// суффиксов. Удалённые строки идут перед добавленными на их месте
+func lcs(a, b []string) []line {
+	lines := make([]line, 0, len(a)+len(b))
+
+	if len(a) == 0 || len(b) == 0 || (len(a)+1)*(len(b)+1) > maxCells {
+		for _, text := range a {
+			lines = append(lines, line{removed, text})
+		}
+		for _, text := range b {
+			lines = append(lines, line{added, text})
+		}
+		return lines
+	}
+
+	// lengths[i][j] - длина общей подпоследовательности a[i:] и b[j:]
+	width := len(b) + 1
+	lengths := make([]int32, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i*width+j] = lengths[(i+1)*width+j+1] + 1
+			} else if lengths[(i+1)*width+j] >= lengths[i*width+j+1] {
+				lengths[i*width+j] = lengths[(i+1)*width+j]
+			} else {
+				lengths[i*width+j] = lengths[i*width+j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, line{same, a[i]})
+			i++
+			j++
+		case lengths[(i+1)*width+j] >= lengths[i*width+j+1]:
+			lines = append(lines, line{removed, a[i]})
+			i++
+		default:
+			lines = append(lines, line{added, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, line{removed, a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, line{added, b[j]})
+	}
+
+	return lines
+}
+
+// hunks группирует изменения с contextLines строками вокруг. Изменения,
+// между которыми не больше 2*contextLines общих строк, попадают в один блок
+func hunks(lines []line) []string {
+	var res []string
+
+	for start := 0; start < len(lines); {
+		// первое изменение
+		first := start
+		for first < len(lines) && lines[first].kind == same {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// конец блока: после последнего изменения идёт больше 2*contextLines общих строк
+		end := first
+		for end < len(lines) {
+			next := end + 1
+			for next < len(lines) && lines[next].kind == same {
+				next++
+			}
+			if next == len(lines) || next-end-1 > 2*contextLines {
+				break
+			}
+			end = next
+		}
+
+		from := first - contextLines
+		if from < start {
+			from = start
+		}
+		to := end + 1 + contextLines
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		res = append(res, hunk(lines, from, to))
+		start = to
+	}
+
+	return res
+}
+
+// hunk возвращает блок строк lines[from:to] с заголовком @@
+func hunk(lines []line, from, to int) string {
+	// номера строк в обоих текстах перед блоком
+	aLine, bLine := 0, 0
+	for _, l := range lines[:from] {
+		if l.kind != added {
+			aLine++
+		}
+		if l.kind != removed {
+			bLine++
+		}
+	}
+
+	var body strings.Builder
+	aLen, bLen := 0, 0
+	for _, l := range lines[from:to] {
+		if l.kind != added {
+			aLen++
+		}
+		if l.kind != removed {
+			bLen++
+		}
+		body.WriteByte(l.kind)
+		body.WriteString(l.text)
+		body.WriteByte('\n')
+	}
+
+	return fmt.Sprintf("@@ -%s +%s @@\n", span(aLine, aLen), span(bLine, bLen)) + body.String()
+}
+
+// span - диапазон строк в заголовке блока: первая строка и количество.
+// Для пустого диапазона указывается строка перед ним, количество 1 не пишется
+func span(before, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, length)
+}
@@ -0,0 +1,38 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	if got := Unified("a", "b", "same\ntext", "same\ntext"); got != "" {
+		t.Error("expected empty diff for equal texts, got", got)
+	}
+
+	got := Unified("v1", "v2", "one\ntwo\nthree", "one\n2\nthree\nfour")
+	want := "--- v1\n+++ v2\n@@ -1,3 +1,4 @@\n one\n-two\n+2\n three\n+four\n"
+	if got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+
+	// пустой текст
+	if got, want := Unified("v1", "v2", "", "line"), "--- v1\n+++ v2\n@@ -0,0 +1 @@\n+line\n"; got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+
+	// далёкие изменения попадают в разные блоки с 3 строками контекста
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, strings.Repeat("x", i+1))
+	}
+	changed := append([]string{}, lines...)
+	changed[1], changed[18] = "first", "last"
+	got = Unified("v1", "v2", strings.Join(lines, "\n"), strings.Join(changed, "\n"))
+	if strings.Count(got, "@@ -") != 2 {
+		t.Fatal("expected 2 hunks, got\n" + got)
+	}
+	if !strings.Contains(got, "@@ -1,5 +1,5 @@\n x\n-xx\n+first\n xxx\n") || !strings.Contains(got, "@@ -16,5 +16,5 @@\n") {
+		t.Error("unexpected hunks\n" + got)
+	}
+}
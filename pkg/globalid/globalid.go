@@ -0,0 +1,65 @@
+package globalid
+
+// Глобальные id для GraphQL: тип сущности и её id в хранилище,
+// закодированные в base64, например base64("Post:12").
+// По такому id однозначно понятно, к какой сущности он относится,
+// поэтому id поста и комментария с одинаковым номером не совпадают
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// типы сущностей
+const (
+	User    = "User"
+	Post    = "Post"
+	Comment = "Comment"
+	Board   = "Board"
+	Report  = "Report"
+
+	Notification    = "Notification"
+	Webhook         = "Webhook"
+	WebhookDelivery = "WebhookDelivery"
+)
+
+// Encode возвращает глобальный id сущности typ с id в хранилище
+func Encode(typ string, id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(typ + ":" + strconv.FormatUint(uint64(id), 10)))
+}
+
+// Decode возвращает тип сущности и её id в хранилище
+func Decode(gid string) (string, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(gid)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid id %q", gid)
+	}
+
+	typ, rawId, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return "", 0, fmt.Errorf("invalid id %q", gid)
+	}
+
+	id, err := strconv.ParseUint(rawId, 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid id %q", gid)
+	}
+
+	return typ, uint(id), nil
+}
+
+// DecodeAs возвращает id в хранилище, если gid - id сущности типа typ
+func DecodeAs(gid, typ string) (uint, error) {
+	gotTyp, id, err := Decode(gid)
+	if err != nil {
+		return 0, err
+	}
+
+	if gotTyp != typ {
+		return 0, fmt.Errorf("id %q is %s id, expected %s id", gid, gotTyp, typ)
+	}
+
+	return id, nil
+}
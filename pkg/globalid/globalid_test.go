@@ -0,0 +1,59 @@
+package globalid
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, typ := range []string{User, Post, Comment, Board, WebhookDelivery} {
+		for _, id := range []uint{0, 1, 12, 4294967295} {
+			gid := Encode(typ, id)
+			got, err := DecodeAs(gid, typ)
+			if err != nil || got != id {
+				t.Errorf("expected %s %d from %q, got %d, %v", typ, id, gid, got, err)
+			}
+		}
+	}
+
+	// id поста и комментария с одним номером различаются
+	if Encode(Post, 1) == Encode(Comment, 1) {
+		t.Error("expected different ids for post and comment 1")
+	}
+}
+
+func TestDecodeAs(t *testing.T) {
+	raw := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name string
+		gid  string
+		typ  string
+		id   uint
+		ok   bool
+	}{
+		{"valid", Encode(Post, 7), Post, 7, true},
+		{"wrong type", Encode(Comment, 7), Post, 0, false},
+		{"not base64", "Post:7", Post, 0, false},
+		{"empty", "", Post, 0, false},
+		{"no separator", raw("Post7"), Post, 0, false},
+		{"not a number", raw("Post:x"), Post, 0, false},
+		{"negative", raw("Post:-1"), Post, 0, false},
+		{"too large", raw("Post:4294967296"), Post, 0, false},
+		{"empty type", raw(":7"), Post, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := DecodeAs(tt.gid, tt.typ)
+			if tt.ok && (err != nil || id != tt.id) {
+				t.Errorf("expected %d, got %d, %v", tt.id, id, err)
+			}
+			if !tt.ok && err == nil {
+				t.Errorf("expected error, got %d", id)
+			}
+		})
+	}
+}
@@ -5,14 +5,26 @@ package smodel
 
 import (
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/leonideliseev/ozonTestTask/graph/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	"github.com/leonideliseev/ozonTestTask/pkg/ranking"
 	_ "github.com/lib/pq"
 )
 
 type User struct {
 	ID       uint   `gorm:"primary_key"`
 	Username string `gorm:"not null"`
+	// когда аккаунт удалён, nil - не удалён. Не DeletedAt: по этому имени
+	// gorm перестал бы находить пользователя, а его записям нужен автор
+	AnonymizedAt *time.Time
+}
+
+// AnonymousUsername - username пользователя после удаления аккаунта
+func AnonymousUsername(id uint) string {
+	return "deleted-user-" + strconv.FormatUint(uint64(id), 10)
 }
 
 type Post struct {
@@ -22,8 +34,218 @@ type Post struct {
 	UserID          uint       `gorm:"not null"`
 	User            User       `gorm:"foreignkey:UserID"`
 	CommentsEnabled bool       `gorm:"not null"`
+	// доска поста, nil - пост вне досок
+	BoardID         *uint
 	Comments        []*Comment `gorm:"foreignkey:PostID"`
 	CommPage        *CommPage   `gorm:"-"`
+	CreatedAt       time.Time
+	// счётчики комментариев всех уровней и к самому посту,
+	// обновляются при создании комментария
+	CommentCount         int
+	TopLevelCommentCount int
+	LastCommentAt        *time.Time
+	// теги в порядке имён
+	Tags                 []Tag `gorm:"many2many:post_tags"`
+	Votes
+	// решение модератора
+	Status               ContentStatus `gorm:"not null;default:''"`
+	// минимальный интервал между комментариями одного пользователя
+	// к посту в секундах, 0 - без ограничения
+	SlowModeSeconds      int `gorm:"not null;default:0"`
+	// когда автор последний раз менял заголовок или текст, nil - не менял
+	EditedAt             *time.Time
+	// когда пост удалён автором или модератором, nil - не удалён.
+	// Не DeletedAt: такое поле gorm сам добавляет в условия всех запросов,
+	// а удалённые записи нужны модераторам и остаются в ветках комментариев
+	RemovedAt            *time.Time
+	// когда текст удалённого поста стёрт насовсем, пост остался заглушкой
+	PurgedAt             *time.Time
+	// пост не создан, а возвращён повторно по ключу идемпотентности
+	Replayed             bool `gorm:"-" json:"-"`
+}
+
+// Votes - голоса за пост или комментарий и ранги по ним (см. пакет ranking),
+// обновляются при голосовании
+type Votes struct {
+	Upvotes   int
+	Downvotes int
+	TopRank   float64
+	HotRank   float64
+}
+
+// Score - голоса "за" минус голоса "против"
+func (v Votes) Score() int {
+	return v.Upvotes - v.Downvotes
+}
+
+// Change заменяет голос old на value (1, -1 или 0 - нет голоса)
+// и пересчитывает ранги записи, созданной в createdAt
+func (v *Votes) Change(old, value int, createdAt time.Time) {
+	for _, change := range []struct{ value, sign int }{{old, -1}, {value, 1}} {
+		switch change.value {
+		case 1:
+			v.Upvotes += change.sign
+		case -1:
+			v.Downvotes += change.sign
+		}
+	}
+
+	v.TopRank = ranking.Top(v.Upvotes, v.Downvotes)
+	v.HotRank = ranking.Hot(v.Upvotes, v.Downvotes, createdAt)
+}
+
+// Board - доска, на которой собраны посты одной команды или темы.
+// Настройки доски проверяются при создании постов и комментариев
+type Board struct {
+	ID          uint   `gorm:"primary_key"`
+	Name        string `gorm:"not null"`
+	Description string `gorm:"not null"`
+	// включены ли комментарии у поста, если при создании это не указано
+	CommentsEnabledByDefault bool `gorm:"not null"`
+	// максимальная длина комментария в символах, 0 - без ограничения доски
+	MaxCommentLength int `gorm:"not null"`
+	// кто может создавать посты, пусто - любой пользователь
+	AllowedPosters []User `gorm:"many2many:board_posters"`
+	CreatedAt      time.Time
+}
+
+type BoardPage struct {
+	Boards []*Board
+	TotalCount int
+}
+
+type CreateBoard struct {
+	Name        string
+	Description string
+	CommentsEnabledByDefault bool
+	MaxCommentLength int
+	// id пользователей, которые могут создавать посты, без повторов
+	AllowedPosters []uint
+}
+
+// Tag - тег поста, имя уникально. В in-memory хранилище теги
+// различаются только по имени и ID не заполняется
+type Tag struct {
+	ID   uint   `gorm:"primary_key"`
+	Name string `gorm:"not null;unique_index"`
+}
+
+// TagCount - тег и количество постов с ним
+type TagCount struct {
+	Name      string
+	PostCount int
+}
+
+// TargetType - к чему относятся реакция или голос
+type TargetType string
+
+const (
+	TargetPost    TargetType = "post"
+	TargetComment TargetType = "comment"
+)
+
+// Target - пост или комментарий, на который ставится реакция или голос
+type Target struct {
+	Type TargetType
+	ID   uint
+}
+
+// Reaction - реакция пользователя, каждую реакцию пользователь
+// ставит на пост или комментарий не больше одного раза
+type Reaction struct {
+	UserID     uint               `gorm:"primary_key;auto_increment:false"`
+	TargetType TargetType `gorm:"primary_key"`
+	TargetID   uint               `gorm:"primary_key;auto_increment:false"`
+	Reaction   string             `gorm:"primary_key"`
+	CreatedAt  time.Time
+}
+
+// ReactionCount - количество одной реакции на пост или комментарий,
+// обновляется вместе с реакциями
+type ReactionCount struct {
+	TargetType TargetType `gorm:"primary_key"`
+	TargetID   uint               `gorm:"primary_key;auto_increment:false"`
+	Reaction   string             `gorm:"primary_key"`
+	Count      int                `gorm:"not null"`
+}
+
+// ReactionSummary - результат React и Unreact
+type ReactionSummary struct {
+	// пост, к которому относится реакция: сам пост или пост комментария
+	PostID uint
+	// false - реакция уже была поставлена или снята
+	Changed bool
+	// количества реакций в порядке их имён
+	Counts []*ReactionCount
+}
+
+type React struct {
+	UserId   uint
+	Target   Target
+	Reaction string
+}
+
+// PostFilter - фильтр постов в GetPosts
+type PostFilter struct {
+	// посты доски, nil - все посты
+	BoardId *uint
+	// посты с тегами (без повторов), пусто - без фильтра по тегам
+	Tags []string
+	// true - посты со всеми тегами из Tags, false - хотя бы с одним
+	AllTags bool
+	// вместе с удалёнными постами, для модераторов
+	WithDeleted bool
+}
+
+// PostSort - порядок постов в GetPosts, значения совпадают с enum PostSort в GraphQL
+type PostSort string
+
+const (
+	// в порядке создания
+	PostSortDefault              PostSort = ""
+	PostSortCommentCount         PostSort = "COMMENT_COUNT"
+	PostSortTopLevelCommentCount PostSort = "TOP_LEVEL_COMMENT_COUNT"
+	PostSortLastCommentAt        PostSort = "LAST_COMMENT_AT"
+	// по рангам голосов
+	PostSortTop                  PostSort = "TOP"
+	PostSortHot                  PostSort = "HOT"
+)
+
+// CommentSort - порядок комментариев и ответов на каждом уровне дерева,
+// значения совпадают с enum CommentSort в GraphQL
+type CommentSort string
+
+const (
+	// в порядке создания
+	CommentSortDefault CommentSort = ""
+	CommentSortTop     CommentSort = "TOP"
+	CommentSortHot     CommentSort = "HOT"
+)
+
+// Vote - голос пользователя за пост или комментарий, у пользователя
+// один голос на запись. Value: 1 - "за", -1 - "против"
+type Vote struct {
+	UserID     uint       `gorm:"primary_key;auto_increment:false"`
+	TargetType TargetType `gorm:"primary_key"`
+	TargetID   uint       `gorm:"primary_key;auto_increment:false"`
+	Value      int        `gorm:"not null"`
+	CreatedAt  time.Time
+}
+
+// CastVote - голос пользователя, Value 0 снимает голос
+type CastVote struct {
+	UserId uint
+	Target Target
+	Value  int
+}
+
+// VoteSummary - результат голосования
+type VoteSummary struct {
+	// пост, к которому относится голос: сам пост или пост комментария
+	PostID uint
+	// false - голос не изменился
+	Changed bool
+	Votes
 }
 
 type Comment struct {
@@ -35,6 +257,312 @@ type Comment struct {
 	ParentID  *uint
 	Replies   []*Comment `gorm:"foreignkey:ParentID"`
 	ReplyPage *CommPage   `gorm:"-"`
+	CreatedAt time.Time
+	Votes
+	// решение модератора
+	Status    ContentStatus `gorm:"not null;default:''"`
+	// когда автор последний раз менял текст, nil - не менял
+	EditedAt  *time.Time
+	// когда комментарий удалён, nil - не удалён, как у Post
+	RemovedAt *time.Time
+	// когда текст удалённого комментария стёрт насовсем
+	PurgedAt  *time.Time
+	// комментарий не создан, а возвращён повторно по ключу идемпотентности
+	Replayed  bool `gorm:"-" json:"-"`
+	// уведомления, созданные вместе с комментарием
+	Notifications []Notification `gorm:"-" json:"-"`
+}
+
+// ContentStatus - решение модератора по посту или комментарию.
+// Значения совпадают с enum ContentStatus в GraphQL, кроме видимой записи
+type ContentStatus string
+
+const (
+	ContentVisible ContentStatus = ""
+	// скрыта от всех, кроме модераторов
+	ContentHidden  ContentStatus = "HIDDEN"
+	// текст стёрт
+	ContentDeleted ContentStatus = "DELETED"
+)
+
+func (s ContentStatus) ToGraphQL() model.ContentStatus {
+	if s == ContentVisible {
+		return model.ContentStatusVisible
+	}
+	return model.ContentStatus(s)
+}
+
+// ReportStatus - статус жалобы, значения совпадают с enum ReportStatus в GraphQL
+type ReportStatus string
+
+const (
+	ReportOpen     ReportStatus = "OPEN"
+	ReportApproved ReportStatus = "APPROVED"
+	ReportHidden   ReportStatus = "HIDDEN"
+	ReportDeleted  ReportStatus = "DELETED"
+)
+
+// ModerationAction - решение модератора по жалобе, значения совпадают
+// с enum ModerationAction в GraphQL
+type ModerationAction string
+
+const (
+	ModerationApprove ModerationAction = "APPROVE"
+	ModerationHide    ModerationAction = "HIDE"
+	ModerationDelete  ModerationAction = "DELETE"
+)
+
+// ModerationResults - статус жалобы и записи после решения модератора
+var ModerationResults = map[ModerationAction]struct {
+	Report  ReportStatus
+	Content ContentStatus
+}{
+	ModerationApprove: {ReportApproved, ContentVisible},
+	ModerationHide:    {ReportHidden, ContentHidden},
+	ModerationDelete:  {ReportDeleted, ContentDeleted},
+}
+
+// Report - жалоба на пост или комментарий в очереди модерации
+type Report struct {
+	ID         uint       `gorm:"primary_key"`
+	TargetType TargetType `gorm:"not null"`
+	TargetID   uint       `gorm:"not null"`
+	// пост, к которому относится запись: сам пост или пост комментария
+	PostID     uint       `gorm:"not null"`
+	// кто пожаловался, nil - запись отправлена в очередь фильтром модерации
+	ReporterID *uint
+	Reason     string       `gorm:"not null"`
+	Status     ReportStatus `gorm:"not null"`
+	CreatedAt  time.Time
+	// решение модератора, заполняется при закрытии жалобы
+	ModeratorID *uint
+	Resolution  string `gorm:"not null"`
+	ResolvedAt  *time.Time
+}
+
+type ReportPage struct {
+	Reports []*Report
+	TotalCount int
+}
+
+// NotificationKind - почему пришло уведомление, значения совпадают
+// с enum NotificationKind в GraphQL
+type NotificationKind string
+
+const (
+	// пользователя упомянули в комментарии через @username
+	NotificationMention NotificationKind = "MENTION"
+	// ответ на комментарий пользователя
+	NotificationReply   NotificationKind = "REPLY"
+)
+
+// Notification - уведомление пользователя о комментарии
+type Notification struct {
+	ID        uint             `gorm:"primary_key"`
+	// получатель
+	UserID    uint             `gorm:"not null"`
+	Kind      NotificationKind `gorm:"not null"`
+	// автор комментария
+	ActorID   uint             `gorm:"not null"`
+	PostID    uint             `gorm:"not null"`
+	CommentID uint             `gorm:"not null"`
+	Read      bool             `gorm:"not null;default:false"`
+	CreatedAt time.Time
+}
+
+type NotificationPage struct {
+	Notifications []*Notification
+	TotalCount    int
+	// непрочитанные уведомления пользователя, без учёта фильтра
+	UnreadCount   int
+}
+
+// Revision - версия заголовка и текста поста или комментария. Версии
+// не меняются и остаются после удаления записи модератором.
+// Пока запись не менялась, версии не хранятся: её единственная версия 1 -
+// текущий текст. При первом изменении сохраняется и исходная версия
+type Revision struct {
+	ID         uint       `gorm:"primary_key"`
+	TargetType TargetType `gorm:"not null"`
+	TargetID   uint       `gorm:"not null"`
+	// номер версии записи, начиная с 1
+	Number int `gorm:"not null"`
+	// у комментариев пустой
+	Title   string `gorm:"type:text;not null"`
+	Content string `gorm:"type:text;not null"`
+	// кто внёс изменение: автор или модератор, у исходной версии - автор
+	EditorID  uint `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+type RevisionPage struct {
+	Revisions  []*Revision
+	TotalCount int
+}
+
+// WebhookEvent - событие для вебхуков, значения совпадают
+// с enum WebhookEvent в GraphQL
+type WebhookEvent string
+
+const (
+	WebhookPostCreated    WebhookEvent = "POST_CREATED"
+	WebhookCommentCreated WebhookEvent = "COMMENT_CREATED"
+)
+
+// Webhook - адрес, на который отправляются события
+type Webhook struct {
+	ID     uint   `gorm:"primary_key"`
+	URL    string `gorm:"not null"`
+	// события через запятую
+	Events string `gorm:"not null"`
+	// ключ подписи тела запроса, наружу не отдаётся
+	Secret    string `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+// EventList возвращает события вебхука
+func (w *Webhook) EventList() []WebhookEvent {
+	events := make([]WebhookEvent, 0)
+	for _, event := range strings.Split(w.Events, ",") {
+		if event != "" {
+			events = append(events, WebhookEvent(event))
+		}
+	}
+	return events
+}
+
+// Subscribed проверяет, отправляется ли вебхуку событие
+func (w *Webhook) Subscribed(event WebhookEvent) bool {
+	for _, e := range w.EventList() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateWebhook struct {
+	URL    string
+	// события без повторов
+	Events []WebhookEvent
+	Secret string
+}
+
+// DeliveryStatus - состояние доставки события вебхуку, значения совпадают
+// с enum WebhookDeliveryStatus в GraphQL
+type DeliveryStatus string
+
+const (
+	// ждёт первой или повторной попытки
+	DeliveryPending   DeliveryStatus = "PENDING"
+	DeliveryDelivered DeliveryStatus = "DELIVERED"
+	// попытки закончились, доставка больше не повторяется
+	DeliveryDead      DeliveryStatus = "DEAD"
+)
+
+// WebhookDelivery - доставка одного события одному вебхуку
+type WebhookDelivery struct {
+	ID        uint         `gorm:"primary_key"`
+	WebhookID uint         `gorm:"not null"`
+	Event     WebhookEvent `gorm:"not null"`
+	// тело запроса, одинаковое для всех вебхуков события
+	Payload   string         `gorm:"type:text;not null"`
+	Status    DeliveryStatus `gorm:"not null"`
+	Attempts  int            `gorm:"not null"`
+	// событие outbox, 0 - доставка создана не из outbox
+	EventID uint `gorm:"not null;default:0"`
+	// автор записи события, 0 - неизвестен. Доставки удаляются вместе с аккаунтом автора
+	UserID uint `gorm:"not null;default:0;index"`
+	// когда делать следующую попытку, для PENDING
+	NextAttemptAt time.Time `gorm:"not null"`
+	// результат последней попытки: код ответа (0 - ответа не было) и ошибка
+	ResponseStatus int    `gorm:"not null"`
+	LastError      string `gorm:"not null"`
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []*WebhookDelivery
+	TotalCount int
+}
+
+// DeliveryFilter - фильтр доставок, nil - без фильтра
+type DeliveryFilter struct {
+	WebhookId *uint
+	Status    *DeliveryStatus
+}
+
+// CreateDeliveries - событие, которое нужно доставить всем подписанным вебхукам
+type CreateDeliveries struct {
+	Event   WebhookEvent
+	Payload string
+	At      time.Time
+	// событие outbox, из которого создаются доставки, 0 - не из outbox.
+	// Повтор с тем же EventId новых доставок не создаёт
+	EventId uint
+	// автор записи события. Если его аккаунт удалён, доставки не создаются
+	UserId uint
+}
+
+// OutboxEventType - тип доменного события в outbox
+type OutboxEventType string
+
+const (
+	EventPostCreated    OutboxEventType = "post.created"
+	EventCommentCreated OutboxEventType = "comment.created"
+)
+
+// OutboxEvent - доменное событие, записанное в транзакции изменения
+type OutboxEvent struct {
+	ID   uint            `gorm:"primary_key"`
+	Type OutboxEventType `gorm:"not null"`
+	// JSON: Post для post.created, CommentCreated для comment.created
+	Payload   string `gorm:"type:text;not null"`
+	// автор записи события, событие удаляется вместе с аккаунтом автора
+	UserID    uint `gorm:"not null;default:0;index"`
+	CreatedAt time.Time
+	// место в порядке чтения, выдаётся после фиксации события, до этого nil
+	Position *uint `gorm:"unique_index"`
+}
+
+// OutboxOffset - место последнего события outbox, обработанного потребителем
+type OutboxOffset struct {
+	Consumer    string `gorm:"primary_key"`
+	LastEventID uint   `gorm:"not null"`
+}
+
+// CommentCreated - данные события comment.created
+type CommentCreated struct {
+	Comment       Comment        `json:"comment"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// DeliveryAttempt - результат попытки доставки
+type DeliveryAttempt struct {
+	DeliveryId uint
+	// DELIVERED, PENDING для повтора в NextAttemptAt или DEAD
+	Status         DeliveryStatus
+	NextAttemptAt  time.Time
+	ResponseStatus int
+	Error          string
+	At             time.Time
+}
+
+type CreateReport struct {
+	// nil - жалоба от фильтра модерации
+	ReporterId *uint
+	Target     Target
+	Reason     string
+}
+
+// ResolveReport - решение модератора. Оно применяется к записи
+// и закрывает все открытые жалобы на неё
+type ResolveReport struct {
+	ReportId    uint
+	ModeratorId uint
+	Action      ModerationAction
+	Reason      string
 }
 
 type PostPage struct {
@@ -47,11 +575,105 @@ type CommPage struct {
 	TotalCount int
 }
 
+// SearchType - что искать в Search, значения совпадают с enum SearchType в GraphQL
+type SearchType string
+
+const (
+	// посты и комментарии
+	SearchAll     SearchType = ""
+	SearchPosts   SearchType = "POST"
+	SearchComments SearchType = "COMMENT"
+)
+
+// Search - параметры полнотекстового поиска
+type Search struct {
+	Query    string
+	Type     SearchType
+	// только автора с этим id
+	AuthorId *uint
+	// только созданные в [From, To)
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Offset   int
+}
+
+// SearchHit - найденный пост или комментарий, заполнено одно из Post и Comment.
+// В Snippet совпавшие слова выделены тегами <b></b>
+type SearchHit struct {
+	Post    *Post
+	Comment *Comment
+	Rank    float64
+	Snippet string
+}
+
+type SearchPage struct {
+	Hits []*SearchHit
+	TotalCount int
+}
+
 type CreatePost struct {
 	Title    string
 	Content  string
 	UserId   uint
 	CommentsEnabled bool
+	// доска поста, nil - пост вне досок
+	BoardId  *uint
+	// имена тегов, без повторов
+	Tags     []string
+	SlowModeSeconds int
+	// nil - без ключа идемпотентности
+	Idempotency *Idempotency
+}
+
+// EditPost - изменение поста его автором, nil - поле не меняется
+type EditPost struct {
+	PostId   uint
+	EditorId uint
+	Title    *string
+	Content  *string
+	// текущие заголовок и текст, с которыми модерация проверила пост, если
+	// Title или Content не меняются. Если к моменту изменения они другие,
+	// то изменение не сохраняется. nil - не проверяется
+	BaseTitle   *string
+	BaseContent *string
+}
+
+// EditComment - изменение комментария его автором
+type EditComment struct {
+	CommentId uint
+	EditorId  uint
+	Content   string
+}
+
+// SoftDelete - удаление поста или комментария автором или модератором.
+// Запись помечается удалённой и до очистки может быть восстановлена
+type SoftDelete struct {
+	Target Target
+	UserId uint
+	// модератор может удалить чужую запись
+	Moderator bool
+}
+
+// Restore - восстановление удалённой записи модератором
+type Restore struct {
+	Target Target
+	// восстанавливаются только записи, удалённые позже этого времени
+	DeletedAfter time.Time
+}
+
+// AnonymizeUser - удаление аккаунта: username заменяется на
+// AnonymousUsername, а посты и комментарии остаются за анонимным автором
+type AnonymizeUser struct {
+	UserId uint
+	// стереть текст постов и комментариев пользователя, оставив заглушки
+	RemoveContent bool
+}
+
+// SetSlowMode - новый интервал медленного режима поста, 0 - выключить
+type SetSlowMode struct {
+	PostId  uint
+	Seconds int
 }
 
 type CreateComment struct {
@@ -59,6 +681,28 @@ type CreateComment struct {
 	UserId   uint
 	Content  string
 	ParentId *uint
+	// nil - без ключа идемпотентности
+	Idempotency *Idempotency
+}
+
+// Idempotency - ключ идемпотентности мутации создания. Повтор с тем же ключом
+// от того же пользователя возвращает уже созданную запись, пока ключ не истёк
+type Idempotency struct {
+	Key  string
+	// хэш данных запроса: повтор с другими данными отклоняется
+	Hash string
+	// сколько ключ хранится после создания записи
+	TTL  time.Duration
+}
+
+// IdempotencyKey - сохранённый ключ идемпотентности и созданная по нему запись
+type IdempotencyKey struct {
+	UserID     uint       `gorm:"primary_key;auto_increment:false"`
+	Key        string     `gorm:"primary_key"`
+	Hash       string     `gorm:"not null"`
+	TargetType TargetType `gorm:"not null"`
+	TargetID   uint       `gorm:"not null"`
+	ExpiresAt  time.Time  `gorm:"not null"`
 }
 
 type CreateUser struct {
@@ -78,13 +722,37 @@ func (p *Post) ToGraphQL() *model.Post {
 
 	user := p.User.ToGraphQL()
 
+	var boardID *string
+	if p.BoardID != nil {
+		idStr := globalid.Encode(globalid.Board, *p.BoardID)
+		boardID = &idStr
+	}
+
+	tags := make([]string, len(p.Tags))
+	for i, tag := range p.Tags {
+		tags[i] = tag.Name
+	}
+
 	return &model.Post{
-		ID:       strconv.FormatUint(uint64(p.ID), 10),
+		ID:       globalid.Encode(globalid.Post, p.ID),
 		Title:    p.Title,
 		Content:  p.Content,
-		UserID:   strconv.FormatUint(uint64(p.UserID), 10),
+		UserID:   globalid.Encode(globalid.User, p.UserID),
 		Author:   user,
 		CommentsEnabled: p.CommentsEnabled,
+		CreatedAt: p.CreatedAt,
+		CommentCount: p.CommentCount,
+		TopLevelCommentCount: p.TopLevelCommentCount,
+		LastCommentAt: p.LastCommentAt,
+		Score: p.Score(),
+		Upvotes: p.Upvotes,
+		Downvotes: p.Downvotes,
+		Tags: tags,
+		BoardID: boardID,
+		Status: p.Status.ToGraphQL(),
+		SlowModeSeconds: p.SlowModeSeconds,
+		EditedAt: p.EditedAt,
+		DeletedAt: p.RemovedAt,
 		CommPage: &model.CommPage{
 			Comments: comments,
 			TotalCount: totalCount,
@@ -95,7 +763,7 @@ func (p *Post) ToGraphQL() *model.Post {
 func (c *Comment) ToGraphQL() *model.Comment {
 	var parentID *string
 	if c.ParentID != nil {
-		idStr := strconv.FormatUint(uint64(*c.ParentID), 10)
+		idStr := globalid.Encode(globalid.Comment, *c.ParentID)
 		parentID = &idStr
 	}
 
@@ -110,12 +778,19 @@ func (c *Comment) ToGraphQL() *model.Comment {
 	}
 
 	return &model.Comment{
-		ID:       strconv.FormatUint(uint64(c.ID), 10),
-		PostID:   strconv.FormatUint(uint64(c.PostID), 10),
+		ID:       globalid.Encode(globalid.Comment, c.ID),
+		PostID:   globalid.Encode(globalid.Post, c.PostID),
 		ParentCommentID: parentID,
-		UserID:   strconv.FormatUint(uint64(c.UserID), 10),
+		UserID:   globalid.Encode(globalid.User, c.UserID),
 		Author:     c.User.ToGraphQL(),
 		Content:  c.Content,
+		CreatedAt: c.CreatedAt,
+		Score: c.Score(),
+		Upvotes: c.Upvotes,
+		Downvotes: c.Downvotes,
+		Status: c.Status.ToGraphQL(),
+		EditedAt: c.EditedAt,
+		DeletedAt: c.RemovedAt,
 		ReplyPage: &model.CommPage{
 			Comments: replies,
 			TotalCount: totalCount,
@@ -123,9 +798,125 @@ func (c *Comment) ToGraphQL() *model.Comment {
 	}
 }
 
+func (b *Board) ToGraphQL() *model.Board {
+	posters := make([]*model.User, len(b.AllowedPosters))
+	for i, user := range b.AllowedPosters {
+		posters[i] = user.ToGraphQL()
+	}
+
+	return &model.Board{
+		ID:          globalid.Encode(globalid.Board, b.ID),
+		Name:        b.Name,
+		Description: b.Description,
+		CommentsEnabledByDefault: b.CommentsEnabledByDefault,
+		MaxCommentLength: b.MaxCommentLength,
+		AllowedPosters: posters,
+		CreatedAt:   b.CreatedAt,
+	}
+}
+
+func (r *Report) ToGraphQL() *model.Report {
+	var reporterID, moderatorID *string
+	if r.ReporterID != nil {
+		idStr := globalid.Encode(globalid.User, *r.ReporterID)
+		reporterID = &idStr
+	}
+	if r.ModeratorID != nil {
+		idStr := globalid.Encode(globalid.User, *r.ModeratorID)
+		moderatorID = &idStr
+	}
+
+	var resolution *string
+	if r.Status != ReportOpen {
+		resolution = &r.Resolution
+	}
+
+	targetKind := globalid.Post
+	if r.TargetType == TargetComment {
+		targetKind = globalid.Comment
+	}
+
+	return &model.Report{
+		ID:          globalid.Encode(globalid.Report, r.ID),
+		TargetID:    globalid.Encode(targetKind, r.TargetID),
+		PostID:      globalid.Encode(globalid.Post, r.PostID),
+		ReporterID:  reporterID,
+		Reason:      r.Reason,
+		Status:      model.ReportStatus(r.Status),
+		CreatedAt:   r.CreatedAt,
+		ModeratorID: moderatorID,
+		Resolution:  resolution,
+		ResolvedAt:  r.ResolvedAt,
+	}
+}
+
 func (u *User) ToGraphQL() *model.User {
 	return &model.User{
-		ID:       strconv.FormatUint(uint64(u.ID), 10),
+		ID:       globalid.Encode(globalid.User, u.ID),
 		Username: u.Username,
+		Deleted:  u.AnonymizedAt != nil,
+	}
+}
+
+func (n *Notification) ToGraphQL() *model.Notification {
+	return &model.Notification{
+		ID:        globalid.Encode(globalid.Notification, n.ID),
+		Kind:      model.NotificationKind(n.Kind),
+		ActorID:   globalid.Encode(globalid.User, n.ActorID),
+		PostID:    globalid.Encode(globalid.Post, n.PostID),
+		CommentID: globalid.Encode(globalid.Comment, n.CommentID),
+		Read:      n.Read,
+		CreatedAt: n.CreatedAt,
+	}
+}
+
+func (w *Webhook) ToGraphQL() *model.Webhook {
+	events := make([]model.WebhookEvent, 0)
+	for _, event := range w.EventList() {
+		events = append(events, model.WebhookEvent(event))
+	}
+
+	return &model.Webhook{
+		ID:        globalid.Encode(globalid.Webhook, w.ID),
+		URL:       w.URL,
+		Events:    events,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+func (d *WebhookDelivery) ToGraphQL() *model.WebhookDelivery {
+	var responseStatus *int
+	if d.ResponseStatus != 0 {
+		status := d.ResponseStatus
+		responseStatus = &status
+	}
+
+	return &model.WebhookDelivery{
+		ID:             globalid.Encode(globalid.WebhookDelivery, d.ID),
+		WebhookID:      globalid.Encode(globalid.Webhook, d.WebhookID),
+		Event:          model.WebhookEvent(d.Event),
+		Payload:        d.Payload,
+		Status:         model.WebhookDeliveryStatus(d.Status),
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		ResponseStatus: responseStatus,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+}
+
+func (r *Revision) ToGraphQL() *model.Revision {
+	var title *string
+	if r.TargetType == TargetPost {
+		title = &r.Title
+	}
+
+	return &model.Revision{
+		Number:    r.Number,
+		Title:     title,
+		Content:   r.Content,
+		EditorID:  globalid.Encode(globalid.User, r.EditorID),
+		CreatedAt: r.CreatedAt,
 	}
 }
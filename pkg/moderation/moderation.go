@@ -0,0 +1,190 @@
+package moderation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Модерация постов и комментариев до записи в хранилище.
+// Pipeline прогоняет запись через этапы (Stage) по порядку: каждый этап
+// пропускает запись, отклоняет её с причиной или отправляет в очередь модерации
+
+// Kind - что проверяется
+type Kind string
+
+const (
+	KindPost    Kind = "post"
+	KindComment Kind = "comment"
+)
+
+// Item - создаваемая или изменяемая запись, у комментария Title пустой
+type Item struct {
+	Kind    Kind
+	UserID  uint
+	Title   string
+	Content string
+}
+
+// Text - весь текст записи
+func (i Item) Text() string {
+	if i.Title == "" {
+		return i.Content
+	}
+	return i.Title + "\n" + i.Content
+}
+
+// Action - решение этапа
+type Action int
+
+const (
+	Allow Action = iota
+	// запись в очередь модерации: создаётся, но её проверит модератор
+	Queue
+	Reject
+)
+
+func (a Action) String() string {
+	switch a {
+	case Queue:
+		return "queue"
+	case Reject:
+		return "reject"
+	}
+	return "allow"
+}
+
+// Verdict - решение по записи: этап, который его принял, и причина
+type Verdict struct {
+	Action Action
+	Stage  string
+	Reason string
+}
+
+// Err возвращает ошибку для отклонённой записи, для остальных - nil
+func (v Verdict) Err() error {
+	if v.Action != Reject {
+		return nil
+	}
+	return &RejectedError{Stage: v.Stage, Reason: v.Reason}
+}
+
+// RejectedError - запись отклонена модерацией
+type RejectedError struct {
+	Stage  string
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("rejected by moderation (%s): %s", e.Stage, e.Reason)
+}
+
+// Stage - этап модерации. Check не меняет запись и может
+// вызываться одновременно из разных запросов
+type Stage interface {
+	Name() string
+	Check(item Item) Verdict
+}
+
+// Pipeline - этапы модерации в порядке проверки, пустой пропускает всё
+type Pipeline struct {
+	stages []Stage
+}
+
+func New(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Check прогоняет запись через этапы. Первое отклонение прекращает проверку,
+// а решение отправить в очередь запоминается, и проверка продолжается:
+// следующий этап ещё может запись отклонить
+func (p *Pipeline) Check(item Item) Verdict {
+	res := Verdict{Action: Allow}
+	for _, stage := range p.stages {
+		verdict := stage.Check(item)
+		if verdict.Stage == "" {
+			verdict.Stage = stage.Name()
+		}
+
+		switch verdict.Action {
+		case Reject:
+			return verdict
+		case Queue:
+			if res.Action == Allow {
+				res = verdict
+			}
+		}
+	}
+
+	return res
+}
+
+// Parse собирает встроенные этапы из списка вида
+// "banned_words=reject,links=queue:3,spam=queue:10,length=reject:10000".
+// После действия (reject или queue) указывается предел этапа, у banned_words
+// его нет, а слова передаются в bannedWords. Этапы проверяются в порядке списка
+func Parse(spec string, bannedWords []string) (*Pipeline, error) {
+	var stages []Stage
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, rule, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid moderation stage %q, expected stage=action[:limit]", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("moderation stage %s is set twice", name)
+		}
+		seen[name] = true
+
+		actionStr, limitStr, hasLimit := strings.Cut(rule, ":")
+		var action Action
+		switch actionStr {
+		case "reject":
+			action = Reject
+		case "queue":
+			action = Queue
+		default:
+			return nil, fmt.Errorf("unknown action %q of moderation stage %s, expected reject or queue", actionStr, name)
+		}
+
+		limit := 0
+		if hasLimit {
+			var err error
+			limit, err = strconv.Atoi(limitStr)
+			if err != nil || limit < 0 {
+				return nil, fmt.Errorf("invalid limit %q of moderation stage %s", limitStr, name)
+			}
+		}
+
+		if name == BannedWordsStage {
+			if hasLimit {
+				return nil, fmt.Errorf("moderation stage %s has no limit", name)
+			}
+			stages = append(stages, NewBannedWords(action, bannedWords))
+			continue
+		}
+
+		if !hasLimit {
+			return nil, fmt.Errorf("moderation stage %s needs a limit", name)
+		}
+
+		switch name {
+		case LinksStage:
+			stages = append(stages, &Links{Action: action, Max: limit})
+		case SpamStage:
+			stages = append(stages, &Spam{Action: action, MaxRepeats: limit})
+		case LengthStage:
+			stages = append(stages, &Length{Action: action, Max: limit})
+		default:
+			return nil, fmt.Errorf("unknown moderation stage %q", name)
+		}
+	}
+
+	return New(stages...), nil
+}
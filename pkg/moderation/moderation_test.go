@@ -0,0 +1,89 @@
+package moderation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStages(t *testing.T) {
+	tests := []struct {
+		name    string
+		stage   Stage
+		content string
+		action  Action
+	}{
+		{"BannedWord", NewBannedWords(Reject, []string{"Casino"}), "best casino, come in", Reject},
+		{"BannedWordInsideWord", NewBannedWords(Reject, []string{"casino"}), "casinos are fine", Allow},
+		{"Links", &Links{Action: Queue, Max: 1}, "see https://a.ru and www.b.ru", Queue},
+		{"LinksUnderLimit", &Links{Action: Queue, Max: 2}, "see https://a.ru and www.b.ru", Allow},
+		{"RepeatedChar", &Spam{Action: Queue, MaxRepeats: 3}, "wow!!!!", Queue},
+		{"RepeatedSpaces", &Spam{Action: Queue, MaxRepeats: 3}, "a      b", Allow},
+		{"RepeatedWord", &Spam{Action: Reject, MaxRepeats: 2}, "buy Buy buy now", Reject},
+		{"Length", &Length{Action: Reject, Max: 5}, "привет", Reject},
+		{"LengthInRunes", &Length{Action: Reject, Max: 6}, "привет", Allow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := tt.stage.Check(Item{Kind: KindComment, Content: tt.content})
+			if verdict.Action != tt.action {
+				t.Error("expected", tt.action, "got", verdict)
+			}
+			if verdict.Action != Allow && verdict.Reason == "" {
+				t.Error("expected reason, got", verdict)
+			}
+		})
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p := New(&Links{Action: Queue, Max: 0}, &Spam{Action: Reject, MaxRepeats: 3}, &Links{Action: Reject, Max: 0})
+
+	// очередь запоминается, но следующий этап отклоняет запись
+	verdict := p.Check(Item{Kind: KindPost, Title: "t", Content: "https://a.ru !!!!"})
+	if verdict.Action != Reject || verdict.Stage != SpamStage {
+		t.Error("expected reject by spam, got", verdict)
+	}
+	var rejected *RejectedError
+	if err := verdict.Err(); !errors.As(err, &rejected) || rejected.Stage != SpamStage {
+		t.Error("expected rejected error, got", err)
+	}
+
+	// первый этап, отправивший в очередь
+	verdict = New(&Links{Action: Queue, Max: 0}, &Spam{Action: Queue, MaxRepeats: 3}).Check(Item{Kind: KindPost, Content: "https://a.ru !!!!"})
+	if verdict.Action != Queue || verdict.Stage != LinksStage || verdict.Err() != nil {
+		t.Error("expected queue by links, got", verdict)
+	}
+
+	if verdict := New().Check(Item{Kind: KindPost, Content: "https://a.ru !!!!"}); verdict.Action != Allow {
+		t.Error("expected allow by empty pipeline, got", verdict)
+	}
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse("banned_words=reject, links=queue:2,spam=queue:5,length=reject:100", []string{"casino"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.stages) != 4 {
+		t.Fatal("expected 4 stages, got", len(p.stages))
+	}
+	if verdict := p.Check(Item{Kind: KindComment, Content: strings.Repeat("a ", 100)}); verdict.Action != Reject || verdict.Stage != LengthStage {
+		t.Error("expected reject by length, got", verdict)
+	}
+
+	for _, spec := range []string{
+		"links",
+		"links=allow:2",
+		"links=queue",
+		"links=queue:-1",
+		"banned_words=reject:2",
+		"unknown=reject:2",
+		"spam=queue:2,spam=reject:3",
+	} {
+		if _, err := Parse(spec, nil); err == nil {
+			t.Error("expected error for", spec)
+		}
+	}
+}
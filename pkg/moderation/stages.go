@@ -0,0 +1,137 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// имена встроенных этапов в Parse
+const (
+	BannedWordsStage = "banned_words"
+	LinksStage       = "links"
+	SpamStage        = "spam"
+	LengthStage      = "length"
+)
+
+// BannedWords находит запрещённые слова. Слова сравниваются целиком
+// без учёта регистра, "спам" не находится в "спамер"
+type BannedWords struct {
+	Action Action
+	words  map[string]bool
+}
+
+func NewBannedWords(action Action, words []string) *BannedWords {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			set[word] = true
+		}
+	}
+
+	return &BannedWords{Action: action, words: set}
+}
+
+func (s *BannedWords) Name() string {
+	return BannedWordsStage
+}
+
+func (s *BannedWords) Check(item Item) Verdict {
+	for _, word := range words(item.Text()) {
+		if s.words[word] {
+			return Verdict{Action: s.Action, Reason: fmt.Sprintf("banned word %q", word)}
+		}
+	}
+	return Verdict{Action: Allow}
+}
+
+// Links ограничивает количество ссылок
+type Links struct {
+	Action Action
+	Max    int
+}
+
+func (s *Links) Name() string {
+	return LinksStage
+}
+
+func (s *Links) Check(item Item) Verdict {
+	count := 0
+	for _, field := range strings.Fields(strings.ToLower(item.Text())) {
+		if strings.Contains(field, "http://") || strings.Contains(field, "https://") || strings.HasPrefix(field, "www.") {
+			count++
+		}
+	}
+
+	if count > s.Max {
+		return Verdict{Action: s.Action, Reason: fmt.Sprintf("too many links, %d > %d", count, s.Max)}
+	}
+	return Verdict{Action: Allow}
+}
+
+// Spam находит повторы: один символ или одно слово подряд больше MaxRepeats раз,
+// например "!!!!!!!!!!!!" или "купи купи купи купи". Пробелы не считаются
+type Spam struct {
+	Action     Action
+	MaxRepeats int
+}
+
+func (s *Spam) Name() string {
+	return SpamStage
+}
+
+func (s *Spam) Check(item Item) Verdict {
+	var prev rune
+	run := 0
+	for _, r := range item.Text() {
+		if r == prev {
+			run++
+		} else {
+			prev, run = r, 1
+		}
+
+		if run > s.MaxRepeats && !unicode.IsSpace(r) {
+			return Verdict{Action: s.Action, Reason: fmt.Sprintf("character %q repeated more than %d times", r, s.MaxRepeats)}
+		}
+	}
+
+	var prevWord string
+	run = 0
+	for _, word := range words(item.Text()) {
+		if word == prevWord {
+			run++
+		} else {
+			prevWord, run = word, 1
+		}
+
+		if run > s.MaxRepeats {
+			return Verdict{Action: s.Action, Reason: fmt.Sprintf("word %q repeated more than %d times", word, s.MaxRepeats)}
+		}
+	}
+
+	return Verdict{Action: Allow}
+}
+
+// Length ограничивает длину текста записи в символах
+type Length struct {
+	Action Action
+	Max    int
+}
+
+func (s *Length) Name() string {
+	return LengthStage
+}
+
+func (s *Length) Check(item Item) Verdict {
+	if length := len([]rune(item.Text())); length > s.Max {
+		return Verdict{Action: s.Action, Reason: fmt.Sprintf("very long %s, simvol lenght = %d > %d", item.Kind, length, s.Max)}
+	}
+	return Verdict{Action: Allow}
+}
+
+// words разбивает текст на слова в нижнем регистре
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
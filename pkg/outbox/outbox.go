@@ -0,0 +1,63 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// Outbox - таблица доменных событий, которые хранилище записывает в одной
+// транзакции с изменением. Relay читает события по порядку и передаёт их
+// потребителям. Событиям, которые записаны параллельными транзакциями, место
+// в порядке чтения выдаётся после фиксации, поэтому читатель не пропускает
+// событие, зафиксированное позже события с большим id.
+// Каждый потребитель обрабатывает события строго по порядку мест:
+// пока обработчик возвращает ошибку, следующие события ему не передаются.
+// После обработки события смещение потребителя сдвигается, у постоянного
+// потребителя оно хранится в базе, поэтому после перезапуска обработка
+// продолжается с того же места. Если процесс остановится между обработкой
+// и сохранением смещения, событие будет передано ещё раз, поэтому
+// обработчик постоянного потребителя должен быть идемпотентным по id события
+
+// Store - методы хранилища для outbox, их реализует sqlstore.Storage
+type Store interface {
+	// GetOutboxEvents выдаёт места новым зафиксированным событиям
+	// и возвращает события с местом больше after по порядку мест
+	GetOutboxEvents(ctx context.Context, after uint, limit int) ([]*smodel.OutboxEvent, error)
+	LastOutboxPosition(ctx context.Context) (uint, error)
+	// GetOutboxOffset возвращает смещение потребителя, false - оно не сохранялось
+	GetOutboxOffset(ctx context.Context, consumer string) (uint, bool, error)
+	SetOutboxOffset(ctx context.Context, consumer string, position uint) error
+	// PruneOutbox удаляет события с местом не больше upTo, созданные раньше before
+	PruneOutbox(ctx context.Context, upTo uint, before time.Time) (int, error)
+}
+
+// Consumer - потребитель событий
+type Consumer struct {
+	// имя, под которым хранится смещение
+	Name string
+	// true - смещение хранится в базе и после перезапуска обрабатываются
+	// пропущенные события, false - обрабатываются только события,
+	// записанные после запуска
+	Durable bool
+	Handle  func(ctx context.Context, event *smodel.OutboxEvent) error
+}
+
+// Config - настройки Relay
+type Config struct {
+	// как часто проверяются новые события, если Relay не разбудили
+	PollInterval time.Duration
+	// сколько событий читается за раз
+	BatchSize int
+	// сколько хранятся события, обработанные всеми потребителями
+	Retention time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PollInterval: time.Second,
+		BatchSize:    100,
+		Retention:    24 * time.Hour,
+	}
+}
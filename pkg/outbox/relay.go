@@ -0,0 +1,176 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/sirupsen/logrus"
+)
+
+// как часто удаляются старые события
+const pruneInterval = time.Minute
+
+// Relay передаёт события outbox потребителям в фоне (Run)
+type Relay struct {
+	store     Store
+	cfg       Config
+	consumers []*consumer
+	// будит Run после записи событий, не дожидаясь PollInterval
+	wake chan struct{}
+	// когда последний раз удалялись старые события
+	pruned time.Time
+}
+
+// consumer - потребитель и его текущее смещение
+type consumer struct {
+	Consumer
+	offset uint
+	// смещение прочитано из базы или выставлено на последнее событие
+	started bool
+}
+
+func NewRelay(store Store, cfg Config) *Relay {
+	return &Relay{
+		store: store,
+		cfg:   cfg,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Add добавляет потребителя, вызывается до Run
+func (r *Relay) Add(c Consumer) {
+	r.consumers = append(r.consumers, &consumer{Consumer: c})
+}
+
+// Wake будит Run, чтобы новые события были переданы сразу
+func (r *Relay) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run передаёт события, пока не завершится ctx
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			logrus.Errorf("failed relay outbox events: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// Flush передаёт потребителям все записанные события и удаляет старые.
+// Ошибка одного потребителя не останавливает остальных
+func (r *Relay) Flush(ctx context.Context) error {
+	var firstErr error
+	for _, c := range r.consumers {
+		if err := r.flush(ctx, c); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("consumer %s: %w", c.Name, err)
+		}
+	}
+
+	if time.Since(r.pruned) >= pruneInterval {
+		if err := r.prune(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (r *Relay) flush(ctx context.Context, c *consumer) error {
+	if !c.started {
+		if err := r.start(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	for {
+		events, err := r.store.GetOutboxEvents(ctx, c.offset, r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := r.handle(ctx, c, event); err != nil {
+				return fmt.Errorf("event %d: %w", event.ID, err)
+			}
+		}
+
+		if len(events) < r.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// start выставляет начальное смещение потребителя
+func (r *Relay) start(ctx context.Context, c *consumer) error {
+	if c.Durable {
+		offset, _, err := r.store.GetOutboxOffset(ctx, c.Name)
+		if err != nil {
+			return err
+		}
+		c.offset = offset
+	} else {
+		last, err := r.store.LastOutboxPosition(ctx)
+		if err != nil {
+			return err
+		}
+		c.offset = last
+	}
+
+	c.started = true
+	return nil
+}
+
+// handle передаёт событие потребителю и сдвигает его смещение
+func (r *Relay) handle(ctx context.Context, c *consumer, event *smodel.OutboxEvent) error {
+	if err := c.Handle(ctx, event); err != nil {
+		return err
+	}
+
+	if c.Durable {
+		if err := r.store.SetOutboxOffset(ctx, c.Name, *event.Position); err != nil {
+			return err
+		}
+	}
+	c.offset = *event.Position
+
+	return nil
+}
+
+// prune удаляет события, которые обработаны всеми потребителями
+// и хранятся дольше Retention
+func (r *Relay) prune(ctx context.Context) error {
+	upTo, err := r.store.LastOutboxPosition(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range r.consumers {
+		if !c.started {
+			return nil
+		}
+		if c.offset < upTo {
+			upTo = c.offset
+		}
+	}
+
+	if _, err := r.store.PruneOutbox(ctx, upTo, time.Now().Add(-r.cfg.Retention)); err != nil {
+		return err
+	}
+
+	r.pruned = time.Now()
+	return nil
+}
@@ -0,0 +1,171 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/storage/sqlite"
+)
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := sqlite.NewSQLiteStore(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := store.CreateUser(ctx, smodel.CreateUser{Username: "author"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	post, err := store.CreatePost(ctx, smodel.CreatePost{Title: "title", Content: "content", UserId: user.ID, CommentsEnabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{PollInterval: time.Millisecond, BatchSize: 2, Retention: time.Hour}
+
+	// durable обрабатывает все события, на втором один раз возвращает ошибку
+	var durable []uint
+	failed := false
+	handleDurable := func(ctx context.Context, event *smodel.OutboxEvent) error {
+		if len(durable) == 1 && !failed {
+			failed = true
+			return errors.New("temporary failure")
+		}
+		durable = append(durable, *event.Position)
+		return nil
+	}
+	// live получает только события после запуска
+	var live []*smodel.OutboxEvent
+	handleLive := func(ctx context.Context, event *smodel.OutboxEvent) error {
+		live = append(live, event)
+		return nil
+	}
+
+	relay := NewRelay(store, cfg)
+	relay.Add(Consumer{Name: "durable", Durable: true, Handle: handleDurable})
+	relay.Add(Consumer{Name: "live", Handle: handleLive})
+	if err := relay.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(durable) != 1 || len(live) != 0 {
+		t.Fatal("expected post event only for durable consumer, got", durable, live)
+	}
+
+	var comments []uint
+	for i := 0; i < 3; i++ {
+		comment, err := store.CreateComment(ctx, smodel.CreateComment{PostId: post.ID, UserId: user.ID, Content: "comment"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		comments = append(comments, comment.ID)
+	}
+
+	if err := relay.Flush(ctx); err == nil {
+		t.Fatal("expected consumer error")
+	}
+	if len(durable) != 1 || len(live) != 3 {
+		t.Fatal("expected durable consumer stopped on failed event, got", durable, len(live))
+	}
+	for i, event := range live {
+		var data smodel.CommentCreated
+		if err := json.Unmarshal([]byte(event.Payload), &data); err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != smodel.EventCommentCreated || data.Comment.ID != comments[i] {
+			t.Errorf("expected comment %d, got %s %s", comments[i], event.Type, event.Payload)
+		}
+	}
+
+	if err := relay.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(durable) != 4 {
+		t.Fatal("expected all events after retry, got", durable)
+	}
+	for i := 1; i < len(durable); i++ {
+		if durable[i] <= durable[i-1] {
+			t.Fatal("events out of order", durable)
+		}
+	}
+
+	// после перезапуска durable продолжает с сохранённого смещения
+	comment, err := store.CreateComment(ctx, smodel.CreateComment{PostId: post.ID, UserId: user.ID, Content: "after restart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := NewRelay(store, cfg)
+	var afterRestart []*smodel.OutboxEvent
+	restarted.Add(Consumer{Name: "durable", Durable: true, Handle: func(ctx context.Context, event *smodel.OutboxEvent) error {
+		afterRestart = append(afterRestart, event)
+		return nil
+	}})
+	if err := restarted.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(afterRestart) != 1 {
+		t.Fatal("expected 1 event after restart, got", len(afterRestart))
+	}
+	var data smodel.CommentCreated
+	if err := json.Unmarshal([]byte(afterRestart[0].Payload), &data); err != nil || data.Comment.ID != comment.ID {
+		t.Error("unexpected event after restart", afterRestart[0].Payload, err)
+	}
+
+	// смещение не сдвигается назад
+	if err := store.SetOutboxOffset(ctx, "durable", 1); err != nil {
+		t.Fatal(err)
+	}
+	if offset, ok, err := store.GetOutboxOffset(ctx, "durable"); err != nil || !ok || offset != *afterRestart[0].Position {
+		t.Error("expected offset", *afterRestart[0].Position, "got", offset, ok, err)
+	}
+
+	// удаляются только обработанные всеми события
+	pruned, err := store.PruneOutbox(ctx, durable[1], time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 2 {
+		t.Error("expected 2 pruned events, got", pruned)
+	}
+	if events, err := store.GetOutboxEvents(ctx, 0, 10); err != nil || len(events) != 3 || *events[0].Position != durable[2] {
+		t.Error("unexpected events after prune", events, err)
+	}
+}
+
+func TestRelayLateCommit(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := sqlite.NewSQLiteStore(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handled []uint
+	relay := NewRelay(store, Config{PollInterval: time.Millisecond, BatchSize: 10, Retention: time.Hour})
+	relay.Add(Consumer{Name: "durable", Durable: true, Handle: func(ctx context.Context, event *smodel.OutboxEvent) error {
+		handled = append(handled, event.ID)
+		return nil
+	}})
+
+	// событие с меньшим id зафиксировано после того, как прочитано событие с большим
+	for _, id := range []uint{10, 5} {
+		event := smodel.OutboxEvent{ID: id, Type: smodel.EventPostCreated, Payload: "{}", CreatedAt: time.Now()}
+		if err := store.DB.Create(&event).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := relay.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(handled) != 2 || handled[0] != 10 || handled[1] != 5 {
+		t.Error("expected both events in commit order, got", handled)
+	}
+}
@@ -0,0 +1,53 @@
+package ranking
+
+import (
+	"math"
+	"time"
+)
+
+// Ранги постов и комментариев по голосам, как на Reddit.
+// Ранги не зависят от текущего времени, поэтому хранятся вместе с записью,
+// пересчитываются только при голосовании и по ним можно строить индексы
+
+// z-оценка для доверительной вероятности 95%
+const z = 1.96
+
+// Top - нижняя граница доверительного интервала Уилсона для доли голосов "за".
+// Запись с 10 голосами "за" из 10 выше записи с 1 из 1: при малом числе
+// голосов доля известна неточно, и граница ниже. Без голосов - 0
+func Top(up, down int) float64 {
+	n := float64(up + down)
+	if n == 0 {
+		return 0
+	}
+
+	p := float64(up) / n
+	return (p + z*z/(2*n) - z*math.Sqrt((p*(1-p)+z*z/(4*n))/n)) / (1 + z*z/n)
+}
+
+// начало отсчёта времени в Hot
+var hotEpoch = time.Date(2005, time.December, 8, 7, 46, 43, 0, time.UTC)
+
+// HotDecay - за сколько секунд новизна даёт столько же, сколько
+// десятикратный рост счёта: запись, созданная на 12.5 часов позже,
+// равна записи с в 10 раз большим счётом
+const HotDecay = 45000
+
+// Hot - ранг с затуханием по времени: логарифм счёта (голоса "за" минус "против")
+// плюс время создания. Чем старше запись, тем больше голосов ей нужно,
+// чтобы оказаться выше новой
+func Hot(up, down int, createdAt time.Time) float64 {
+	score := float64(up - down)
+
+	order := math.Log10(math.Max(math.Abs(score), 1))
+	var sign float64
+	switch {
+	case score > 0:
+		sign = 1
+	case score < 0:
+		sign = -1
+	}
+
+	seconds := createdAt.Sub(hotEpoch).Seconds()
+	return sign*order + seconds/HotDecay
+}
@@ -0,0 +1,60 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTop(t *testing.T) {
+	if got := Top(0, 0); got != 0 {
+		t.Error("expected 0 without votes, got", got)
+	}
+
+	// известное значение для 1 голоса "за"
+	if got := Top(1, 0); math.Abs(got-0.2065) > 1e-4 {
+		t.Error("expected 0.2065, got", got)
+	}
+
+	// больше голосов при той же доле - выше
+	if Top(1, 0) >= Top(10, 0) {
+		t.Error("expected 10/10 above 1/1, got", Top(1, 0), Top(10, 0))
+	}
+	// больше доля при том же числе голосов - выше
+	if Top(6, 4) >= Top(8, 2) {
+		t.Error("expected 8/10 above 6/10, got", Top(6, 4), Top(8, 2))
+	}
+	// граница не выходит за [0, 1]
+	for _, votes := range [][2]int{{0, 1}, {0, 100}, {100, 0}, {1000000, 0}} {
+		if got := Top(votes[0], votes[1]); got < 0 || got > 1 {
+			t.Error("expected value in [0, 1] for", votes, "got", got)
+		}
+	}
+}
+
+func TestHot(t *testing.T) {
+	created := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	if got := Hot(0, 0, hotEpoch); got != 0 {
+		t.Error("expected 0 at epoch, got", got)
+	}
+
+	// десятикратный счёт равен новизне на HotDecay секунд
+	later := created.Add(HotDecay * time.Second)
+	if a, b := Hot(10, 0, created), Hot(1, 0, later); math.Abs(a-b) > 1e-9 {
+		t.Error("expected equal ranks, got", a, b)
+	}
+
+	// при равном счёте выше новая
+	if Hot(5, 0, created) >= Hot(5, 0, created.Add(time.Hour)) {
+		t.Error("expected newer post above")
+	}
+	// отрицательный счёт ниже нулевого, нулевой ниже положительного
+	if !(Hot(0, 10, created) < Hot(0, 0, created) && Hot(0, 0, created) < Hot(10, 0, created)) {
+		t.Error("expected negative < zero < positive score")
+	}
+	// счёт 1 и 0 дают одинаковый логарифм, разницу даёт только знак
+	if Hot(1, 0, created) != Hot(0, 0, created) {
+		t.Error("expected score 1 equal to score 0, got", Hot(1, 0, created), Hot(0, 0, created))
+	}
+}
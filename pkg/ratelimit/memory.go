@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// как часто удаляются заполненные корзины
+const sweepInterval = time.Minute
+
+// MemoryLimiter - корзины в памяти процесса
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rule    Rule
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Take(ctx context.Context, key string, rule Rule) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Limit), updated: now}
+		l.buckets[key] = b
+	}
+	b.rule = rule
+	b.refill(now)
+
+	if b.tokens < 1 {
+		return &Error{
+			RetryAfter: time.Duration((1 - b.tokens) * float64(rule.Per) / float64(rule.Limit)),
+			Reason:     "rate limit exceeded",
+		}
+	}
+
+	b.tokens--
+	return nil
+}
+
+// refill добавляет токены, восстановившиеся с прошлого запроса
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 {
+		return
+	}
+
+	b.tokens += elapsed.Seconds() * float64(b.rule.Limit) / b.rule.Per.Seconds()
+	if limit := float64(b.rule.Limit); b.tokens > limit {
+		b.tokens = limit
+	}
+	b.updated = now
+}
+
+// sweep удаляет заполненные корзины: они ничем не отличаются от новых
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rule.Limit) {
+			delete(l.buckets, key)
+		}
+	}
+}
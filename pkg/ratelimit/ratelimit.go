@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ограничение частоты запросов корзинами токенов (token bucket).
+// У каждого ключа (например, пользователь и мутация) своя корзина на Limit
+// токенов, запрос забирает токен, а токены восстанавливаются равномерно:
+// Limit штук за Per. Поэтому можно сделать Limit запросов подряд,
+// а дальше - не чаще одного в Per/Limit
+
+// Rule - Limit запросов за Per
+type Rule struct {
+	Limit int
+	Per   time.Duration
+}
+
+// ParseRule разбирает правило вида "5/1m"
+func ParseRule(s string) (Rule, error) {
+	limit, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("invalid rate %q, expected limit/duration", s)
+	}
+
+	var r Rule
+	var err error
+	if r.Limit, err = strconv.Atoi(limit); err != nil || r.Limit <= 0 {
+		return Rule{}, fmt.Errorf("invalid rate limit %q", limit)
+	}
+	if r.Per, err = time.ParseDuration(per); err != nil || r.Per <= 0 {
+		return Rule{}, fmt.Errorf("invalid rate duration %q", per)
+	}
+
+	return r, nil
+}
+
+// Error - запрос отклонён, повторить его можно через RetryAfter
+type Error struct {
+	RetryAfter time.Duration
+	Reason     string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Reason, e.RetryAfter.Round(time.Millisecond))
+}
+
+// RetryAfterSeconds - RetryAfter в целых секундах с округлением вверх, как в заголовке Retry-After
+func (e *Error) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Limiter - хранилище корзин. In-process реализация - MemoryLimiter,
+// для нескольких экземпляров приложения корзины должны лежать в общем хранилище
+type Limiter interface {
+	// Take забирает токен из корзины key с правилом rule.
+	// Если токенов нет, возвращает *Error со временем до появления токена
+	Take(ctx context.Context, key string, rule Rule) error
+}
+
+// Rules - правила одной мутации: для пользователя и для IP, nil - без ограничения
+type Rules struct {
+	User *Rule
+	IP   *Rule
+}
+
+// Limits - правила по именам мутаций
+type Limits map[string]Rules
+
+// ParseLimits разбирает список вида "createComment:user=5/1m,createComment:ip=30/1m"
+func ParseLimits(spec string) (Limits, error) {
+	limits := make(Limits)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key, rate, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q, expected mutation:user=limit/duration", part)
+		}
+		mutation, by, ok := strings.Cut(key, ":")
+		if !ok || mutation == "" {
+			return nil, fmt.Errorf("invalid rate limit key %q, expected mutation:user or mutation:ip", key)
+		}
+
+		rule, err := ParseRule(rate)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		rules := limits[mutation]
+		switch by {
+		case "user":
+			rules.User = &rule
+		case "ip":
+			rules.IP = &rule
+		default:
+			return nil, fmt.Errorf("invalid rate limit key %q, expected mutation:user or mutation:ip", key)
+		}
+		limits[mutation] = rules
+	}
+
+	return limits, nil
+}
+
+type ipKey struct{}
+
+// IP возвращает адрес клиента запроса, "" если он неизвестен
+func IP(ctx context.Context) string {
+	ip, _ := ctx.Value(ipKey{}).(string)
+	return ip
+}
+
+// Middleware добавляет в контекст адрес клиента. Используется адрес соединения,
+// поэтому за прокси все клиенты будут с адресом прокси
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ipKey{}, ip)))
+	})
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	l := NewMemoryLimiter()
+	l.now = func() time.Time { return now }
+	rule := Rule{Limit: 2, Per: time.Minute}
+
+	// два запроса подряд, третий ждёт токен 30 секунд
+	for i := 0; i < 2; i++ {
+		if err := l.Take(ctx, "a", rule); err != nil {
+			t.Fatal("expected token, got", err)
+		}
+	}
+	var rlErr *Error
+	if err := l.Take(ctx, "a", rule); !errors.As(err, &rlErr) || rlErr.RetryAfter != 30*time.Second || rlErr.RetryAfterSeconds() != 30 {
+		t.Fatal("expected retry after 30s, got", err)
+	}
+
+	// у другого ключа своя корзина
+	if err := l.Take(ctx, "b", rule); err != nil {
+		t.Error("expected token for other key, got", err)
+	}
+
+	// через 10 секунд ждать ещё 20
+	now = now.Add(10 * time.Second)
+	if err := l.Take(ctx, "a", rule); !errors.As(err, &rlErr) || rlErr.RetryAfter != 20*time.Second {
+		t.Error("expected retry after 20s, got", err)
+	}
+
+	now = now.Add(20 * time.Second)
+	if err := l.Take(ctx, "a", rule); err != nil {
+		t.Error("expected restored token, got", err)
+	}
+
+	// заполненные корзины удаляются
+	now = now.Add(time.Hour)
+	if err := l.Take(ctx, "c", rule); err != nil {
+		t.Error("expected token, got", err)
+	}
+	if len(l.buckets) != 1 {
+		t.Error("expected only new bucket after sweep, got", len(l.buckets))
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("createComment:user=5/1m, createComment:ip=30/1m,createPost:ip=1/1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comment := limits["createComment"]
+	if comment.User == nil || *comment.User != (Rule{Limit: 5, Per: time.Minute}) || comment.IP == nil || comment.IP.Limit != 30 {
+		t.Error("expected user and ip rules for createComment, got", comment)
+	}
+	if post := limits["createPost"]; post.User != nil || post.IP == nil {
+		t.Error("expected only ip rule for createPost, got", post)
+	}
+
+	for _, spec := range []string{"createComment", "createComment=5/1m", "createComment:host=5/1m", "createComment:user=0/1m", "createComment:user=5/x"} {
+		if _, err := ParseLimits(spec); err == nil {
+			t.Error("expected error for", spec)
+		}
+	}
+}
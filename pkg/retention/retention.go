@@ -0,0 +1,74 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Удалённые посты и комментарии можно восстановить в течение окна хранения.
+// Purger в фоне очищает записи, удалённые раньше: их текст и версии стираются,
+// а сами записи остаются заглушками, чтобы ответы на них не терялись
+
+// Store - метод хранилища для очистки, его реализуют все хранилища
+type Store interface {
+	// PurgeDeleted стирает записи, удалённые раньше before, и возвращает их количество
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
+}
+
+// Config - настройки Purger
+type Config struct {
+	// сколько удалённые записи можно восстановить
+	Window time.Duration
+	// как часто ищутся записи для очистки
+	Interval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Window:   30 * 24 * time.Hour,
+		Interval: time.Hour,
+	}
+}
+
+// Purger очищает удалённые записи старше окна хранения в фоне (Run)
+type Purger struct {
+	store Store
+	cfg   Config
+}
+
+func NewPurger(store Store, cfg Config) *Purger {
+	return &Purger{store: store, cfg: cfg}
+}
+
+// Run очищает записи сразу и затем каждые Interval до отмены ctx
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Purge(ctx); err != nil && ctx.Err() == nil {
+			logrus.Errorf("failed purge deleted records: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge очищает записи, удалённые раньше окна хранения
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	purged, err := p.store.PurgeDeleted(ctx, time.Now().Add(-p.cfg.Window))
+	if err != nil {
+		return 0, err
+	}
+
+	if purged > 0 {
+		logrus.Infof("purged %d deleted records", purged)
+	}
+	return purged, nil
+}
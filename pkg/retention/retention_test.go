@@ -0,0 +1,85 @@
+package retention
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/storage/sqlite"
+)
+
+func TestPurger(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := sqlite.NewSQLiteStore(filepath.Join(t.TempDir(), "retention.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := store.CreateUser(ctx, smodel.CreateUser{Username: "author"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	post, err := store.CreatePost(ctx, smodel.CreatePost{Title: "title", Content: "content", UserId: user.ID, CommentsEnabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment, err := store.CreateComment(ctx, smodel.CreateComment{PostId: post.ID, UserId: user.ID, Content: "comment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := store.CreateComment(ctx, smodel.CreateComment{PostId: post.ID, ParentId: &comment.ID, UserId: user.ID, Content: "reply"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := smodel.Target{Type: smodel.TargetComment, ID: comment.ID}
+	if err := store.SoftDelete(ctx, smodel.SoftDelete{Target: target, UserId: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// запись удалена позже начала окна и не очищается
+	purged, err := NewPurger(store, Config{Window: time.Hour, Interval: time.Hour}).Purge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 0 {
+		t.Fatal("expected nothing purged inside the window, got", purged)
+	}
+
+	purged, err = NewPurger(store, Config{Window: 0, Interval: time.Hour}).Purge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Fatal("expected one purged comment, got", purged)
+	}
+
+	// текст стёрт, а заглушка и ответ на неё остались
+	got, err := store.GetComments(ctx, 10, 0, smodel.CommentSortDefault, comment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Content != "" || got.PurgedAt == nil || got.RemovedAt == nil {
+		t.Fatal("expected purged tombstone, got", got.Content, got.PurgedAt, got.RemovedAt)
+	}
+	if got.ReplyPage.TotalCount != 1 || got.ReplyPage.Comms[0].ID != reply.ID {
+		t.Fatal("expected reply kept under tombstone, got", got.ReplyPage)
+	}
+
+	// очищенную запись нельзя восстановить
+	if err := store.Restore(ctx, smodel.Restore{Target: target}); err == nil {
+		t.Fatal("expected restore of purged comment to fail")
+	}
+
+	// повторная очистка ничего не находит
+	purged, err = NewPurger(store, Config{Window: 0, Interval: time.Hour}).Purge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 0 {
+		t.Fatal("expected nothing purged twice, got", purged)
+	}
+}
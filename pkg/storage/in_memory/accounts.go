@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Посты, комментарии и доски хранят копию автора, поэтому при удалении
+// аккаунта анонимный пользователь записывается во все копии. Аккаунт
+// удаляется под m.mu, а записи пользователя меняются под блокировками
+// их шардов по одной. Доставки вебхуков хранят копии записей вместе
+// с username автора, поэтому они удаляются
+
+// anonymization - удаление аккаунта в журнале
+type anonymization struct {
+	UserId        uint      `json:"userId"`
+	At            time.Time `json:"at"`
+	RemoveContent bool      `json:"removeContent,omitempty"`
+}
+
+func (m *MemoryStorage) AnonymizeUser(ctx context.Context, a smodel.AnonymizeUser) (*smodel.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[a.UserId]
+	if !ok {
+		return nil, errors.New(u.ErrorUserId(a.UserId))
+	}
+	if user.AnonymizedAt != nil {
+		return nil, errors.New(u.ErrorUserDeleted(a.UserId))
+	}
+
+	rec := anonymization{UserId: a.UserId, At: time.Now(), RemoveContent: a.RemoveContent}
+	if err := m.log(record{Op: opAnonymize, Anonymization: &rec}); err != nil {
+		return nil, err
+	}
+	m.applyAnonymization(rec)
+
+	user = m.users[a.UserId]
+	return &user, nil
+}
+
+// applyAnonymization делает пользователя анонимным во всех копиях и при
+// RemoveContent очищает его записи. Вызывается под m.mu
+func (m *MemoryStorage) applyAnonymization(a anonymization) {
+	user, ok := m.users[a.UserId]
+	if !ok {
+		return
+	}
+
+	old := user.Username
+	at := a.At
+	user.Username, user.AnonymizedAt = smodel.AnonymousUsername(user.ID), &at
+	m.users[user.ID] = user
+
+	// прежний username переходит к следующему пользователю с таким же
+	if m.usernames[old] == user.ID {
+		delete(m.usernames, old)
+		for id, other := range m.users {
+			if other.Username == old && (m.usernames[old] == 0 || id < m.usernames[old]) {
+				m.usernames[old] = id
+			}
+		}
+	}
+	if _, ok := m.usernames[user.Username]; !ok {
+		m.usernames[user.Username] = user.ID
+	}
+
+	m.webhooksMu.Lock()
+	m.applyDropUserDeliveries(user.ID)
+	m.webhooksMu.Unlock()
+
+	for id, board := range m.boards {
+		for i := range board.AllowedPosters {
+			if board.AllowedPosters[i].ID == user.ID {
+				board.AllowedPosters[i] = user
+			}
+		}
+		m.boards[id] = board
+	}
+
+	for _, id := range m.userPosts[user.ID] {
+		shard := m.posts[id]
+		shard.mu.Lock()
+		shard.post.User = user
+		if a.RemoveContent {
+			m.removeContent(shard, smodel.Target{Type: smodel.TargetPost, ID: id}, a.At)
+		}
+		shard.mu.Unlock()
+	}
+
+	m.commentsMu.RLock()
+	comments := append([]uint(nil), m.userComments[user.ID]...)
+	m.commentsMu.RUnlock()
+
+	for _, id := range comments {
+		shard, ok := m.commentShard(id)
+		if !ok {
+			continue
+		}
+		shard.mu.Lock()
+		comment := shard.comments[id]
+		comment.User = user
+		shard.comments[id] = comment
+		if a.RemoveContent {
+			m.removeContent(shard, smodel.Target{Type: smodel.TargetComment, ID: id}, a.At)
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// removeContent помечает запись удалённой, если она ещё не удалена, и очищает её.
+// Вызывается под m.mu и блокировкой шарда
+func (m *MemoryStorage) removeContent(shard *postShard, target smodel.Target, at time.Time) {
+	_, removedAt, purgedAt := shard.removal(target)
+	if removedAt == nil {
+		m.applyRemoval(shard, removal{Target: target, At: &at})
+	}
+	if purgedAt == nil {
+		m.applyPurge(shard, purge{Targets: []smodel.Target{target}, At: at})
+	}
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+func (m *MemoryStorage) CreateBoard(ctx context.Context, b smodel.CreateBoard) (*smodel.Board, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	posters := make([]smodel.User, 0, len(b.AllowedPosters))
+	for _, id := range b.AllowedPosters {
+		user, ok := m.users[id]
+		if !ok {
+			return nil, errors.New(u.ErrorUserId(id))
+		}
+		posters = append(posters, user)
+	}
+	sort.Slice(posters, func(i, j int) bool { return posters[i].ID < posters[j].ID })
+
+	board := smodel.Board{
+		ID:                       m.boardSeq.next(),
+		Name:                     b.Name,
+		Description:              b.Description,
+		CommentsEnabledByDefault: b.CommentsEnabledByDefault,
+		MaxCommentLength:         b.MaxCommentLength,
+		AllowedPosters:           posters,
+		CreatedAt:                time.Now(),
+	}
+
+	if err := m.log(record{Op: opCreateBoard, Board: &board}); err != nil {
+		return nil, err
+	}
+	m.applyBoard(board)
+
+	return &board, nil
+}
+
+// applyBoard добавляет доску, вызывается под m.mu
+func (m *MemoryStorage) applyBoard(board smodel.Board) {
+	m.boardSeq.advance(board.ID)
+	m.boards[board.ID] = board
+	m.boardIds = append(m.boardIds, board.ID)
+}
+
+func (m *MemoryStorage) GetBoard(ctx context.Context, id uint) (*smodel.Board, error) {
+	m.mu.RLock()
+	board, ok := m.boards[id]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, errors.New(u.ErrorBoardId(id))
+	}
+
+	return &board, nil
+}
+
+func (m *MemoryStorage) GetBoards(ctx context.Context, limit, offset int) (*smodel.BoardPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := page(m.boardIds, limit, offset)
+	boards := make([]*smodel.Board, 0, len(ids))
+	for _, id := range ids {
+		board := m.boards[id]
+		boards = append(boards, &board)
+	}
+
+	return &smodel.BoardPage{
+		Boards:     boards,
+		TotalCount: len(m.boardIds),
+	}, nil
+}
+
+// checkPoster проверяет, что доска существует и пользователь может
+// создавать на ней посты, вызывается под m.mu
+func (m *MemoryStorage) checkPoster(boardId, userId uint) error {
+	board, ok := m.boards[boardId]
+	if !ok {
+		return errors.New(u.ErrorBoardId(boardId))
+	}
+
+	// ограничений нет
+	if len(board.AllowedPosters) == 0 {
+		return nil
+	}
+
+	for _, user := range board.AllowedPosters {
+		if user.ID == userId {
+			return nil
+		}
+	}
+
+	return errors.New(u.ErrorPosterNotAllowed(userId, boardId))
+}
+
+// checkCommentLength проверяет длину комментария по настройкам доски
+func checkCommentLength(board smodel.Board, content string) error {
+	if length := len([]rune(content)); board.MaxCommentLength > 0 && length > board.MaxCommentLength {
+		return errors.New(u.ErrorCommentTooLong(length, board.MaxCommentLength))
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Пометка об удалении лежит в самой записи и меняется под блокировкой шарда.
+// Удалённые посты дополнительно собраны в m.removedPosts, чтобы getPosts
+// пропускал их без блокировки шардов, поэтому пост удаляется и
+// восстанавливается ещё и под m.mu
+
+// removal - удаление или восстановление записи в журнале
+type removal struct {
+	Target smodel.Target `json:"target"`
+	// время удаления, nil - запись восстановлена
+	At *time.Time `json:"at,omitempty"`
+}
+
+// purge - очистка удалённых записей одного поста в журнале
+type purge struct {
+	Targets []smodel.Target `json:"targets"`
+	At      time.Time       `json:"at"`
+}
+
+func (m *MemoryStorage) SoftDelete(ctx context.Context, d smodel.SoftDelete) error {
+	shard, err := m.targetShard(d.Target)
+	if err != nil {
+		return err
+	}
+
+	if d.Target.Type == smodel.TargetPost {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	userId, removedAt, _ := shard.removal(d.Target)
+	if !d.Moderator && userId != d.UserId {
+		return errors.New(u.ErrorNotAuthor(d.UserId, d.Target))
+	}
+	if removedAt != nil {
+		return errors.New(u.ErrorRemoved(d.Target))
+	}
+
+	now := time.Now()
+	rec := removal{Target: d.Target, At: &now}
+	if err := m.log(record{Op: opRemove, Removal: &rec}); err != nil {
+		return err
+	}
+	m.applyRemoval(shard, rec)
+
+	return nil
+}
+
+func (m *MemoryStorage) Restore(ctx context.Context, r smodel.Restore) error {
+	shard, err := m.targetShard(r.Target)
+	if err != nil {
+		return err
+	}
+
+	if r.Target.Type == smodel.TargetPost {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	_, removedAt, purgedAt := shard.removal(r.Target)
+	if removedAt == nil {
+		return errors.New(u.ErrorNotRemoved(r.Target))
+	}
+	if purgedAt != nil || !removedAt.After(r.DeletedAfter) {
+		return errors.New(u.ErrorRestoreExpired(r.Target))
+	}
+
+	rec := removal{Target: r.Target}
+	if err := m.log(record{Op: opRemove, Removal: &rec}); err != nil {
+		return err
+	}
+	m.applyRemoval(shard, rec)
+
+	return nil
+}
+
+// removal возвращает автора записи и её состояние удаления.
+// Вызывается под блокировкой шарда
+func (s *postShard) removal(target smodel.Target) (uint, *time.Time, *time.Time) {
+	if target.Type == smodel.TargetPost {
+		return s.post.UserID, s.post.RemovedAt, s.post.PurgedAt
+	}
+
+	comment := s.comments[target.ID]
+	return comment.UserID, comment.RemovedAt, comment.PurgedAt
+}
+
+// applyRemoval ставит или снимает пометку об удалении. Вызывается под
+// блокировкой шарда, для поста - ещё и под m.mu
+func (m *MemoryStorage) applyRemoval(shard *postShard, r removal) {
+	if r.Target.Type == smodel.TargetPost {
+		shard.post.RemovedAt = r.At
+		if r.At != nil {
+			m.removedPosts[r.Target.ID] = true
+		} else {
+			delete(m.removedPosts, r.Target.ID)
+		}
+		return
+	}
+
+	comment := shard.comments[r.Target.ID]
+	comment.RemovedAt = r.At
+	shard.comments[r.Target.ID] = comment
+}
+
+// restoreRemoval применяет удаление или восстановление при восстановлении хранилища
+func (m *MemoryStorage) restoreRemoval(r removal) error {
+	shard, err := m.targetShard(r.Target)
+	if err != nil {
+		return err
+	}
+
+	m.applyRemoval(shard, r)
+	return nil
+}
+
+func (m *MemoryStorage) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	m.mu.RLock()
+	shards := make([]*postShard, 0, len(m.postIds))
+	for _, id := range m.postIds {
+		shards = append(shards, m.posts[id])
+	}
+	m.mu.RUnlock()
+
+	purged := 0
+	for _, shard := range shards {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+
+		n, err := m.purgeShard(shard, before)
+		if err != nil {
+			return purged, err
+		}
+		purged += n
+	}
+
+	return purged, nil
+}
+
+// purgeShard очищает удалённые раньше before пост и комментарии шарда
+func (m *MemoryStorage) purgeShard(shard *postShard, before time.Time) (int, error) {
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	expired := func(removedAt, purgedAt *time.Time) bool {
+		return removedAt != nil && purgedAt == nil && removedAt.Before(before)
+	}
+
+	var p purge
+	if expired(shard.post.RemovedAt, shard.post.PurgedAt) {
+		p.Targets = append(p.Targets, smodel.Target{Type: smodel.TargetPost, ID: shard.id})
+	}
+	for id, comment := range shard.comments {
+		if expired(comment.RemovedAt, comment.PurgedAt) {
+			p.Targets = append(p.Targets, smodel.Target{Type: smodel.TargetComment, ID: id})
+		}
+	}
+	if len(p.Targets) == 0 {
+		return 0, nil
+	}
+
+	p.At = time.Now()
+	if err := m.log(record{Op: opPurge, Purge: &p}); err != nil {
+		return 0, err
+	}
+	m.applyPurge(shard, p)
+
+	return len(p.Targets), nil
+}
+
+// applyPurge стирает текст и версии записей, вызывается под блокировкой шарда
+func (m *MemoryStorage) applyPurge(shard *postShard, p purge) {
+	for _, target := range p.Targets {
+		purgedAt := p.At
+		delete(shard.revisions, target)
+
+		if target.Type == smodel.TargetPost {
+			m.search.remove(docKey{id: target.ID}, weighted{shard.post.Title, titleWeight}, weighted{shard.post.Content, 1})
+			shard.post.Title, shard.post.Content, shard.post.PurgedAt = "", "", &purgedAt
+			continue
+		}
+
+		comment := shard.comments[target.ID]
+		m.search.remove(docKey{comment: true, id: target.ID}, weighted{comment.Content, 1})
+		comment.Content, comment.PurgedAt = "", &purgedAt
+		shard.comments[target.ID] = comment
+	}
+}
+
+// restorePurge применяет очистку при восстановлении хранилища
+func (m *MemoryStorage) restorePurge(p purge) error {
+	if len(p.Targets) == 0 {
+		return nil
+	}
+
+	shard, err := m.targetShard(p.Targets[0])
+	if err != nil {
+		return err
+	}
+
+	m.applyPurge(shard, p)
+	return nil
+}
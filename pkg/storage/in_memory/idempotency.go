@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// как часто удаляются истёкшие ключи идемпотентности
+const keysSweepInterval = time.Minute
+
+// keyID - ключ идемпотентности пользователя
+type keyID struct {
+	userId uint
+	key    string
+}
+
+// lockKey ждёт, пока завершится другой запрос с тем же ключом пользователя,
+// и занимает ключ до вызова возвращённой функции. Без ключа ничего не занимает
+func (m *MemoryStorage) lockKey(ctx context.Context, userId uint, key *smodel.Idempotency) (func(), error) {
+	if key == nil {
+		return func() {}, nil
+	}
+	id := keyID{userId, key.Key}
+
+	for {
+		m.keysMu.Lock()
+		busy, ok := m.pendingKeys[id]
+		if !ok {
+			done := make(chan struct{})
+			m.pendingKeys[id] = done
+			m.keysMu.Unlock()
+
+			return func() {
+				m.keysMu.Lock()
+				delete(m.pendingKeys, id)
+				m.keysMu.Unlock()
+				close(done)
+			}, nil
+		}
+		m.keysMu.Unlock()
+
+		select {
+		case <-busy:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// findKey возвращает id записи, созданной по действующему ключу пользователя,
+// 0 - ключа нет. Для других данных запроса возвращает ошибку.
+// Вызывается, когда ключ занят запросом (см. lockKey)
+func (m *MemoryStorage) findKey(userId uint, key smodel.Idempotency, typ smodel.TargetType, now time.Time) (uint, error) {
+	m.keysMu.Lock()
+	defer m.keysMu.Unlock()
+
+	m.sweepKeys(now)
+
+	saved, ok := m.keys[keyID{userId, key.Key}]
+	if !ok || !now.Before(saved.ExpiresAt) {
+		return 0, nil
+	}
+	if saved.Hash != key.Hash || saved.TargetType != typ {
+		return 0, errors.New(u.ErrorIdempotencyKeyReused(key.Key))
+	}
+
+	return saved.TargetID, nil
+}
+
+// newKey - ключ для записи, которая будет создана по запросу
+func newKey(userId uint, key *smodel.Idempotency, typ smodel.TargetType, id uint, now time.Time) *smodel.IdempotencyKey {
+	if key == nil {
+		return nil
+	}
+
+	return &smodel.IdempotencyKey{
+		UserID:     userId,
+		Key:        key.Key,
+		Hash:       key.Hash,
+		TargetType: typ,
+		TargetID:   id,
+		ExpiresAt:  now.Add(key.TTL),
+	}
+}
+
+// applyKey запоминает ключ
+func (m *MemoryStorage) applyKey(key *smodel.IdempotencyKey) {
+	if key == nil {
+		return
+	}
+
+	m.keysMu.Lock()
+	defer m.keysMu.Unlock()
+	m.keys[keyID{key.UserID, key.Key}] = *key
+}
+
+// sweepKeys удаляет истёкшие ключи, вызывается под keysMu
+func (m *MemoryStorage) sweepKeys(now time.Time) {
+	if now.Sub(m.lastKeysSweep) < keysSweepInterval {
+		return
+	}
+	m.lastKeysSweep = now
+
+	for id, key := range m.keys {
+		if !now.Before(key.ExpiresAt) {
+			delete(m.keys, id)
+		}
+	}
+}
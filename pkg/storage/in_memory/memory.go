@@ -1,231 +1,874 @@
 package memory
 
 import (
+	"context"
 	"errors"
-
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/ranking"
 	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
 )
 
+// Блокировки хранилища:
+//   - mu защищает справочники: пользователей, доски и посты (шарды). Берётся только
+//     на время поиска или добавления записи в справочник;
+//   - у каждого поста свой шард со своей блокировкой, под которой лежат
+//     сам пост, все его комментарии, реакции и голоса. Чтение ветки одного поста
+//     не мешает записи комментариев в другие посты;
+//   - commentsMu защищает индекс комментарий -> шард и индекс комментариев
+//     по авторам;
+//   - reportsMu защищает жалобы;
+//   - keysMu защищает ключи идемпотентности и занятые ключи. Запрос с ключом
+//     занимает его до создания записи (см. lockKey), поэтому запросы с одним
+//     ключом пользователя выполняются по очереди, а с разными - одновременно;
+//   - notificationsMu защищает уведомления;
+//   - webhooksMu защищает вебхуки и их доставки.
+//
+// Порядок захвата: занятый ключ идемпотентности, mu, затем блокировка шарда, затем reportsMu. commentsMu,
+// keysMu, notificationsMu и webhooksMu берутся последними и ни одна блокировка не берётся под ними. Блокировки не берутся повторно:
+// рекурсивный обход комментариев выполняется под одной блокировкой шарда
 type MemoryStorage struct {
-	posts   map[uint]smodel.Post
-    users   map[uint]smodel.User
-    comments map[uint]smodel.Comment
-
-	commReply map[int][]int
-	// commReply является вспомогательной структурой.
-	// Она создана для того, чтобы получать комментарии, которые относятся к посту/другому комментарию по id
-	// Для однозначного соответствия, id постов в эту мапу заносятся как -id (отрицательные)
-	// Иначе происходили бы коллизии между id постов и комментариев
+	mu      sync.RWMutex
+	users   map[uint]smodel.User
+	posts   map[uint]*postShard
+	postIds []uint // id постов в порядке создания
+
+	// первый созданный пользователь с таким username
+	usernames map[string]uint
+	// id постов пользователя в порядке создания
+	userPosts map[uint][]uint
+	// id постов с тегом в порядке создания
+	tagPosts map[string][]uint
+	// id постов доски в порядке создания
+	boardPosts map[uint][]uint
+	// удалённые посты, не выдаются в списке постов
+	removedPosts map[uint]bool
+
+	boards   map[uint]smodel.Board
+	boardIds []uint // id досок в порядке создания
+
+	commentsMu sync.RWMutex
+	comments   map[uint]*postShard
+	// id комментариев пользователя по возрастанию
+	userComments map[uint][]uint
+
+	reportsMu sync.RWMutex
+	reports   map[uint]smodel.Report
+	reportIds []uint // id жалоб в порядке создания
+	// id жалоб на пост или комментарий
+	targetReports map[smodel.Target][]uint
+
+	notificationsMu sync.RWMutex
+	notifications   map[uint]smodel.Notification
+	// id уведомлений пользователя по возрастанию
+	userNotifications map[uint][]uint
+
+	webhooksMu sync.RWMutex
+	webhooks   map[uint]smodel.Webhook
+	webhookIds []uint // id вебхуков в порядке создания
+	deliveries map[uint]smodel.WebhookDelivery
+	// id доставок по возрастанию, всех и ожидающих попытки
+	deliveryIds       []uint
+	pendingDeliveries []uint
+	// до какого времени доставка выбрана для отправки, в журнал не пишется
+	deliveryLeases map[uint]time.Time
+
+	keysMu sync.Mutex
+	// ключи идемпотентности, истёкшие удаляются не сразу
+	keys map[keyID]smodel.IdempotencyKey
+	// ключи запросов, которые сейчас создают запись, канал закрывается по окончании
+	pendingKeys   map[keyID]chan struct{}
+	lastKeysSweep time.Time
+
+	// полнотекстовый индекс постов и комментариев
+	search *searchIndex
+
+	// последовательности id сущностей
+	userSeq    sequence
+	postSeq    sequence
+	commentSeq sequence
+	boardSeq   sequence
+	reportSeq  sequence
+
+	notificationSeq sequence
+	webhookSeq      sequence
+	deliverySeq     sequence
+	revisionSeq     sequence
+
+	// сохранение на диск, nil если выключено
+	persist *persistence
+}
 
-    mu      sync.RWMutex
+// postShard - пост со всеми комментариями под своей блокировкой
+type postShard struct {
+	// id поста, не меняется и читается без блокировки
+	id uint
+	// id доски поста, 0 - пост без доски. Не меняется и читается без блокировки
+	board uint
+
+	mu       sync.RWMutex
+	post     smodel.Post
+	comments map[uint]smodel.Comment
+
+	// replies создан для того, чтобы получать ответы на пост/другой комментарий по id.
+	// Ответы на сам пост лежат под ключом 0, id комментариев начинаются с 1
+	replies map[uint][]uint
+
+	// реакции на пост и его комментарии
+	reactions map[smodel.Target]*reactionSet
+	// голоса за пост и его комментарии по пользователям
+	votes map[smodel.Target]map[uint]smodel.Vote
+	// время последнего комментария пользователя к посту, для медленного режима
+	lastComment map[uint]time.Time
+	// версии поста и его комментариев по возрастанию номера
+	revisions map[smodel.Target][]smodel.Revision
 }
 
-func NewInMemoryStore() *MemoryStorage {
-	return &MemoryStorage{
-		posts:    make(map[uint]smodel.Post),
-        users:    make(map[uint]smodel.User),
-        comments: make(map[uint]smodel.Comment),
-		commReply: make(map[int][]int),
+// NewInMemoryStore создаёт хранилище. Если включено сохранение на диск
+// (WithPersistence), то состояние восстанавливается из снимка и журнала
+func NewInMemoryStore(opts ...Option) (*MemoryStorage, error) {
+	m := &MemoryStorage{
+		users:         make(map[uint]smodel.User),
+		posts:         make(map[uint]*postShard),
+		usernames:     make(map[string]uint),
+		userPosts:     make(map[uint][]uint),
+		tagPosts:      make(map[string][]uint),
+		boardPosts:    make(map[uint][]uint),
+		removedPosts:  make(map[uint]bool),
+		boards:        make(map[uint]smodel.Board),
+		comments:      make(map[uint]*postShard),
+		userComments:  make(map[uint][]uint),
+		reports:       make(map[uint]smodel.Report),
+		targetReports: make(map[smodel.Target][]uint),
+		keys:          make(map[keyID]smodel.IdempotencyKey),
+		pendingKeys:   make(map[keyID]chan struct{}),
+		search:        newSearchIndex(),
+
+		notifications:     make(map[uint]smodel.Notification),
+		userNotifications: make(map[uint][]uint),
+		webhooks:          make(map[uint]smodel.Webhook),
+		deliveries:        make(map[uint]smodel.WebhookDelivery),
+		deliveryLeases:    make(map[uint]time.Time),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.persist != nil {
+		if err := m.restore(); err != nil {
+			return nil, err
+		}
 	}
+
+	return m, nil
 }
 
-func (m *MemoryStorage) CreatePost(p smodel.CreatePost) (*smodel.Post, error) {
+func (m *MemoryStorage) CreatePost(ctx context.Context, p smodel.CreatePost) (*smodel.Post, error) {
+	unlockKey, err := m.lockKey(ctx, p.UserId, p.Idempotency)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockKey()
+
 	m.mu.Lock()
-    defer m.mu.Unlock()
+	defer m.mu.Unlock()
 
 	user, ok := m.users[p.UserId]
 	if !ok {
 		return nil, errors.New(u.ErrorUserId(p.UserId))
 	}
+	if user.AnonymizedAt != nil {
+		return nil, errors.New(u.ErrorUserDeleted(p.UserId))
+	}
+
+	now := time.Now()
+
+	// повтор по ключу идемпотентности возвращает созданный пост
+	if p.Idempotency != nil {
+		id, err := m.findKey(p.UserId, *p.Idempotency, smodel.TargetPost, now)
+		if err != nil {
+			return nil, err
+		}
+		if id != 0 {
+			shard, ok := m.posts[id]
+			if !ok {
+				return nil, errors.New(u.ErrorPostId(id))
+			}
+
+			shard.mu.RLock()
+			defer shard.mu.RUnlock()
+
+			post := shard.postCopy()
+			post.Replayed = true
+			return post, nil
+		}
+	}
 
-	id := uint(len(m.posts)) + 1 // +1 чтобы id совпадал с бд и не было коллизий в функции getComm
+	// проверка настроек доски
+	if p.BoardId != nil {
+		if err := m.checkPoster(*p.BoardId, p.UserId); err != nil {
+			return nil, err
+		}
+	}
+
+	id := m.postSeq.next()
 
 	post := smodel.Post{
-		ID: id,
-		Title: p.Title,
-		Content: p.Content,
-		UserID: p.UserId,
-		User: user,
+		ID:              id,
+		Title:           p.Title,
+		Content:         p.Content,
+		UserID:          p.UserId,
 		CommentsEnabled: p.CommentsEnabled,
-		CommPage: &smodel.CommPage{
-			Comms: []*smodel.Comment{},
-			TotalCount: 0,
-		},
+		BoardID:         p.BoardId,
+		SlowModeSeconds: p.SlowModeSeconds,
+		CreatedAt:       now,
+		Tags:            make([]smodel.Tag, 0, len(p.Tags)),
+	}
+	for _, name := range p.Tags {
+		post.Tags = append(post.Tags, smodel.Tag{Name: name})
 	}
+	sort.Slice(post.Tags, func(i, j int) bool { return post.Tags[i].Name < post.Tags[j].Name })
 
-    m.posts[id] = post
+	key := newKey(p.UserId, p.Idempotency, smodel.TargetPost, id, now)
+	if err := m.log(record{Op: opCreatePost, Post: &post, Key: key}); err != nil {
+		return nil, err
+	}
+	shard := m.applyPost(post)
+	m.applyKey(key)
 
-    return &post, nil
+	return shard.postCopy(), nil
 }
 
-func (m *MemoryStorage) CreateComment(c smodel.CreateComment) (*smodel.Comment, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// applyPost добавляет пост, вызывается под m.mu
+func (m *MemoryStorage) applyPost(post smodel.Post) *postShard {
+	m.postSeq.advance(post.ID)
+	post.User = m.users[post.UserID]
+	// счётчики считаются заново при применении комментариев поста,
+	// голоса - при применении голосов
+	post.CommentCount, post.TopLevelCommentCount, post.LastCommentAt = 0, 0, nil
+	post.Votes = smodel.Votes{HotRank: ranking.Hot(0, 0, post.CreatedAt)}
+
+	shard := &postShard{
+		id:        post.ID,
+		post:      post,
+		comments:  make(map[uint]smodel.Comment),
+		replies:   make(map[uint][]uint),
+		reactions: make(map[smodel.Target]*reactionSet),
+		votes:     make(map[smodel.Target]map[uint]smodel.Vote),
+
+		lastComment: make(map[uint]time.Time),
+		revisions:   make(map[smodel.Target][]smodel.Revision),
+	}
+	if post.BoardID != nil {
+		shard.board = *post.BoardID
+		m.boardPosts[shard.board] = append(m.boardPosts[shard.board], post.ID)
+	}
 
-	// проверка существования автора
-	user, ok := m.users[c.UserId]
-	if !ok {
-		return nil, errors.New(u.ErrorUserId(c.UserId))
+	m.posts[post.ID] = shard
+	m.postIds = append(m.postIds, post.ID)
+	if post.RemovedAt != nil {
+		m.removedPosts[post.ID] = true
+	}
+	m.userPosts[post.UserID] = append(m.userPosts[post.UserID], post.ID)
+	for _, tag := range post.Tags {
+		m.tagPosts[tag.Name] = append(m.tagPosts[tag.Name], post.ID)
 	}
+	m.search.add(docKey{id: post.ID}, weighted{post.Title, titleWeight}, weighted{post.Content, 1})
+
+	return shard
+}
 
+func (m *MemoryStorage) CreateComment(ctx context.Context, c smodel.CreateComment) (*smodel.Comment, error) {
+	m.mu.RLock()
+	// проверка существования автора
+	user, userExist := m.users[c.UserId]
 	// проверка существования поста
-	post, exist := m.posts[c.PostId]
-	if !exist {
+	shard, postExist := m.posts[c.PostId]
+	// доски не меняются, поэтому настройки можно проверить без блокировки шарда
+	var board smodel.Board
+	if postExist {
+		board = m.boards[shard.board]
+	}
+	// упомянутые пользователи ищутся до блокировки шарда, под ней m.mu не берётся
+	mentioned := m.mentionedUsers(c.Content)
+	m.mu.RUnlock()
+
+	if !userExist {
+		return nil, errors.New(u.ErrorUserId(c.UserId))
+	}
+	if user.AnonymizedAt != nil {
+		return nil, errors.New(u.ErrorUserDeleted(c.UserId))
+	}
+	if !postExist {
 		return nil, errors.New(u.ErrorPostId(c.PostId))
 	}
 
+	now := time.Now()
+
+	unlockKey, err := m.lockKey(ctx, c.UserId, c.Idempotency)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockKey()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	// повтор по ключу идемпотентности возвращает созданный комментарий
+	if c.Idempotency != nil {
+		id, err := m.findKey(c.UserId, *c.Idempotency, smodel.TargetComment, now)
+		if err != nil {
+			return nil, err
+		}
+		if id != 0 {
+			comment, ok := shard.comments[id]
+			if !ok {
+				return nil, errors.New(u.ErrorCommId(id))
+			}
+			comment.Replayed = true
+			return &comment, nil
+		}
+	}
+
+	// удалённый пост нельзя комментировать
+	if shard.post.RemovedAt != nil {
+		return nil, errors.New(u.ErrorRemoved(smodel.Target{Type: smodel.TargetPost, ID: c.PostId}))
+	}
+
 	// проверка что можно оставлять комментарии
-	if !post.CommentsEnabled {
+	if !shard.post.CommentsEnabled {
 		return nil, errors.New(u.ErrorCommDisable())
 	}
 
+	// проверка длины комментария по настройкам доски
+	if err := checkCommentLength(board, c.Content); err != nil {
+		return nil, err
+	}
+
+	// проверка медленного режима
+	if err := shard.checkSlowMode(c.UserId, now); err != nil {
+		return nil, err
+	}
+
 	// если ответ на другой комментарий
 	if c.ParentId != nil {
-		parentComm, ok := m.comments[*c.ParentId]
+		// родитель из другого поста лежит в другом шарде
+		parentShard, ok := m.commentShard(*c.ParentId)
 		// проверка существования родительского поста
 		if !ok {
 			return nil, errors.New(u.ErrorParentIdForReply(*c.ParentId))
 		}
 
 		// проверка чтобы ответ на комментарий был под тем же постом
-		if c.PostId != parentComm.PostID {
-			return nil, errors.New(u.ErorrMismatchPostId(c.PostId, parentComm.PostID))
+		if parentShard != shard {
+			return nil, errors.New(u.ErorrMismatchPostId(c.PostId, parentShard.id))
 		}
 	}
 
-	id := uint(len(m.comments)) + 1 // чтобы совпадало с бд
+	id := m.commentSeq.next()
 
 	comment := smodel.Comment{
-		ID: id,
-		PostID: c.PostId,
-		ParentID: c.ParentId,
-		UserID: c.UserId,
-		User: user,
-		Content: c.Content,
+		ID:        id,
+		PostID:    c.PostId,
+		ParentID:  c.ParentId,
+		UserID:    c.UserId,
+		User:      user,
+		Content:   c.Content,
+		CreatedAt: now,
 	}
 
-	m.comments[id] = comment
+	// уведомления автору родительского комментария и упомянутым пользователям
+	var parentAuthor uint
+	if c.ParentId != nil {
+		parentAuthor = shard.comments[*c.ParentId].UserID
+	}
+	notifications := m.newNotifications(comment, parentAuthor, mentioned)
 
-	// добавление в commReply
-	if comment.ParentID != nil { // если комментарий на комментарий
-		//if _, exist := m.commReply[int(*comment.ParentID)]; exist {
-	    m.commReply[int(*comment.ParentID)] = append(m.commReply[int(*comment.ParentID)], int(id))
-		/*} else {
-			m.commReply[int(*comment.ParentID)] = []int{int(id)}
-		}*/
-	} else { // если комментарий под пост
-		m.commReply[-int(comment.PostID)] = append(m.commReply[-int(comment.PostID)], int(id))
+	key := newKey(c.UserId, c.Idempotency, smodel.TargetComment, id, now)
+	if err := m.log(record{Op: opCreateComment, Comment: &comment, Key: key, Notifications: notifications}); err != nil {
+		return nil, err
 	}
+	m.applyComment(shard, comment)
+	m.applyKey(key)
+	m.applyNotifications(notifications)
 
+	comment.Notifications = notifications
 	return &comment, nil
 }
 
-func (m *MemoryStorage) CreateUser(u smodel.CreateUser) (*smodel.User, error) {
+// applyComment добавляет комментарий в шард его поста,
+// вызывается под блокировкой шарда
+func (m *MemoryStorage) applyComment(shard *postShard, comment smodel.Comment) {
+	m.commentSeq.advance(comment.ID)
+	// голоса считаются заново при применении голосов
+	comment.Votes = smodel.Votes{HotRank: ranking.Hot(0, 0, comment.CreatedAt)}
+	shard.comments[comment.ID] = comment
+
+	// добавление в replies
+	var parentId uint // ответ на пост
+	if comment.ParentID != nil {
+		parentId = *comment.ParentID
+	}
+	shard.replies[parentId] = append(shard.replies[parentId], comment.ID)
+
+	shard.post.CommentCount++
+	if comment.ParentID == nil {
+		shard.post.TopLevelCommentCount++
+	}
+	// указатель заменяется, а не меняется: его копии уже отданы из хранилища
+	if last := shard.post.LastCommentAt; last == nil || comment.CreatedAt.After(*last) {
+		createdAt := comment.CreatedAt
+		shard.post.LastCommentAt = &createdAt
+	}
+	if last, ok := shard.lastComment[comment.UserID]; !ok || comment.CreatedAt.After(last) {
+		shard.lastComment[comment.UserID] = comment.CreatedAt
+	}
+
+	m.commentsMu.Lock()
+	m.comments[comment.ID] = shard
+	// комментарии разных постов применяются под разными блокировками,
+	// поэтому id может прийти не по порядку
+	m.userComments[comment.UserID] = insertSorted(m.userComments[comment.UserID], comment.ID)
+	m.commentsMu.Unlock()
+
+	m.search.add(docKey{comment: true, id: comment.ID}, weighted{comment.Content, 1})
+}
+
+// commentShard находит шард поста, к которому относится комментарий
+func (m *MemoryStorage) commentShard(id uint) (*postShard, bool) {
+	m.commentsMu.RLock()
+	defer m.commentsMu.RUnlock()
+
+	shard, ok := m.comments[id]
+	return shard, ok
+}
+
+func (m *MemoryStorage) CreateUser(ctx context.Context, u smodel.CreateUser) (*smodel.User, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	
-	id := uint(len(m.users)) + 1 // чтобы совпадало с бд
-	
+
+	id := m.userSeq.next()
+
 	user := smodel.User{
-		ID: id,
+		ID:       id,
 		Username: u.Username,
 	}
 
-	m.users[id] = user
+	if err := m.log(record{Op: opCreateUser, User: &user}); err != nil {
+		return nil, err
+	}
+	m.applyUser(user)
 
 	return &user, nil
 }
 
-func (m *MemoryStorage) GetPosts(limit, offset int) (*smodel.PostPage, error) {
+// applyUser добавляет пользователя, вызывается под m.mu
+func (m *MemoryStorage) applyUser(user smodel.User) {
+	m.userSeq.advance(user.ID)
+	m.users[user.ID] = user
+	if _, ok := m.usernames[user.Username]; !ok {
+		m.usernames[user.Username] = user.ID
+	}
+}
+
+func (m *MemoryStorage) GetUserByUsername(ctx context.Context, username string) (*smodel.User, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	totalCount := len(m.posts)
+	id, ok := m.usernames[username]
+	if !ok {
+		return nil, errors.New(u.ErrorUsername(username))
+	}
+
+	user := m.users[id]
+	return &user, nil
+}
 
-	posts := make([]*smodel.Post, 0, limit)
-	for postId, post := range m.posts {
-		if int(postId) > offset && int(postId) <= offset + limit {
-			posts = append(posts, &post)
+func (m *MemoryStorage) GetUserPosts(ctx context.Context, limit, offset int, id uint, withDeleted bool) (*smodel.PostPage, error) {
+	m.mu.RLock()
+	if _, ok := m.users[id]; !ok {
+		m.mu.RUnlock()
+		return nil, errors.New(u.ErrorUserId(id))
+	}
+	all := m.userPosts[id]
+	if !withDeleted && len(m.removedPosts) > 0 {
+		all = make([]uint, 0, len(m.userPosts[id]))
+		for _, pid := range m.userPosts[id] {
+			if !m.removedPosts[pid] {
+				all = append(all, pid)
+			}
 		}
 	}
+	totalCount := len(all)
+	ids := pageDesc(all, limit, offset)
+	shards := make([]*postShard, 0, len(ids))
+	for _, id := range ids {
+		shards = append(shards, m.posts[id])
+	}
+	m.mu.RUnlock()
+
+	posts := make([]*smodel.Post, 0, len(shards))
+	for _, shard := range shards {
+		shard.mu.RLock()
+		posts = append(posts, shard.postCopy())
+		shard.mu.RUnlock()
+	}
 
 	return &smodel.PostPage{
-		Posts: posts,
+		Posts:      posts,
 		TotalCount: totalCount,
 	}, nil
 }
 
-func (m *MemoryStorage) GetPost(limit, offset int, id uint) (*smodel.Post, error) {
+func (m *MemoryStorage) GetUserComments(ctx context.Context, limit, offset int, id uint, withDeleted bool) (*smodel.CommPage, error) {
 	m.mu.RLock()
-    defer m.mu.RUnlock()
+	_, ok := m.users[id]
+	m.mu.RUnlock()
 
-	// проверка существования поста
-    post, ok := m.posts[id]
-    if !ok {
-        return nil, errors.New(u.ErrorPostId(id))
-    }
+	if !ok {
+		return nil, errors.New(u.ErrorUserId(id))
+	}
 
-	// получение комментариев к посту
-	//comms := m.getComments(limit, offset, -int(id), 0)
+	m.commentsMu.RLock()
+	all := append([]uint(nil), m.userComments[id]...)
+	allShards := make(map[uint]*postShard, len(all))
+	for _, id := range all {
+		allShards[id] = m.comments[id]
+	}
+	m.commentsMu.RUnlock()
+
+	// видимость проверяется по шардам уже без commentsMu
+	if !withDeleted {
+		visible := all[:0]
+		for _, id := range all {
+			if allShards[id].commentVisible(id) {
+				visible = append(visible, id)
+			}
+		}
+		all = visible
+	}
 
-	post.CommPage = m.getComments(limit, offset, -int(id), 0)
+	totalCount := len(all)
+	ids := pageDesc(all, limit, offset)
+	shards := make([]*postShard, 0, len(ids))
+	for _, id := range ids {
+		shards = append(shards, allShards[id])
+	}
 
-    return &post, nil
+	comms := make([]*smodel.Comment, 0, len(ids))
+	for i, shard := range shards {
+		shard.mu.RLock()
+		comm := shard.comments[ids[i]]
+		shard.mu.RUnlock()
+
+		comm.ReplyPage = &smodel.CommPage{
+			Comms:      []*smodel.Comment{},
+			TotalCount: 0,
+		}
+		comms = append(comms, &comm)
+	}
+
+	return &smodel.CommPage{
+		Comms:      comms,
+		TotalCount: totalCount,
+	}, nil
+}
+
+func (m *MemoryStorage) GetUser(ctx context.Context, id uint) (*smodel.User, error) {
+	m.mu.RLock()
+	user, ok := m.users[id]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, errors.New(u.ErrorUserId(id))
+	}
+
+	return &user, nil
 }
 
-func (m *MemoryStorage) GetComments(limit, offset int, id uint) (*smodel.Comment, error) {
+func (m *MemoryStorage) GetPosts(ctx context.Context, limit, offset int, postSort smodel.PostSort, filter smodel.PostFilter) (*smodel.PostPage, error) {
+	less, ok := postLess[postSort]
+	if postSort != smodel.PostSortDefault && !ok {
+		return nil, errors.New(u.ErrorPostSort(string(postSort)))
+	}
+
 	m.mu.RLock()
-    defer m.mu.RUnlock()
+	ids := m.filterPosts(filter)
+	totalCount := len(ids)
+	// при сортировке по счётчикам нужны все посты, они лежат в шардах
+	if less == nil {
+		ids = page(ids, limit, offset)
+	}
+	shards := make([]*postShard, 0, len(ids))
+	for _, id := range ids {
+		shards = append(shards, m.posts[id])
+	}
+	m.mu.RUnlock()
+
+	posts := make([]*smodel.Post, 0, len(shards))
+	for _, shard := range shards {
+		shard.mu.RLock()
+		posts = append(posts, shard.postCopy())
+		shard.mu.RUnlock()
+	}
+
+	if less != nil {
+		sort.Slice(posts, func(i, j int) bool { return less(posts[i], posts[j]) })
+		start, end := bounds(len(posts), limit, offset)
+		posts = posts[start:end]
+	}
+
+	return &smodel.PostPage{
+		Posts:      posts,
+		TotalCount: totalCount,
+	}, nil
+}
+
+// порядок постов для сортировок по счётчикам, как в PostgreSQL:
+// по убыванию, при равенстве сначала новые, посты без комментариев в конце
+var postLess = map[smodel.PostSort]func(a, b *smodel.Post) bool{
+	smodel.PostSortCommentCount: func(a, b *smodel.Post) bool {
+		if a.CommentCount != b.CommentCount {
+			return a.CommentCount > b.CommentCount
+		}
+		return a.ID > b.ID
+	},
+	smodel.PostSortTopLevelCommentCount: func(a, b *smodel.Post) bool {
+		if a.TopLevelCommentCount != b.TopLevelCommentCount {
+			return a.TopLevelCommentCount > b.TopLevelCommentCount
+		}
+		return a.ID > b.ID
+	},
+	smodel.PostSortLastCommentAt: func(a, b *smodel.Post) bool {
+		if (a.LastCommentAt == nil) != (b.LastCommentAt == nil) {
+			return b.LastCommentAt == nil
+		}
+		if a.LastCommentAt != nil && !a.LastCommentAt.Equal(*b.LastCommentAt) {
+			return a.LastCommentAt.After(*b.LastCommentAt)
+		}
+		return a.ID > b.ID
+	},
+	smodel.PostSortTop: func(a, b *smodel.Post) bool {
+		if a.TopRank != b.TopRank {
+			return a.TopRank > b.TopRank
+		}
+		return a.ID > b.ID
+	},
+	smodel.PostSortHot: func(a, b *smodel.Post) bool {
+		if a.HotRank != b.HotRank {
+			return a.HotRank > b.HotRank
+		}
+		return a.ID > b.ID
+	},
+}
+
+// порядок комментариев на каждом уровне дерева, как в PostgreSQL
+var commentLess = map[smodel.CommentSort]func(a, b *smodel.Comment) bool{
+	smodel.CommentSortTop: func(a, b *smodel.Comment) bool {
+		if a.TopRank != b.TopRank {
+			return a.TopRank > b.TopRank
+		}
+		return a.ID > b.ID
+	},
+	smodel.CommentSortHot: func(a, b *smodel.Comment) bool {
+		if a.HotRank != b.HotRank {
+			return a.HotRank > b.HotRank
+		}
+		return a.ID > b.ID
+	},
+}
+
+func (m *MemoryStorage) GetPost(ctx context.Context, limit, offset int, sort smodel.CommentSort, id uint) (*smodel.Post, error) {
+	less, ok := commentLess[sort]
+	if sort != smodel.CommentSortDefault && !ok {
+		return nil, errors.New(u.ErrorCommentSort(string(sort)))
+	}
+
+	m.mu.RLock()
+	shard, ok := m.posts[id]
+	m.mu.RUnlock()
+
+	// проверка существования поста
+	if !ok {
+		return nil, errors.New(u.ErrorPostId(id))
+	}
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	post := shard.postCopy()
+
+	// получение комментариев к посту
+	commPage, err := shard.getComments(ctx, limit, offset, less, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	post.CommPage = commPage
+
+	return post, nil
+}
+
+func (m *MemoryStorage) GetComments(ctx context.Context, limit, offset int, sort smodel.CommentSort, id uint) (*smodel.Comment, error) {
+	less, ok := commentLess[sort]
+	if sort != smodel.CommentSortDefault && !ok {
+		return nil, errors.New(u.ErrorCommentSort(string(sort)))
+	}
 
 	// проверка существования комментария
-	comm, ok := m.comments[id]
+	shard, ok := m.commentShard(id)
 	if !ok {
 		return nil, errors.New(u.ErrorCommId(id))
 	}
 
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	comm := shard.comments[id]
+
 	// получение комментариев
 	// начинаем с глубины 1, так как уже есть сам комментарий
-	comm.ReplyPage = m.getComments(limit, offset, int(id), 1)
-	//comms := []*smodel.Comment{&comm}
+	replyPage, err := shard.getComments(ctx, limit, offset, less, id, 1)
+	if err != nil {
+		return nil, err
+	}
+	comm.ReplyPage = replyPage
 
 	return &comm, nil
 }
 
-// рекурсивно получает комментарии
-func (m *MemoryStorage) getComments(limit, offset, id, depth int) *smodel.CommPage {
-	m.mu.RLock()
-    defer m.mu.RUnlock()
+// копия поста для возврата из хранилища, вызывается под блокировкой шарда
+func (s *postShard) postCopy() *smodel.Post {
+	post := s.post
+	post.CommPage = &smodel.CommPage{
+		Comms:      []*smodel.Comment{},
+		TotalCount: 0,
+	}
+	return &post
+}
+
+// commentVisible сообщает, что комментарий id не скрыт, не удалён
+// и пост его не удалён. Берёт блокировку шарда сам
+func (s *postShard) commentVisible(id uint) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comm := s.comments[id]
+	return comm.Status == smodel.ContentVisible && comm.RemovedAt == nil && s.post.RemovedAt == nil
+}
 
+// рекурсивно получает комментарии, вызывается под блокировкой шарда
+// обход прерывается, если контекст запроса отменён.
+// less - порядок на каждом уровне, nil - в порядке создания
+func (s *postShard) getComments(ctx context.Context, limit, offset int, less func(a, b *smodel.Comment) bool, id uint, depth int) (*smodel.CommPage, error) {
 	commPage := smodel.CommPage{
-		Comms: make([]*smodel.Comment, 0),
+		Comms:      make([]*smodel.Comment, 0),
 		TotalCount: 0,
 	}
 
 	comms := make([]*smodel.Comment, 0)
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if depth > 4 {
-		return &commPage
+		return &commPage, nil
 	}
 
-	level, ok := m.commReply[id]
+	level, ok := s.replies[id]
 	if !ok {
-		return &commPage
+		return &commPage, nil
 	}
 
 	totalCount := len(level)
-	level = level[offset:offset+limit]
+	if less != nil {
+		level = s.sortComments(level, less)
+	}
+	level = page(level, limit, offset)
 
 	for _, lv := range level {
-		comm := m.comments[uint(lv)]
-		comm.ReplyPage = m.getComments(limit, offset, lv, depth + 1)
+		comm := s.comments[lv]
+		replyPage, err := s.getComments(ctx, limit, offset, less, lv, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		comm.ReplyPage = replyPage
 		comms = append(comms, &comm)
 	}
 
 	commPage.Comms = comms
 	commPage.TotalCount = totalCount
 
-	return &commPage
+	return &commPage, nil
+}
+
+// sortComments возвращает отсортированную копию id комментариев,
+// вызывается под блокировкой шарда
+func (s *postShard) sortComments(ids []uint, less func(a, b *smodel.Comment) bool) []uint {
+	sorted := make([]uint, len(ids))
+	copy(sorted, ids)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := s.comments[sorted[i]], s.comments[sorted[j]]
+		return less(&a, &b)
+	})
+
+	return sorted
+}
+
+// страница из limit элементов начиная с offset, границы не выходят за срез
+func page(ids []uint, limit, offset int) []uint {
+	start, end := bounds(len(ids), limit, offset)
+	return ids[start:end]
+}
+
+// страница среза, перевёрнутого от конца к началу, то есть сначала новые
+func pageDesc(ids []uint, limit, offset int) []uint {
+	start, end := bounds(len(ids), limit, offset)
+
+	res := make([]uint, 0, end-start)
+	for i := start; i < end; i++ {
+		res = append(res, ids[len(ids)-1-i])
+	}
+
+	return res
+}
+
+func bounds(n, limit, offset int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > n {
+		offset = n
+	}
+
+	end := offset + limit
+	if limit < 0 || end > n {
+		end = n
+	}
+
+	return offset, end
+}
+
+// insertSorted вставляет id в отсортированный срез
+func insertSorted(ids []uint, id uint) []uint {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+
+	return ids
+}
+
+// removeId удаляет id из отсортированного списка
+func removeId(ids []uint, id uint) []uint {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+	if i == len(ids) || ids[i] != id {
+		return ids
+	}
+	return append(ids[:i], ids[i+1:]...)
 }
@@ -0,0 +1,309 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// Стресс-тесты блокировок, запускать с -race:
+// go test -race ./pkg/storage/in_memory/
+
+func TestConcurrentReadWrite(t *testing.T) {
+	ctx := context.Background()
+	m, err := NewInMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := m.CreateUser(ctx, smodel.CreateUser{Username: "qwerty"})
+	if err != nil {
+		t.Fatalf("Error create user: %s", err.Error())
+	}
+
+	const postsCount, writers, readers, iterations = 4, 8, 8, 200
+
+	postIds := make([]uint, 0, postsCount)
+	for i := 0; i < postsCount; i++ {
+		post, err := m.CreatePost(ctx, smodel.CreatePost{Title: "t", Content: "c", UserId: user.ID, CommentsEnabled: true})
+		if err != nil {
+			t.Fatalf("Error create post: %s", err.Error())
+		}
+		postIds = append(postIds, post.ID)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers+readers)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			postId := postIds[w%postsCount]
+			var parentId *uint
+			for i := 0; i < iterations; i++ {
+				comm, err := m.CreateComment(ctx, smodel.CreateComment{PostId: postId, UserId: user.ID, Content: "c", ParentId: parentId})
+				if err != nil {
+					errs <- err
+					return
+				}
+				// чередование комментариев к посту и ответов
+				if i%2 == 0 {
+					parentId = &comm.ID
+				} else {
+					parentId = nil
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				post, err := m.GetPost(ctx, 20, 0, smodel.CommentSortDefault, postIds[r%postsCount])
+				if err != nil {
+					errs <- err
+					return
+				}
+				for _, comm := range post.CommPage.Comms {
+					if _, err := m.GetComments(ctx, 20, 0, smodel.CommentSortDefault, comm.ID); err != nil {
+						errs <- err
+						return
+					}
+				}
+				if _, err := m.GetPosts(ctx, 20, 0, smodel.PostSortCommentCount, smodel.PostFilter{}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(r)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	total := 0
+	for _, postId := range postIds {
+		post, err := m.GetPost(ctx, 1000, 0, smodel.CommentSortDefault, postId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += post.CommPage.TotalCount
+		for _, comm := range post.CommPage.Comms {
+			total += comm.ReplyPage.TotalCount
+		}
+	}
+	if total != writers*iterations {
+		t.Error("expected", writers*iterations, "comments, got", total)
+	}
+}
+
+// чтение ветки одного поста не блокирует запись в другой пост
+func TestReadDoesNotBlockOtherPost(t *testing.T) {
+	ctx := context.Background()
+	m, err := NewInMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := m.CreateUser(ctx, smodel.CreateUser{Username: "qwerty"})
+	first, _ := m.CreatePost(ctx, smodel.CreatePost{Title: "t", Content: "c", UserId: user.ID, CommentsEnabled: true})
+	second, _ := m.CreatePost(ctx, smodel.CreatePost{Title: "t", Content: "c", UserId: user.ID, CommentsEnabled: true})
+
+	// долгое чтение первого поста
+	shard := m.posts[first.ID]
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.CreateComment(ctx, smodel.CreateComment{PostId: second.ID, UserId: user.ID, Content: "c"})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Error create comm: %s", err.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write to another post blocked by read")
+	}
+}
+
+// запрос с ключом идемпотентности ждёт только запросы с тем же ключом
+func TestIdempotencyKeyDoesNotBlockOtherKeys(t *testing.T) {
+	ctx := context.Background()
+	m, err := NewInMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := m.CreateUser(ctx, smodel.CreateUser{Username: "qwerty"})
+	post, _ := m.CreatePost(ctx, smodel.CreatePost{Title: "t", Content: "c", UserId: user.ID, CommentsEnabled: true})
+	key := func(name string) *smodel.Idempotency {
+		return &smodel.Idempotency{Key: name, Hash: "h", TTL: time.Hour}
+	}
+
+	// долгий запрос с ключом "busy"
+	unlock, err := m.lockKey(ctx, user.ID, key("busy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := m.CreatePost(ctx, smodel.CreatePost{Title: "t", Content: "c", UserId: user.ID, Idempotency: key("post")})
+		done <- err
+	}()
+	go func() {
+		_, err := m.CreateComment(ctx, smodel.CreateComment{PostId: post.ID, UserId: user.ID, Content: "c", Idempotency: key("comment")})
+		done <- err
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Error create: %s", err.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("request with another key blocked")
+		}
+	}
+
+	// тот же ключ ждёт, пока ключ не освободится
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := m.CreatePost(waitCtx, smodel.CreatePost{Title: "t", Content: "c", UserId: user.ID, Idempotency: key("busy")}); err != context.DeadlineExceeded {
+		t.Fatal("expected request with busy key to wait, got", err)
+	}
+
+	unlock()
+	if _, err := m.CreatePost(ctx, smodel.CreatePost{Title: "t", Content: "c", UserId: user.ID, Idempotency: key("busy")}); err != nil {
+		t.Fatalf("Error create post: %s", err.Error())
+	}
+}
+
+// читатель ветки не захватывает блокировку повторно, поэтому
+// ожидающий писатель того же поста не приводит к взаимной блокировке
+func TestReaderWithQueuedWriter(t *testing.T) {
+	ctx := context.Background()
+	m, err := NewInMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := m.CreateUser(ctx, smodel.CreateUser{Username: "qwerty"})
+	post, _ := m.CreatePost(ctx, smodel.CreatePost{Title: "t", Content: "c", UserId: user.ID, CommentsEnabled: true})
+
+	// глубокая ветка, чтобы обход шёл через все уровни
+	var parentId *uint
+	for i := 0; i < 10; i++ {
+		comm, err := m.CreateComment(ctx, smodel.CreateComment{PostId: post.ID, UserId: user.ID, Content: "c", ParentId: parentId})
+		if err != nil {
+			t.Fatalf("Error create comm: %s", err.Error())
+		}
+		parentId = &comm.ID
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				m.GetPost(ctx, 20, 0, smodel.CommentSortDefault, post.ID)
+			}()
+			go func() {
+				defer wg.Done()
+				m.CreateComment(ctx, smodel.CreateComment{PostId: post.ID, UserId: user.ID, Content: "c"})
+			}()
+		}
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock between readers and writers of one post")
+	}
+}
+
+// снимки во время записи согласованы с журналом
+func TestConcurrentSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	m, err := NewInMemoryStore(WithPersistence(Persistence{Dir: dir, Fsync: FsyncNever}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := m.CreateUser(ctx, smodel.CreateUser{Username: "qwerty"})
+
+	const writers, iterations = 4, 100
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			post, err := m.CreatePost(ctx, smodel.CreatePost{Title: "t", Content: "c", UserId: user.ID, CommentsEnabled: true})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for i := 0; i < iterations; i++ {
+				if _, err := m.CreateComment(ctx, smodel.CreateComment{PostId: post.ID, UserId: user.ID, Content: "c"}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	stop := make(chan struct{})
+	snapshots := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				snapshots <- nil
+				return
+			default:
+				if err := m.Snapshot(); err != nil {
+					snapshots <- err
+					return
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(stop)
+	if err := <-snapshots; err != nil {
+		t.Fatalf("Error snapshot: %s", err.Error())
+	}
+	m.persist.wal.close()
+
+	m, err = NewInMemoryStore(WithPersistence(Persistence{Dir: dir, Fsync: FsyncNever}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.posts) != writers || len(m.comments) != writers*iterations {
+		t.Error("expected", writers, "posts and", writers*iterations, "comments, got", len(m.posts), len(m.comments))
+	}
+}
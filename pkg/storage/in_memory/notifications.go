@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// notificationsRead - уведомления, отмеченные прочитанными, в журнале
+type notificationsRead struct {
+	UserId uint   `json:"userId"`
+	Ids    []uint `json:"ids"`
+}
+
+// mentionedUsers возвращает id пользователей, упомянутых в тексте.
+// Из пользователей с одним username упоминается первый. Вызывается под m.mu
+func (m *MemoryStorage) mentionedUsers(text string) []uint {
+	var ids []uint
+	for _, name := range u.Mentions(text) {
+		if id, ok := m.usernames[name]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// newNotifications создаёт уведомления о комментарии: автору родительского
+// комментария parentAuthor (0 - нет родителя) и упомянутым пользователям,
+// самому автору уведомления не приходят
+func (m *MemoryStorage) newNotifications(comment smodel.Comment, parentAuthor uint, mentioned []uint) []smodel.Notification {
+	notified := map[uint]bool{comment.UserID: true}
+	var notifications []smodel.Notification
+	add := func(userId uint, kind smodel.NotificationKind) {
+		if userId == 0 || notified[userId] {
+			return
+		}
+		notified[userId] = true
+		notifications = append(notifications, smodel.Notification{
+			ID:        m.notificationSeq.next(),
+			UserID:    userId,
+			Kind:      kind,
+			ActorID:   comment.UserID,
+			PostID:    comment.PostID,
+			CommentID: comment.ID,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+
+	add(parentAuthor, smodel.NotificationReply)
+	for _, id := range mentioned {
+		add(id, smodel.NotificationMention)
+	}
+
+	return notifications
+}
+
+// applyNotifications добавляет уведомления, берёт notificationsMu
+func (m *MemoryStorage) applyNotifications(notifications []smodel.Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+
+	m.notificationsMu.Lock()
+	defer m.notificationsMu.Unlock()
+
+	for _, n := range notifications {
+		m.notificationSeq.advance(n.ID)
+		m.notifications[n.ID] = n
+		// уведомления разных постов создаются под разными блокировками,
+		// поэтому id может прийти не по порядку
+		m.userNotifications[n.UserID] = insertSorted(m.userNotifications[n.UserID], n.ID)
+	}
+}
+
+func (m *MemoryStorage) GetNotifications(ctx context.Context, userId uint, limit, offset int, unreadOnly bool) (*smodel.NotificationPage, error) {
+	m.notificationsMu.RLock()
+	defer m.notificationsMu.RUnlock()
+
+	ids := make([]uint, 0)
+	for _, id := range m.userNotifications[userId] {
+		if !m.notifications[id].Read {
+			ids = append(ids, id)
+		}
+	}
+	unread := len(ids)
+	if !unreadOnly {
+		ids = m.userNotifications[userId]
+	}
+
+	notifications := make([]*smodel.Notification, 0)
+	for _, id := range pageDesc(ids, limit, offset) {
+		n := m.notifications[id]
+		notifications = append(notifications, &n)
+	}
+
+	return &smodel.NotificationPage{
+		Notifications: notifications,
+		TotalCount:    len(ids),
+		UnreadCount:   unread,
+	}, nil
+}
+
+func (m *MemoryStorage) MarkNotificationsRead(ctx context.Context, userId uint, ids []uint) (int, error) {
+	m.notificationsMu.Lock()
+	defer m.notificationsMu.Unlock()
+
+	// в журнал попадают только уведомления, которые действительно отмечены
+	read := notificationsRead{UserId: userId}
+	if ids == nil {
+		ids = m.userNotifications[userId]
+	}
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if n, ok := m.notifications[id]; ok && n.UserID == userId && !n.Read && !seen[id] {
+			seen[id] = true
+			read.Ids = append(read.Ids, id)
+		}
+	}
+	if len(read.Ids) == 0 {
+		return 0, nil
+	}
+
+	if err := m.log(record{Op: opMarkRead, Read: &read}); err != nil {
+		return 0, err
+	}
+	m.applyRead(read)
+
+	return len(read.Ids), nil
+}
+
+// applyRead отмечает уведомления прочитанными, вызывается под notificationsMu
+func (m *MemoryStorage) applyRead(read notificationsRead) {
+	for _, id := range read.Ids {
+		if n, ok := m.notifications[id]; ok {
+			n.Read = true
+			m.notifications[id] = n
+		}
+	}
+}
@@ -0,0 +1,589 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// Сохранение in-memory хранилища на диск.
+// Каждое изменение сначала дописывается в журнал (см. wal.go), затем
+// применяется в памяти. Периодически всё состояние сохраняется снимком,
+// после чего журнал очищается. При запуске загружается снимок
+// и к нему применяются записи журнала, сделанные после снимка
+
+const (
+	walFile      = "wal.log"
+	snapshotFile = "snapshot.json"
+)
+
+// Persistence настраивает сохранение хранилища на диск
+type Persistence struct {
+	// каталог для журнала и снимков
+	Dir string
+	// когда журнал сбрасывается на диск через fsync
+	Fsync FsyncPolicy
+	// интервал fsync для FsyncInterval
+	FsyncInterval time.Duration
+	// интервал снимков, 0 - снимок только при закрытии хранилища
+	SnapshotInterval time.Duration
+}
+
+type Option func(*MemoryStorage)
+
+// WithPersistence включает сохранение хранилища на диск
+func WithPersistence(p Persistence) Option {
+	return func(m *MemoryStorage) {
+		m.persist = &persistence{cfg: p}
+	}
+}
+
+type persistence struct {
+	cfg Persistence
+	wal *wal
+
+	// записи в журнал идут по очереди, в порядке номеров
+	logMu sync.Mutex
+	// номер последней записи журнала
+	seq uint64
+
+	// снимки не делаются параллельно
+	snapshotMu sync.Mutex
+	stop       chan struct{}
+	workers    sync.WaitGroup
+}
+
+// операции в записях журнала
+const (
+	opCreateUser    = "createUser"
+	opCreatePost    = "createPost"
+	opCreateComment = "createComment"
+	opCreateBoard   = "createBoard"
+	opReact         = "react"
+	opUnreact       = "unreact"
+	opVote          = "vote"
+	opCreateReport  = "createReport"
+	opResolveReport = "resolveReport"
+	opSetSlowMode   = "setSlowMode"
+	opMarkRead      = "markNotificationsRead"
+	opEdit          = "edit"
+	opRemove        = "remove"
+	opPurge         = "purge"
+	opAnonymize     = "anonymize"
+
+	opCreateWebhook    = "createWebhook"
+	opDeleteWebhook    = "deleteWebhook"
+	opCreateDeliveries = "createDeliveries"
+	opDeliveryAttempt  = "deliveryAttempt"
+)
+
+// запись журнала: операция и созданная сущность со всеми
+// сгенерированными полями, чтобы повтор давал то же состояние
+type record struct {
+	Seq     uint64          `json:"seq"`
+	Op      string          `json:"op"`
+	User    *smodel.User    `json:"user,omitempty"`
+	Post    *smodel.Post    `json:"post,omitempty"`
+	Comment *smodel.Comment `json:"comment,omitempty"`
+	Board   *smodel.Board   `json:"board,omitempty"`
+	// реакция, которую ставят или снимают
+	Reaction *smodel.Reaction `json:"reaction,omitempty"`
+	// новый голос, Value 0 - голос снят
+	Vote   *smodel.Vote   `json:"vote,omitempty"`
+	Report *smodel.Report `json:"report,omitempty"`
+	// решение модератора по жалобе
+	Resolution *resolution `json:"resolution,omitempty"`
+	// новый интервал медленного режима поста
+	SlowMode *smodel.SetSlowMode `json:"slowMode,omitempty"`
+	// ключ идемпотентности, по которому создан пост или комментарий
+	Key *smodel.IdempotencyKey `json:"key,omitempty"`
+	// уведомления, созданные вместе с комментарием
+	Notifications []smodel.Notification `json:"notifications,omitempty"`
+	// уведомления, отмеченные прочитанными
+	Read *notificationsRead `json:"read,omitempty"`
+	// созданный вебхук или id удалённого
+	Webhook   *smodel.Webhook `json:"webhook,omitempty"`
+	WebhookId uint            `json:"webhookId,omitempty"`
+	// доставки события вебхукам
+	Deliveries []smodel.WebhookDelivery `json:"deliveries,omitempty"`
+	// доставка после попытки
+	Delivery *smodel.WebhookDelivery `json:"delivery,omitempty"`
+	// новые версии записи, последняя - её текущий текст
+	Revisions []smodel.Revision `json:"revisions,omitempty"`
+	// удаление или восстановление записи
+	Removal *removal `json:"removal,omitempty"`
+	// очищенные удалённые записи
+	Purge *purge `json:"purge,omitempty"`
+	// удалённый аккаунт
+	Anonymization *anonymization `json:"anonymization,omitempty"`
+}
+
+// снимок всего состояния хранилища после записи журнала Seq
+type snapshot struct {
+	Seq uint64 `json:"seq"`
+	// значения последовательностей id, они могут быть больше
+	// наибольшего id, если часть id пропущена
+	Sequences struct {
+		User    uint64 `json:"user"`
+		Post    uint64 `json:"post"`
+		Comment uint64 `json:"comment"`
+		Board   uint64 `json:"board"`
+		Report  uint64 `json:"report"`
+
+		Notification uint64 `json:"notification"`
+		Webhook      uint64 `json:"webhook"`
+		Delivery     uint64 `json:"delivery"`
+		Revision     uint64 `json:"revision"`
+	} `json:"sequences"`
+	Users     []smodel.User     `json:"users"`
+	Boards    []smodel.Board    `json:"boards"`
+	Posts     []smodel.Post     `json:"posts"`
+	Comments  []smodel.Comment  `json:"comments"`
+	Reactions []smodel.Reaction `json:"reactions"`
+	Votes     []smodel.Vote     `json:"votes"`
+	// жалобы вместе с решениями, статус записей лежит в самих записях
+	Reports []smodel.Report `json:"reports"`
+	// действующие ключи идемпотентности
+	Keys []smodel.IdempotencyKey `json:"keys"`
+	// уведомления вместе с отметками о прочтении
+	Notifications []smodel.Notification `json:"notifications"`
+	// вебхуки и доставки событий
+	Webhooks   []smodel.Webhook         `json:"webhooks"`
+	Deliveries []smodel.WebhookDelivery `json:"deliveries"`
+	// версии постов и комментариев, текст записей лежит в самих записях
+	Revisions []smodel.Revision `json:"revisions"`
+}
+
+// restore загружает снимок и журнал и открывает журнал для записи
+func (m *MemoryStorage) restore() error {
+	p := m.persist
+
+	if p.cfg.Fsync == "" {
+		p.cfg.Fsync = FsyncInterval
+	}
+	if p.cfg.FsyncInterval <= 0 {
+		p.cfg.FsyncInterval = time.Second
+	}
+
+	if err := os.MkdirAll(p.cfg.Dir, 0o755); err != nil {
+		return err
+	}
+
+	if err := m.loadSnapshot(); err != nil {
+		return fmt.Errorf("load snapshot: %w", err)
+	}
+
+	walPath := filepath.Join(p.cfg.Dir, walFile)
+	offset, err := readWAL(walPath, func(data []byte) error {
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+
+		// запись уже есть в снимке
+		if rec.Seq <= p.seq {
+			return nil
+		}
+
+		if err := m.apply(rec); err != nil {
+			return err
+		}
+		p.seq = rec.Seq
+
+		return nil
+	})
+	if errors.Is(err, errCorruptFrame) {
+		// оборвана последняя запись, всё до неё применено, а она отбрасывается
+		logrus.Warnf("in-memory storage: %s, wal truncated to %d bytes", err.Error(), offset)
+	} else if err != nil {
+		return fmt.Errorf("replay wal: %w", err)
+	}
+
+	p.wal, err = openWAL(walPath, offset, p.cfg.Fsync)
+	if err != nil {
+		return err
+	}
+
+	p.stop = make(chan struct{})
+	if p.cfg.Fsync == FsyncInterval {
+		m.every(p.cfg.FsyncInterval, p.wal.sync)
+	}
+	if p.cfg.SnapshotInterval > 0 {
+		m.every(p.cfg.SnapshotInterval, m.Snapshot)
+	}
+
+	return nil
+}
+
+// every запускает fn с интервалом до закрытия хранилища
+func (m *MemoryStorage) every(interval time.Duration, fn func() error) {
+	p := m.persist
+	p.workers.Add(1)
+
+	go func() {
+		defer p.workers.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				if err := fn(); err != nil {
+					logrus.Errorf("in-memory storage: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// log дописывает изменение в журнал до его применения в памяти.
+// Вызывается под блокировкой, которая защищает изменяемые данные,
+// поэтому изменения одних данных попадают в журнал в порядке применения
+func (m *MemoryStorage) log(rec record) error {
+	p := m.persist
+	if p == nil {
+		return nil
+	}
+
+	p.logMu.Lock()
+	defer p.logMu.Unlock()
+
+	rec.Seq = p.seq + 1
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if err := p.wal.append(data); err != nil {
+		return err
+	}
+	p.seq = rec.Seq
+
+	return nil
+}
+
+// apply применяет запись журнала к состоянию в памяти
+func (m *MemoryStorage) apply(rec record) error {
+	switch {
+	case rec.Op == opCreateUser && rec.User != nil:
+		m.applyUser(*rec.User)
+	case rec.Op == opCreatePost && rec.Post != nil:
+		m.applyPost(*rec.Post)
+		m.applyKey(rec.Key)
+	case rec.Op == opCreateComment && rec.Comment != nil:
+		if err := m.restoreComment(*rec.Comment); err != nil {
+			return err
+		}
+		m.applyKey(rec.Key)
+		m.applyNotifications(rec.Notifications)
+	case rec.Op == opCreateBoard && rec.Board != nil:
+		m.applyBoard(*rec.Board)
+	case rec.Op == opReact && rec.Reaction != nil:
+		return m.restoreReaction(*rec.Reaction, true)
+	case rec.Op == opUnreact && rec.Reaction != nil:
+		return m.restoreReaction(*rec.Reaction, false)
+	case rec.Op == opVote && rec.Vote != nil:
+		return m.restoreVote(*rec.Vote)
+	case rec.Op == opCreateReport && rec.Report != nil:
+		m.applyReport(*rec.Report)
+	case rec.Op == opResolveReport && rec.Resolution != nil:
+		return m.restoreResolution(*rec.Resolution)
+	case rec.Op == opSetSlowMode && rec.SlowMode != nil:
+		return m.restoreSlowMode(*rec.SlowMode)
+	case rec.Op == opMarkRead && rec.Read != nil:
+		m.applyRead(*rec.Read)
+	case rec.Op == opEdit && len(rec.Revisions) > 0:
+		return m.restoreRevisions(rec.Revisions, true)
+	case rec.Op == opRemove && rec.Removal != nil:
+		return m.restoreRemoval(*rec.Removal)
+	case rec.Op == opPurge && rec.Purge != nil:
+		return m.restorePurge(*rec.Purge)
+	case rec.Op == opAnonymize && rec.Anonymization != nil:
+		m.applyAnonymization(*rec.Anonymization)
+	case rec.Op == opCreateWebhook && rec.Webhook != nil:
+		m.applyWebhook(*rec.Webhook)
+	case rec.Op == opDeleteWebhook && rec.WebhookId != 0:
+		m.applyDeleteWebhook(rec.WebhookId)
+	case rec.Op == opCreateDeliveries && len(rec.Deliveries) > 0:
+		for _, delivery := range rec.Deliveries {
+			m.applyDelivery(delivery)
+		}
+	case rec.Op == opDeliveryAttempt && rec.Delivery != nil:
+		m.applyDelivery(*rec.Delivery)
+	default:
+		return fmt.Errorf("unknown wal record %d: %q", rec.Seq, rec.Op)
+	}
+
+	return nil
+}
+
+// restoreComment добавляет комментарий при восстановлении
+func (m *MemoryStorage) restoreComment(comment smodel.Comment) error {
+	shard, ok := m.posts[comment.PostID]
+	if !ok {
+		return errors.New(u.ErrorPostId(comment.PostID))
+	}
+
+	comment.User = m.users[comment.UserID]
+	m.applyComment(shard, comment)
+
+	return nil
+}
+
+func (m *MemoryStorage) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(m.persist.cfg.Dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+
+	// комментарии отсортированы по id, поэтому родитель
+	// применяется раньше ответов и порядок ответов сохраняется
+	for _, user := range snap.Users {
+		m.applyUser(user)
+	}
+	for _, board := range snap.Boards {
+		m.applyBoard(board)
+	}
+	for _, post := range snap.Posts {
+		m.applyPost(post)
+	}
+	for _, comment := range snap.Comments {
+		if err := m.restoreComment(comment); err != nil {
+			return err
+		}
+	}
+	for _, reaction := range snap.Reactions {
+		if err := m.restoreReaction(reaction, true); err != nil {
+			return err
+		}
+	}
+	for _, vote := range snap.Votes {
+		if err := m.restoreVote(vote); err != nil {
+			return err
+		}
+	}
+	// версии отсортированы по id, поэтому номера версий записи идут по порядку
+	for _, revision := range snap.Revisions {
+		if err := m.restoreRevisions([]smodel.Revision{revision}, false); err != nil {
+			return err
+		}
+	}
+	m.revisionSeq.advance(uint(snap.Sequences.Revision))
+	m.userSeq.advance(uint(snap.Sequences.User))
+	m.postSeq.advance(uint(snap.Sequences.Post))
+	m.commentSeq.advance(uint(snap.Sequences.Comment))
+	for _, report := range snap.Reports {
+		m.applyReport(report)
+	}
+	for i := range snap.Keys {
+		m.applyKey(&snap.Keys[i])
+	}
+	m.applyNotifications(snap.Notifications)
+	m.notificationSeq.advance(uint(snap.Sequences.Notification))
+	for _, webhook := range snap.Webhooks {
+		m.applyWebhook(webhook)
+	}
+	for _, delivery := range snap.Deliveries {
+		m.applyDelivery(delivery)
+	}
+	m.webhookSeq.advance(uint(snap.Sequences.Webhook))
+	m.deliverySeq.advance(uint(snap.Sequences.Delivery))
+	m.boardSeq.advance(uint(snap.Sequences.Board))
+	m.reportSeq.advance(uint(snap.Sequences.Report))
+	m.persist.seq = snap.Seq
+
+	return nil
+}
+
+// Snapshot сохраняет всё состояние хранилища снимком и очищает журнал
+func (m *MemoryStorage) Snapshot() error {
+	p := m.persist
+	if p == nil {
+		return nil
+	}
+
+	p.snapshotMu.Lock()
+	defer p.snapshotMu.Unlock()
+
+	// чтение разрешено, а запись ждёт, пока снимок не будет сохранён
+	// и журнал не очищен: пользователи, доски и посты создаются под m.mu,
+	// комментарии - под блокировкой шарда. Когда взяты все блокировки,
+	// ни одно изменение не выполняется и номер записи журнала согласован с данными.
+	// Жалобы меняются под reportsMu, ключи идемпотентности - под keysMu
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, id := range m.postIds {
+		shard := m.posts[id]
+		shard.mu.RLock()
+		defer shard.mu.RUnlock()
+	}
+
+	m.reportsMu.RLock()
+	defer m.reportsMu.RUnlock()
+
+	m.notificationsMu.RLock()
+	defer m.notificationsMu.RUnlock()
+
+	m.webhooksMu.RLock()
+	defer m.webhooksMu.RUnlock()
+
+	m.keysMu.Lock()
+	defer m.keysMu.Unlock()
+
+	p.logMu.Lock()
+	seq := p.seq
+	p.logMu.Unlock()
+
+	snap := snapshot{
+		Seq:    seq,
+		Users:  make([]smodel.User, 0, len(m.users)),
+		Boards: make([]smodel.Board, 0, len(m.boards)),
+		Posts:  make([]smodel.Post, 0, len(m.posts)),
+	}
+	snap.Sequences.User = m.userSeq.value()
+	snap.Sequences.Post = m.postSeq.value()
+	snap.Sequences.Comment = m.commentSeq.value()
+	snap.Sequences.Board = m.boardSeq.value()
+	snap.Sequences.Report = m.reportSeq.value()
+	snap.Sequences.Notification = m.notificationSeq.value()
+	snap.Sequences.Webhook = m.webhookSeq.value()
+	snap.Sequences.Delivery = m.deliverySeq.value()
+	snap.Sequences.Revision = m.revisionSeq.value()
+	for _, user := range m.users {
+		snap.Users = append(snap.Users, user)
+	}
+	for _, id := range m.boardIds {
+		snap.Boards = append(snap.Boards, m.boards[id])
+	}
+	for _, id := range m.postIds {
+		shard := m.posts[id]
+		snap.Posts = append(snap.Posts, shard.post)
+		for _, comment := range shard.comments {
+			snap.Comments = append(snap.Comments, comment)
+		}
+		for _, users := range shard.votes {
+			for _, vote := range users {
+				snap.Votes = append(snap.Votes, vote)
+			}
+		}
+		for _, revisions := range shard.revisions {
+			snap.Revisions = append(snap.Revisions, revisions...)
+		}
+		for _, set := range shard.reactions {
+			for _, user := range set.users {
+				for _, reaction := range user {
+					snap.Reactions = append(snap.Reactions, reaction)
+				}
+			}
+		}
+	}
+	snap.Reports = make([]smodel.Report, 0, len(m.reportIds))
+	for _, id := range m.reportIds {
+		snap.Reports = append(snap.Reports, m.reports[id])
+	}
+	snap.Notifications = make([]smodel.Notification, 0, len(m.notifications))
+	for _, n := range m.notifications {
+		snap.Notifications = append(snap.Notifications, n)
+	}
+	sort.Slice(snap.Notifications, func(i, j int) bool { return snap.Notifications[i].ID < snap.Notifications[j].ID })
+	snap.Webhooks = make([]smodel.Webhook, 0, len(m.webhookIds))
+	for _, id := range m.webhookIds {
+		snap.Webhooks = append(snap.Webhooks, m.webhooks[id])
+	}
+	snap.Deliveries = make([]smodel.WebhookDelivery, 0, len(m.deliveryIds))
+	for _, id := range m.deliveryIds {
+		snap.Deliveries = append(snap.Deliveries, m.deliveries[id])
+	}
+	// истёкшие ключи в снимок не попадают
+	now := time.Now()
+	snap.Keys = make([]smodel.IdempotencyKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		if now.Before(key.ExpiresAt) {
+			snap.Keys = append(snap.Keys, key)
+		}
+	}
+	sort.Slice(snap.Users, func(i, j int) bool { return snap.Users[i].ID < snap.Users[j].ID })
+	sort.Slice(snap.Comments, func(i, j int) bool { return snap.Comments[i].ID < snap.Comments[j].ID })
+	sort.Slice(snap.Revisions, func(i, j int) bool { return snap.Revisions[i].ID < snap.Revisions[j].ID })
+
+	if err := writeFileAtomic(filepath.Join(p.cfg.Dir, snapshotFile), snap); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	// если упасть до очистки, то при запуске записи до Seq будут пропущены
+	return p.wal.reset()
+}
+
+// writeFileAtomic записывает v во временный файл и переименовывает его,
+// чтобы при сбое на диске остался либо старый, либо новый файл целиком
+func writeFileAtomic(path string, v interface{}) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// fsync каталога, чтобы переименование тоже попало на диск
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+// Close останавливает фоновые задачи, сохраняет снимок и закрывает журнал
+func (m *MemoryStorage) Close() error {
+	p := m.persist
+	if p == nil {
+		return nil
+	}
+
+	close(p.stop)
+	p.workers.Wait()
+
+	if err := m.Snapshot(); err != nil {
+		p.wal.close()
+		return err
+	}
+
+	return p.wal.close()
+}
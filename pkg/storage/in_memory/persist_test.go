@@ -0,0 +1,395 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/ranking"
+	"github.com/leonideliseev/ozonTestTask/pkg/ratelimit"
+)
+
+func TestPersistence(t *testing.T) {
+	ctx := context.Background()
+
+	open := func(t *testing.T, dir string) *MemoryStorage {
+		m, err := NewInMemoryStore(WithPersistence(Persistence{Dir: dir, Fsync: FsyncAlways}))
+		if err != nil {
+			t.Fatalf("failed to open storage: %v", err)
+		}
+		return m
+	}
+
+	// создаёт пользователя, доску, пост на ней с реакцией, скрытый модератором
+	// комментарий с голосом и ответ
+	// ответ с ключом идемпотентности
+	reply := func(userId, parentId uint) smodel.CreateComment {
+		return smodel.CreateComment{PostId: 1, UserId: userId, Content: "reply", ParentId: &parentId,
+			Idempotency: &smodel.Idempotency{Key: "reply", Hash: "h", TTL: time.Hour}}
+	}
+
+	fill := func(t *testing.T, m *MemoryStorage) {
+		user, err := m.CreateUser(ctx, smodel.CreateUser{Username: "qwerty"})
+		if err != nil {
+			t.Fatalf("Error create user: %s", err.Error())
+		}
+		board, err := m.CreateBoard(ctx, smodel.CreateBoard{Name: "b", AllowedPosters: []uint{user.ID}})
+		if err != nil {
+			t.Fatalf("Error create board: %s", err.Error())
+		}
+		post, err := m.CreatePost(ctx, smodel.CreatePost{Title: "t", Content: "c", UserId: user.ID, CommentsEnabled: true, BoardId: &board.ID})
+		if err != nil {
+			t.Fatalf("Error create post: %s", err.Error())
+		}
+		// вторая реакция снимается, в журнале остаются обе операции
+		target := smodel.Target{Type: smodel.TargetPost, ID: post.ID}
+		for _, reaction := range []string{"like", "wow"} {
+			if _, err := m.React(ctx, smodel.React{UserId: user.ID, Target: target, Reaction: reaction}); err != nil {
+				t.Fatalf("Error react: %s", err.Error())
+			}
+		}
+		if _, err := m.Unreact(ctx, smodel.React{UserId: user.ID, Target: target, Reaction: "wow"}); err != nil {
+			t.Fatalf("Error unreact: %s", err.Error())
+		}
+		comm, err := m.CreateComment(ctx, smodel.CreateComment{PostId: post.ID, UserId: user.ID, Content: "comm"})
+		if err != nil {
+			t.Fatalf("Error create comm: %s", err.Error())
+		}
+		// голос меняется, в журнале остаются оба
+		for _, value := range []int{1, -1} {
+			if _, err := m.Vote(ctx, smodel.CastVote{UserId: user.ID, Target: smodel.Target{Type: smodel.TargetComment, ID: comm.ID}, Value: value}); err != nil {
+				t.Fatalf("Error vote: %s", err.Error())
+			}
+		}
+		report, err := m.CreateReport(ctx, smodel.CreateReport{ReporterId: &user.ID, Target: smodel.Target{Type: smodel.TargetComment, ID: comm.ID}, Reason: "spam"})
+		if err != nil {
+			t.Fatalf("Error create report: %s", err.Error())
+		}
+		if _, err := m.ResolveReport(ctx, smodel.ResolveReport{ReportId: report.ID, ModeratorId: user.ID, Action: smodel.ModerationHide, Reason: "rude"}); err != nil {
+			t.Fatalf("Error resolve report: %s", err.Error())
+		}
+		// вебхук с неудачной попыткой доставки и удалённый вебхук
+		events := []smodel.WebhookEvent{smodel.WebhookCommentCreated}
+		if _, err := m.CreateWebhook(ctx, smodel.CreateWebhook{URL: "http://localhost/hook", Events: events, Secret: "s"}); err != nil {
+			t.Fatalf("Error create webhook: %s", err.Error())
+		}
+		removed, err := m.CreateWebhook(ctx, smodel.CreateWebhook{URL: "http://localhost/removed", Events: events, Secret: "s"})
+		if err != nil {
+			t.Fatalf("Error create webhook: %s", err.Error())
+		}
+		deliveries, err := m.CreateWebhookDeliveries(ctx, smodel.CreateDeliveries{Event: smodel.WebhookCommentCreated, Payload: "{}", At: time.Now()})
+		if err != nil {
+			t.Fatalf("Error create deliveries: %s", err.Error())
+		}
+		if _, err := m.RecordDeliveryAttempt(ctx, smodel.DeliveryAttempt{DeliveryId: deliveries[0].ID, Status: smodel.DeliveryPending, NextAttemptAt: time.Now().Add(time.Hour), ResponseStatus: 500}); err != nil {
+			t.Fatalf("Error record attempt: %s", err.Error())
+		}
+		if err := m.DeleteWebhook(ctx, removed.ID); err != nil {
+			t.Fatalf("Error delete webhook: %s", err.Error())
+		}
+		// изменённый заголовок поста
+		title := "edited"
+		if _, err := m.EditPost(ctx, smodel.EditPost{PostId: post.ID, EditorId: user.ID, Title: &title}); err != nil {
+			t.Fatalf("Error edit post: %s", err.Error())
+		}
+		// удалённый и очищенный пост и удалённый, а затем восстановленный
+		for _, content := range []string{"gone", "back"} {
+			removed, err := m.CreatePost(ctx, smodel.CreatePost{Title: "t", Content: content, UserId: user.ID})
+			if err != nil {
+				t.Fatalf("Error create post: %s", err.Error())
+			}
+			target := smodel.Target{Type: smodel.TargetPost, ID: removed.ID}
+			if err := m.SoftDelete(ctx, smodel.SoftDelete{Target: target, UserId: user.ID}); err != nil {
+				t.Fatalf("Error delete post: %s", err.Error())
+			}
+			if content == "back" {
+				if err := m.Restore(ctx, smodel.Restore{Target: target}); err != nil {
+					t.Fatalf("Error restore post: %s", err.Error())
+				}
+			}
+		}
+		if _, err := m.PurgeDeleted(ctx, time.Now()); err != nil {
+			t.Fatalf("Error purge: %s", err.Error())
+		}
+		// медленный режим не даёт автору комментария ответить, поэтому отвечает другой пользователь
+		if _, err := m.SetSlowMode(ctx, smodel.SetSlowMode{PostId: post.ID, Seconds: 60}); err != nil {
+			t.Fatalf("Error set slow mode: %s", err.Error())
+		}
+		other, err := m.CreateUser(ctx, smodel.CreateUser{Username: "other"})
+		if err != nil {
+			t.Fatalf("Error create user: %s", err.Error())
+		}
+		if _, err := m.CreateComment(ctx, reply(other.ID, comm.ID)); err != nil {
+			t.Fatalf("Error create reply: %s", err.Error())
+		}
+		// удалённый аккаунт с очищенным постом
+		leaver, err := m.CreateUser(ctx, smodel.CreateUser{Username: "leaver"})
+		if err != nil {
+			t.Fatalf("Error create user: %s", err.Error())
+		}
+		if _, err := m.CreatePost(ctx, smodel.CreatePost{Title: "bye", Content: "bye", UserId: leaver.ID}); err != nil {
+			t.Fatalf("Error create post: %s", err.Error())
+		}
+		if _, err := m.AnonymizeUser(ctx, smodel.AnonymizeUser{UserId: leaver.ID, RemoveContent: true}); err != nil {
+			t.Fatalf("Error anonymize user: %s", err.Error())
+		}
+		// уведомление об ответе прочитано
+		if _, err := m.MarkNotificationsRead(ctx, user.ID, nil); err != nil {
+			t.Fatalf("Error mark notifications: %s", err.Error())
+		}
+	}
+
+	// проверяет, что после перезапуска есть всё, что создал fill
+	check := func(t *testing.T, m *MemoryStorage) {
+		post, err := m.GetPost(ctx, 20, 0, smodel.CommentSortDefault, 1)
+		if err != nil {
+			t.Fatalf("Error get post: %s", err.Error())
+		}
+		if post.User.Username != "qwerty" {
+			t.Error("expected author qwerty, got", post.User.Username)
+		}
+		if post.CommPage.TotalCount != 1 || post.CommPage.Comms[0].ReplyPage.TotalCount != 1 {
+			t.Error("expected comment with one reply, got", post.CommPage.TotalCount, "comments")
+		}
+		// индекс поиска восстанавливается вместе с данными
+		if page, err := m.Search(ctx, smodel.Search{Query: "reply", Limit: 20}); err != nil || page.TotalCount != 1 {
+			t.Error("expected 1 hit for reply, got", page, err)
+		}
+		// доска и её посты
+		boardId := uint(1)
+		if page, err := m.GetPosts(ctx, 20, 0, smodel.PostSortDefault, smodel.PostFilter{BoardId: &boardId}); err != nil || page.TotalCount != 1 {
+			t.Error("expected 1 post on board, got", page, err)
+		}
+		if board, err := m.GetBoard(ctx, boardId); err != nil || len(board.AllowedPosters) != 1 {
+			t.Error("expected board with 1 poster, got", board, err)
+		}
+		// реакции
+		counts, err := m.GetReactions(ctx, smodel.Target{Type: smodel.TargetPost, ID: 1})
+		if err != nil || len(counts) != 1 || counts[0].Reaction != "like" || counts[0].Count != 1 {
+			t.Error("expected 1 like, got", counts, err)
+		}
+		// голоса и пересчитанные по ним ранги
+		comm := post.CommPage.Comms[0]
+		if comm.Upvotes != 0 || comm.Downvotes != 1 || comm.TopRank != ranking.Top(0, 1) {
+			t.Error("expected 1 downvote, got", comm.Votes)
+		}
+		if value, err := m.GetUserVote(ctx, 1, smodel.Target{Type: smodel.TargetComment, ID: comm.ID}); err != nil || value != -1 {
+			t.Error("expected vote -1, got", value, err)
+		}
+		// решение модератора
+		if comm.Status != smodel.ContentHidden {
+			t.Error("expected hidden comment, got", comm.Status)
+		}
+		if page, err := m.GetReports(ctx, 20, 0, smodel.ReportHidden); err != nil || page.TotalCount != 1 || page.Reports[0].Resolution != "rude" {
+			t.Error("expected 1 hidden report, got", page, err)
+		}
+		// медленный режим и время последних комментариев пользователей
+		if post.SlowModeSeconds != 60 {
+			t.Error("expected slow mode 60, got", post.SlowModeSeconds)
+		}
+		var rlErr *ratelimit.Error
+		if _, err := m.CreateComment(ctx, smodel.CreateComment{PostId: 1, UserId: 1, Content: "again"}); !errors.As(err, &rlErr) {
+			t.Error("expected slow mode error, got", err)
+		}
+		// ключ идемпотентности ответа
+		if again, err := m.CreateComment(ctx, reply(2, comm.ID)); err != nil || !again.Replayed || again.ID != comm.ReplyPage.Comms[0].ID {
+			t.Error("expected replayed reply, got", again, err)
+		}
+		// уведомление об ответе с отметкой о прочтении, повтор ответа нового не создаёт
+		if page, err := m.GetNotifications(ctx, 1, 20, 0, false); err != nil || page.TotalCount != 1 || page.UnreadCount != 0 || page.Notifications[0].Kind != smodel.NotificationReply {
+			t.Error("expected 1 read reply notification, got", page, err)
+		}
+		// вебхук и доставка после попытки
+		if webhooks, err := m.GetWebhooks(ctx); err != nil || len(webhooks) != 1 || webhooks[0].URL != "http://localhost/hook" {
+			t.Error("expected 1 webhook, got", webhooks, err)
+		}
+		if page, err := m.GetWebhookDeliveries(ctx, 20, 0, smodel.DeliveryFilter{}); err != nil || page.TotalCount != 1 || page.Deliveries[0].Attempts != 1 || page.Deliveries[0].ResponseStatus != 500 {
+			t.Error("expected 1 delivery after attempt, got", page, err)
+		}
+		if due, err := m.ClaimWebhookDeliveries(ctx, time.Now().Add(2*time.Hour), time.Now().Add(2*time.Hour), 20); err != nil || len(due) != 1 {
+			t.Error("expected 1 pending delivery, got", due, err)
+		}
+		// версии поста и текст из последней версии
+		if post.Title != "edited" || post.EditedAt == nil {
+			t.Error("expected edited title, got", post.Title, post.EditedAt)
+		}
+		if page, err := m.GetRevisions(ctx, smodel.Target{Type: smodel.TargetPost, ID: 1}, 20, 0); err != nil || page.TotalCount != 2 || page.Revisions[0].Title != "t" || page.Revisions[1].Number != 2 {
+			t.Error("expected 2 revisions, got", page, err)
+		}
+		if page, err := m.Search(ctx, smodel.Search{Query: "edited", Limit: 20}); err != nil || page.TotalCount != 1 {
+			t.Error("expected 1 hit for edited, got", page, err)
+		}
+		// удалённый пост не выдаётся в списке и после очистки остаётся без текста
+		if page, err := m.GetPosts(ctx, 20, 0, smodel.PostSortDefault, smodel.PostFilter{}); err != nil || page.TotalCount != 2 || page.Posts[1].Content != "back" {
+			t.Error("expected 2 posts without deleted, got", page, err)
+		}
+		if page, err := m.GetPosts(ctx, 20, 0, smodel.PostSortDefault, smodel.PostFilter{WithDeleted: true}); err != nil || page.TotalCount != 4 {
+			t.Error("expected 4 posts with deleted, got", page, err)
+		}
+		if purged, err := m.GetPost(ctx, 20, 0, smodel.CommentSortDefault, 2); err != nil || purged.RemovedAt == nil || purged.PurgedAt == nil || purged.Content != "" {
+			t.Error("expected purged post, got", purged, err)
+		}
+		if page, err := m.Search(ctx, smodel.Search{Query: "gone", Limit: 20}); err != nil || page.TotalCount != 0 {
+			t.Error("expected no hits for purged post, got", page, err)
+		}
+		// анонимный автор очищенного поста, прежний username свободен
+		if user, err := m.GetUserByUsername(ctx, smodel.AnonymousUsername(3)); err != nil || user.AnonymizedAt == nil {
+			t.Error("expected anonymous user, got", user, err)
+		}
+		if _, err := m.GetUserByUsername(ctx, "leaver"); err == nil {
+			t.Error("expected username leaver to be free")
+		}
+		if bye, err := m.GetPost(ctx, 20, 0, smodel.CommentSortDefault, 4); err != nil || bye.User.Username != smodel.AnonymousUsername(3) || bye.Content != "" || bye.PurgedAt == nil {
+			t.Error("expected purged post of anonymous user, got", bye, err)
+		}
+		// счётчики не считаются дважды для комментариев из снимка
+		if post.CommentCount != 2 || post.TopLevelCommentCount != 1 {
+			t.Error("expected 2 comments and 1 top level, got", post.CommentCount, post.TopLevelCommentCount)
+		}
+	}
+
+	t.Run("ReplayWAL", func(t *testing.T) {
+		dir := t.TempDir()
+		m := open(t, dir)
+		fill(t, m)
+		// без Close: журнал не свёрнут в снимок, как после падения
+		m.persist.wal.close()
+
+		check(t, open(t, dir))
+	})
+
+	t.Run("SnapshotAndWAL", func(t *testing.T) {
+		dir := t.TempDir()
+		m := open(t, dir)
+		fill(t, m)
+		if err := m.Snapshot(); err != nil {
+			t.Fatalf("Error snapshot: %s", err.Error())
+		}
+		if _, err := m.CreateUser(ctx, smodel.CreateUser{Username: "after"}); err != nil {
+			t.Fatalf("Error create user: %s", err.Error())
+		}
+		m.persist.wal.close()
+
+		m = open(t, dir)
+		check(t, m)
+		if _, ok := m.users[2]; !ok {
+			t.Error("expected user created after snapshot")
+		}
+	})
+
+	t.Run("TruncatedTail", func(t *testing.T) {
+		dir := t.TempDir()
+		m := open(t, dir)
+		fill(t, m)
+		m.persist.wal.close()
+
+		// обрыв последней записи, как при падении во время записи
+		path := filepath.Join(dir, walFile)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Truncate(path, info.Size()-3); err != nil {
+			t.Fatal(err)
+		}
+
+		// последняя запись - отметка уведомлений прочитанными
+		m = open(t, dir)
+		if page, err := m.GetNotifications(ctx, 1, 20, 0, true); err != nil || page.UnreadCount != 1 {
+			t.Error("expected unread notification before broken record, got", page, err)
+		}
+
+		// после отбрасывания хвоста журнал снова пригоден для записи
+		if _, err := m.CreateUser(ctx, smodel.CreateUser{Username: "next"}); err != nil {
+			t.Fatalf("Error create user: %s", err.Error())
+		}
+		m.persist.wal.close()
+
+		if m = open(t, dir); len(m.users) != 4 {
+			t.Error("expected 4 users, got", len(m.users))
+		}
+	})
+
+	t.Run("CorruptRecord", func(t *testing.T) {
+		dir := t.TempDir()
+		m := open(t, dir)
+		fill(t, m)
+		m.persist.wal.close()
+
+		// порча данных последнего байта журнала
+		path := filepath.Join(dir, walFile)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[len(data)-1] ^= 0xff
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		m = open(t, dir)
+		if page, err := m.GetNotifications(ctx, 1, 20, 0, true); err != nil || page.UnreadCount != 1 {
+			t.Error("expected unread notification before corrupt record, got", page, err)
+		}
+	})
+
+	t.Run("CorruptMiddle", func(t *testing.T) {
+		dir := t.TempDir()
+		m := open(t, dir)
+		fill(t, m)
+		m.persist.wal.close()
+
+		// порча первой записи, после которой есть подтверждённые
+		path := filepath.Join(dir, walFile)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[frameHeaderSize] ^= 0xff
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewInMemoryStore(WithPersistence(Persistence{Dir: dir, Fsync: FsyncAlways})); !errors.Is(err, errCorruptWAL) {
+			t.Fatal("expected corrupt wal error, got", err)
+		}
+		// журнал не обрезан
+		if info, err := os.Stat(path); err != nil || info.Size() != int64(len(data)) {
+			t.Error("expected wal to be kept, got", info, err)
+		}
+	})
+
+	t.Run("SequencesAfterRestore", func(t *testing.T) {
+		dir := t.TempDir()
+		m := open(t, dir)
+		fill(t, m)
+
+		// пропущенные id, например после неудачной записи в журнал
+		m.userSeq.next()
+		m.commentSeq.next()
+		if err := m.Close(); err != nil {
+			t.Fatalf("Error close: %s", err.Error())
+		}
+
+		m = open(t, dir)
+		user, err := m.CreateUser(ctx, smodel.CreateUser{Username: "next"})
+		if err != nil {
+			t.Fatalf("Error create user: %s", err.Error())
+		}
+		if user.ID != 5 {
+			t.Error("expected user id 5, got", user.ID)
+		}
+
+		comm, err := m.CreateComment(ctx, smodel.CreateComment{PostId: 1, UserId: user.ID, Content: "c"})
+		if err != nil {
+			t.Fatalf("Error create comm: %s", err.Error())
+		}
+		if comm.ID != 4 {
+			t.Error("expected comment id 4, got", comm.ID)
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// reactionSet - реакции на пост или комментарий, лежат в шарде поста
+// и меняются под его блокировкой вместе с количествами
+type reactionSet struct {
+	// реакции по пользователю и имени реакции
+	users  map[uint]map[string]smodel.Reaction
+	counts map[string]int
+}
+
+func (m *MemoryStorage) React(ctx context.Context, r smodel.React) (*smodel.ReactionSummary, error) {
+	return m.changeReaction(ctx, r, true)
+}
+
+func (m *MemoryStorage) Unreact(ctx context.Context, r smodel.React) (*smodel.ReactionSummary, error) {
+	return m.changeReaction(ctx, r, false)
+}
+
+// changeReaction ставит (add) или снимает реакцию
+func (m *MemoryStorage) changeReaction(ctx context.Context, r smodel.React, add bool) (*smodel.ReactionSummary, error) {
+	m.mu.RLock()
+	_, userExist := m.users[r.UserId]
+	m.mu.RUnlock()
+
+	if !userExist {
+		return nil, errors.New(u.ErrorUserId(r.UserId))
+	}
+
+	shard, err := m.targetShard(r.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	var exists bool
+	if set, ok := shard.reactions[r.Target]; ok {
+		_, exists = set.users[r.UserId][r.Reaction]
+	}
+
+	summary := &smodel.ReactionSummary{
+		PostID:  shard.id,
+		Changed: exists != add,
+	}
+
+	if summary.Changed {
+		reaction := smodel.Reaction{
+			UserID:     r.UserId,
+			TargetType: r.Target.Type,
+			TargetID:   r.Target.ID,
+			Reaction:   r.Reaction,
+			CreatedAt:  time.Now(),
+		}
+
+		op := opUnreact
+		if add {
+			op = opReact
+		}
+		if err := m.log(record{Op: op, Reaction: &reaction}); err != nil {
+			return nil, err
+		}
+		shard.applyReaction(reaction, add)
+	}
+
+	summary.Counts = shard.reactionCounts(r.Target)
+	return summary, nil
+}
+
+// targetShard находит шард поста, к которому относится пост или комментарий
+func (m *MemoryStorage) targetShard(target smodel.Target) (*postShard, error) {
+	if target.Type == smodel.TargetPost {
+		m.mu.RLock()
+		shard, ok := m.posts[target.ID]
+		m.mu.RUnlock()
+
+		if !ok {
+			return nil, errors.New(u.ErrorPostId(target.ID))
+		}
+		return shard, nil
+	}
+
+	shard, ok := m.commentShard(target.ID)
+	if !ok {
+		return nil, errors.New(u.ErrorCommId(target.ID))
+	}
+	return shard, nil
+}
+
+// applyReaction ставит или снимает реакцию, вызывается под блокировкой шарда
+func (s *postShard) applyReaction(reaction smodel.Reaction, add bool) {
+	target := smodel.Target{Type: reaction.TargetType, ID: reaction.TargetID}
+
+	set, ok := s.reactions[target]
+	if !ok {
+		set = &reactionSet{
+			users:  make(map[uint]map[string]smodel.Reaction),
+			counts: make(map[string]int),
+		}
+		s.reactions[target] = set
+	}
+
+	user := set.users[reaction.UserID]
+	if add {
+		if user == nil {
+			user = make(map[string]smodel.Reaction)
+			set.users[reaction.UserID] = user
+		}
+		user[reaction.Reaction] = reaction
+		set.counts[reaction.Reaction]++
+		return
+	}
+
+	delete(user, reaction.Reaction)
+	if len(user) == 0 {
+		delete(set.users, reaction.UserID)
+	}
+	if set.counts[reaction.Reaction]--; set.counts[reaction.Reaction] <= 0 {
+		delete(set.counts, reaction.Reaction)
+	}
+}
+
+// reactionCounts возвращает количества реакций в порядке имён,
+// вызывается под блокировкой шарда
+func (s *postShard) reactionCounts(target smodel.Target) []*smodel.ReactionCount {
+	counts := []*smodel.ReactionCount{}
+	set, ok := s.reactions[target]
+	if !ok {
+		return counts
+	}
+
+	for reaction, count := range set.counts {
+		counts = append(counts, &smodel.ReactionCount{
+			TargetType: target.Type,
+			TargetID:   target.ID,
+			Reaction:   reaction,
+			Count:      count,
+		})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Reaction < counts[j].Reaction })
+
+	return counts
+}
+
+func (m *MemoryStorage) GetReactions(ctx context.Context, target smodel.Target) ([]*smodel.ReactionCount, error) {
+	shard, err := m.targetShard(target)
+	if err != nil {
+		return nil, err
+	}
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	return shard.reactionCounts(target), nil
+}
+
+func (m *MemoryStorage) GetUserReactions(ctx context.Context, userId uint, target smodel.Target) ([]string, error) {
+	shard, err := m.targetShard(target)
+	if err != nil {
+		return nil, err
+	}
+
+	shard.mu.RLock()
+	reactions := []string{}
+	if set, ok := shard.reactions[target]; ok {
+		for reaction := range set.users[userId] {
+			reactions = append(reactions, reaction)
+		}
+	}
+	shard.mu.RUnlock()
+
+	sort.Strings(reactions)
+	return reactions, nil
+}
+
+// restoreReaction применяет реакцию при восстановлении
+func (m *MemoryStorage) restoreReaction(reaction smodel.Reaction, add bool) error {
+	shard, err := m.targetShard(smodel.Target{Type: reaction.TargetType, ID: reaction.TargetID})
+	if err != nil {
+		return err
+	}
+
+	shard.applyReaction(reaction, add)
+	return nil
+}
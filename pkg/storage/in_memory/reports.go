@@ -0,0 +1,202 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// resolution - решение модератора в журнале
+type resolution struct {
+	smodel.ResolveReport
+	ResolvedAt time.Time `json:"resolvedAt"`
+	// версии, сохранённые перед удалением текста
+	Revisions []smodel.Revision `json:"revisions,omitempty"`
+}
+
+func (m *MemoryStorage) CreateReport(ctx context.Context, r smodel.CreateReport) (*smodel.Report, error) {
+	if r.ReporterId != nil {
+		m.mu.RLock()
+		_, userExist := m.users[*r.ReporterId]
+		m.mu.RUnlock()
+
+		if !userExist {
+			return nil, errors.New(u.ErrorUserId(*r.ReporterId))
+		}
+	}
+
+	shard, err := m.targetShard(r.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	m.reportsMu.Lock()
+	defer m.reportsMu.Unlock()
+
+	report := smodel.Report{
+		ID:         m.reportSeq.next(),
+		TargetType: r.Target.Type,
+		TargetID:   r.Target.ID,
+		PostID:     shard.id,
+		ReporterID: r.ReporterId,
+		Reason:     r.Reason,
+		Status:     smodel.ReportOpen,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := m.log(record{Op: opCreateReport, Report: &report}); err != nil {
+		return nil, err
+	}
+	m.applyReport(report)
+
+	return &report, nil
+}
+
+// applyReport добавляет жалобу, вызывается под reportsMu
+func (m *MemoryStorage) applyReport(report smodel.Report) {
+	m.reportSeq.advance(report.ID)
+	m.reports[report.ID] = report
+	m.reportIds = append(m.reportIds, report.ID)
+
+	target := smodel.Target{Type: report.TargetType, ID: report.TargetID}
+	m.targetReports[target] = append(m.targetReports[target], report.ID)
+}
+
+func (m *MemoryStorage) GetReports(ctx context.Context, limit, offset int, status smodel.ReportStatus) (*smodel.ReportPage, error) {
+	switch status {
+	case smodel.ReportOpen, smodel.ReportApproved, smodel.ReportHidden, smodel.ReportDeleted:
+	default:
+		return nil, errors.New(u.ErrorReportStatus(string(status)))
+	}
+
+	m.reportsMu.RLock()
+	defer m.reportsMu.RUnlock()
+
+	var ids []uint
+	for _, id := range m.reportIds {
+		if m.reports[id].Status == status {
+			ids = append(ids, id)
+		}
+	}
+
+	reports := make([]*smodel.Report, 0)
+	for _, id := range page(ids, limit, offset) {
+		report := m.reports[id]
+		reports = append(reports, &report)
+	}
+
+	return &smodel.ReportPage{
+		Reports:    reports,
+		TotalCount: len(ids),
+	}, nil
+}
+
+func (m *MemoryStorage) ResolveReport(ctx context.Context, r smodel.ResolveReport) (*smodel.Report, error) {
+	if _, ok := smodel.ModerationResults[r.Action]; !ok {
+		return nil, errors.New(u.ErrorModerationAction(string(r.Action)))
+	}
+
+	m.reportsMu.RLock()
+	report, ok := m.reports[r.ReportId]
+	m.reportsMu.RUnlock()
+
+	if !ok {
+		return nil, errors.New(u.ErrorReportId(r.ReportId))
+	}
+
+	m.mu.RLock()
+	_, userExist := m.users[r.ModeratorId]
+	m.mu.RUnlock()
+
+	if !userExist {
+		return nil, errors.New(u.ErrorUserId(r.ModeratorId))
+	}
+
+	shard, err := m.targetShard(smodel.Target{Type: report.TargetType, ID: report.TargetID})
+	if err != nil {
+		return nil, err
+	}
+
+	// запись и жалобы на неё меняются под блокировками шарда и жалоб,
+	// поэтому одну жалобу не закроют два модератора
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	m.reportsMu.Lock()
+	defer m.reportsMu.Unlock()
+
+	if m.reports[r.ReportId].Status != smodel.ReportOpen {
+		return nil, errors.New(u.ErrorReportResolved(r.ReportId))
+	}
+
+	res := resolution{ResolveReport: r, ResolvedAt: time.Now()}
+	// удалённый текст остаётся в истории версий
+	if smodel.ModerationResults[r.Action].Content == smodel.ContentDeleted {
+		target := smodel.Target{Type: report.TargetType, ID: report.TargetID}
+		res.Revisions = m.newRevisions(shard, target, smodel.Revision{EditorID: r.ModeratorId, CreatedAt: res.ResolvedAt})
+	}
+	if err := m.log(record{Op: opResolveReport, Resolution: &res}); err != nil {
+		return nil, err
+	}
+	m.applyResolution(shard, res)
+
+	report = m.reports[r.ReportId]
+	return &report, nil
+}
+
+// applyResolution меняет статус записи и закрывает открытые жалобы на неё.
+// Вызывается под блокировками шарда и reportsMu
+func (m *MemoryStorage) applyResolution(shard *postShard, res resolution) {
+	result := smodel.ModerationResults[res.Action]
+	report := m.reports[res.ReportId]
+	target := smodel.Target{Type: report.TargetType, ID: report.TargetID}
+
+	m.applyRevisions(shard, res.Revisions)
+	// при удалении текст стирается
+	if target.Type == smodel.TargetPost {
+		shard.post.Status = result.Content
+		if result.Content == smodel.ContentDeleted {
+			shard.post.Title, shard.post.Content = "", ""
+		}
+	} else {
+		comment := shard.comments[target.ID]
+		comment.Status = result.Content
+		if result.Content == smodel.ContentDeleted {
+			comment.Content = ""
+		}
+		shard.comments[target.ID] = comment
+	}
+
+	for _, id := range m.targetReports[target] {
+		report := m.reports[id]
+		if report.Status != smodel.ReportOpen {
+			continue
+		}
+
+		resolvedAt := res.ResolvedAt
+		moderatorId := res.ModeratorId
+		report.Status = result.Report
+		report.ModeratorID = &moderatorId
+		report.Resolution = res.Reason
+		report.ResolvedAt = &resolvedAt
+		m.reports[id] = report
+	}
+}
+
+// restoreResolution применяет решение модератора при восстановлении
+func (m *MemoryStorage) restoreResolution(res resolution) error {
+	report, ok := m.reports[res.ReportId]
+	if !ok {
+		return errors.New(u.ErrorReportId(res.ReportId))
+	}
+
+	shard, err := m.targetShard(smodel.Target{Type: report.TargetType, ID: report.TargetID})
+	if err != nil {
+		return err
+	}
+
+	m.applyResolution(shard, res)
+	return nil
+}
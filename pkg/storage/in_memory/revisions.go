@@ -0,0 +1,236 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Версии поста и его комментариев лежат в шарде поста и меняются под его
+// блокировкой. В журнал пишутся сами новые версии, а текст записи при
+// восстановлении берётся из последней из них
+
+func (m *MemoryStorage) EditPost(ctx context.Context, e smodel.EditPost) (*smodel.Post, error) {
+	m.mu.RLock()
+	shard, ok := m.posts[e.PostId]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, errors.New(u.ErrorPostId(e.PostId))
+	}
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	target := smodel.Target{Type: smodel.TargetPost, ID: e.PostId}
+	if err := u.CheckEdit(target, shard.post.UserID, shard.post.Status, shard.post.RemovedAt, e.EditorId); err != nil {
+		return nil, err
+	}
+
+	title, content, err := u.MergeEdit(target, shard.post.Title, shard.post.Content, e)
+	if err != nil {
+		return nil, err
+	}
+
+	next := smodel.Revision{Title: title, Content: content, EditorID: e.EditorId, CreatedAt: time.Now()}
+
+	revisions := m.newRevisions(shard, target, next)
+	if len(revisions) == 0 {
+		return shard.postCopy(), nil
+	}
+
+	if err := m.log(record{Op: opEdit, Revisions: revisions}); err != nil {
+		return nil, err
+	}
+	m.applyEdit(shard, revisions)
+
+	return shard.postCopy(), nil
+}
+
+func (m *MemoryStorage) EditComment(ctx context.Context, e smodel.EditComment) (*smodel.Comment, error) {
+	shard, ok := m.commentShard(e.CommentId)
+	if !ok {
+		return nil, errors.New(u.ErrorCommId(e.CommentId))
+	}
+
+	// доски не меняются, поэтому настройки можно проверить без блокировки шарда
+	m.mu.RLock()
+	board := m.boards[shard.board]
+	m.mu.RUnlock()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	comment := shard.comments[e.CommentId]
+	target := smodel.Target{Type: smodel.TargetComment, ID: e.CommentId}
+	if err := u.CheckEdit(target, comment.UserID, comment.Status, comment.RemovedAt, e.EditorId); err != nil {
+		return nil, err
+	}
+	if e.Content == comment.Content {
+		return &comment, nil
+	}
+	if err := checkCommentLength(board, e.Content); err != nil {
+		return nil, err
+	}
+
+	revisions := m.newRevisions(shard, target, smodel.Revision{Content: e.Content, EditorID: e.EditorId, CreatedAt: time.Now()})
+	if err := m.log(record{Op: opEdit, Revisions: revisions}); err != nil {
+		return nil, err
+	}
+	m.applyEdit(shard, revisions)
+
+	comment = shard.comments[e.CommentId]
+	return &comment, nil
+}
+
+// newRevisions возвращает версии, которые нужно сохранить для нового текста
+// записи: ничего, если текст не изменился, иначе новую версию, а при первом
+// изменении перед ней исходную. Вызывается под блокировкой шарда
+func (m *MemoryStorage) newRevisions(shard *postShard, target smodel.Target, next smodel.Revision) []smodel.Revision {
+	var revisions []smodel.Revision
+
+	last := shard.lastRevision(target)
+	if last.Title == next.Title && last.Content == next.Content {
+		return nil
+	}
+
+	// исходная версия ещё не сохранена
+	if last.ID == 0 {
+		last.ID = m.revisionSeq.next()
+		revisions = append(revisions, last)
+	}
+
+	next.ID = m.revisionSeq.next()
+	next.TargetType, next.TargetID, next.Number = target.Type, target.ID, last.Number+1
+
+	return append(revisions, next)
+}
+
+// lastRevision возвращает последнюю версию записи или её текущий текст как
+// версию 1 с нулевым id, если версий нет. Вызывается под блокировкой шарда
+func (s *postShard) lastRevision(target smodel.Target) smodel.Revision {
+	if revisions := s.revisions[target]; len(revisions) > 0 {
+		return revisions[len(revisions)-1]
+	}
+
+	revision := smodel.Revision{TargetType: target.Type, TargetID: target.ID, Number: 1}
+	if target.Type == smodel.TargetPost {
+		revision.Title, revision.Content = s.post.Title, s.post.Content
+		revision.EditorID, revision.CreatedAt = s.post.UserID, s.post.CreatedAt
+	} else {
+		comment := s.comments[target.ID]
+		revision.Content = comment.Content
+		revision.EditorID, revision.CreatedAt = comment.UserID, comment.CreatedAt
+	}
+
+	return revision
+}
+
+// applyRevisions добавляет версии записей, вызывается под блокировкой шарда
+func (m *MemoryStorage) applyRevisions(shard *postShard, revisions []smodel.Revision) {
+	for _, revision := range revisions {
+		m.revisionSeq.advance(revision.ID)
+
+		target := smodel.Target{Type: revision.TargetType, ID: revision.TargetID}
+		shard.revisions[target] = append(shard.revisions[target], revision)
+	}
+}
+
+// applyEdit сохраняет версии и меняет текст записи на последнюю из них.
+// Вызывается под блокировкой шарда
+func (m *MemoryStorage) applyEdit(shard *postShard, revisions []smodel.Revision) {
+	m.applyRevisions(shard, revisions)
+
+	last := revisions[len(revisions)-1]
+	// указатель заменяется, а не меняется: его копии уже отданы из хранилища
+	editedAt := last.CreatedAt
+
+	if last.TargetType == smodel.TargetPost {
+		key := docKey{id: last.TargetID}
+		m.search.remove(key, weighted{shard.post.Title, titleWeight}, weighted{shard.post.Content, 1})
+		m.search.add(key, weighted{last.Title, titleWeight}, weighted{last.Content, 1})
+
+		shard.post.Title, shard.post.Content, shard.post.EditedAt = last.Title, last.Content, &editedAt
+		return
+	}
+
+	comment := shard.comments[last.TargetID]
+	key := docKey{comment: true, id: last.TargetID}
+	m.search.remove(key, weighted{comment.Content, 1})
+	m.search.add(key, weighted{last.Content, 1})
+
+	comment.Content, comment.EditedAt = last.Content, &editedAt
+	shard.comments[last.TargetID] = comment
+}
+
+// restoreRevisions применяет версии записи при восстановлении,
+// при edit меняется и текст записи
+func (m *MemoryStorage) restoreRevisions(revisions []smodel.Revision, edit bool) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	shard, err := m.targetShard(smodel.Target{Type: revisions[0].TargetType, ID: revisions[0].TargetID})
+	if err != nil {
+		return err
+	}
+
+	if edit {
+		m.applyEdit(shard, revisions)
+	} else {
+		m.applyRevisions(shard, revisions)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) GetRevisions(ctx context.Context, target smodel.Target, limit, offset int) (*smodel.RevisionPage, error) {
+	shard, err := m.targetShard(target)
+	if err != nil {
+		return nil, err
+	}
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	revisions := shard.revisions[target]
+	// запись не менялась
+	if len(revisions) == 0 {
+		revisions = []smodel.Revision{shard.lastRevision(target)}
+	}
+
+	start, end := bounds(len(revisions), limit, offset)
+	page := smodel.RevisionPage{Revisions: make([]*smodel.Revision, 0, end-start), TotalCount: len(revisions)}
+	for _, revision := range revisions[start:end] {
+		revision := revision
+		page.Revisions = append(page.Revisions, &revision)
+	}
+
+	return &page, nil
+}
+
+func (m *MemoryStorage) GetRevision(ctx context.Context, target smodel.Target, number int) (*smodel.Revision, error) {
+	shard, err := m.targetShard(target)
+	if err != nil {
+		return nil, err
+	}
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	revisions := shard.revisions[target]
+	// у записи без версий есть только версия 1 - текущий текст
+	if len(revisions) == 0 {
+		revisions = []smodel.Revision{shard.lastRevision(target)}
+	}
+
+	// номера версий идут подряд с 1
+	if number < 1 || number > len(revisions) {
+		return nil, errors.New(u.ErrorRevision(target, number))
+	}
+
+	revision := revisions[number-1]
+	return &revision, nil
+}
@@ -0,0 +1,351 @@
+package memory
+
+import (
+	"context"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Поиск по инвертированному индексу: для каждого слова хранится, в каких
+// постах и комментариях оно встречается и с каким весом. Индекс дополняется
+// при применении поста или комментария, поэтому восстанавливается вместе
+// с ними из снимка и журнала. Блокировка индекса берётся последней,
+// как commentsMu
+
+// вес слова из заголовка поста, слова из текста весят 1
+const titleWeight = 2
+
+// сколько слов вокруг первого совпадения попадает во фрагмент
+const (
+	snippetBefore = 5
+	snippetWords  = 30
+)
+
+// документ индекса - пост или комментарий
+type docKey struct {
+	comment bool
+	id      uint
+}
+
+type searchIndex struct {
+	mu sync.RWMutex
+	// слово -> документ -> вес слова в документе
+	terms map[string]map[docKey]float64
+	docs  int
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{terms: make(map[string]map[docKey]float64)}
+}
+
+// текст документа и вес его слов
+type weighted struct {
+	text   string
+	weight float64
+}
+
+// add добавляет в индекс документ из текстов с весами
+func (x *searchIndex) add(key docKey, texts ...weighted) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.docs++
+	for _, t := range texts {
+		for _, term := range u.Tokenize(t.text) {
+			docs, ok := x.terms[term]
+			if !ok {
+				docs = make(map[docKey]float64)
+				x.terms[term] = docs
+			}
+			docs[key] += t.weight
+		}
+	}
+}
+
+// remove убирает из индекса документ, добавленный с теми же текстами
+func (x *searchIndex) remove(key docKey, texts ...weighted) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.docs--
+	for _, t := range texts {
+		for _, term := range u.Tokenize(t.text) {
+			docs := x.terms[term]
+			if docs[key] -= t.weight; docs[key] <= 0 {
+				delete(docs, key)
+			}
+			if len(docs) == 0 {
+				delete(x.terms, term)
+			}
+		}
+	}
+}
+
+// match возвращает документы, в которых есть все слова, и их ранг tf-idf
+func (x *searchIndex) match(terms []string) map[docKey]float64 {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var ranks map[docKey]float64
+	for _, term := range terms {
+		docs := x.terms[term]
+		idf := math.Log(1 + float64(x.docs)/float64(len(docs)+1))
+
+		next := make(map[docKey]float64)
+		for key, weight := range docs {
+			if ranks == nil {
+				next[key] = weight * idf
+			} else if rank, ok := ranks[key]; ok {
+				next[key] = rank + weight*idf
+			}
+		}
+		ranks = next
+
+		if len(ranks) == 0 {
+			break
+		}
+	}
+
+	return ranks
+}
+
+func (m *MemoryStorage) Search(ctx context.Context, q smodel.Search) (*smodel.SearchPage, error) {
+	terms := unique(u.Tokenize(q.Query))
+	if len(terms) == 0 {
+		return &smodel.SearchPage{Hits: []*smodel.SearchHit{}}, nil
+	}
+
+	ranks := m.search.match(terms)
+
+	hits := make([]*smodel.SearchHit, 0, len(ranks))
+	for key, rank := range ranks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		hit := &smodel.SearchHit{Rank: rank}
+		if key.comment {
+			if q.Type == smodel.SearchPosts {
+				continue
+			}
+			comm, ok := m.comment(key.id)
+			if !ok || comm.Status != smodel.ContentVisible || comm.RemovedAt != nil || !matchFilters(q, comm.UserID, comm.CreatedAt) {
+				continue
+			}
+			// комментарии скрытых и удалённых постов тоже не ищутся
+			if post, ok := m.post(comm.PostID); !ok || post.Status != smodel.ContentVisible || post.RemovedAt != nil {
+				continue
+			}
+			hit.Comment = comm
+		} else {
+			if q.Type == smodel.SearchComments {
+				continue
+			}
+			post, ok := m.post(key.id)
+			if !ok || post.Status != smodel.ContentVisible || post.RemovedAt != nil || !matchFilters(q, post.UserID, post.CreatedAt) {
+				continue
+			}
+			hit.Post = post
+		}
+		hits = append(hits, hit)
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if !hitCreatedAt(a).Equal(hitCreatedAt(b)) {
+			return hitCreatedAt(a).After(hitCreatedAt(b))
+		}
+		return hitId(a) > hitId(b)
+	})
+
+	totalCount := len(hits)
+	start, end := bounds(len(hits), q.Limit, q.Offset)
+	hits = hits[start:end]
+
+	// фрагменты строятся только для страницы
+	set := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		set[term] = true
+	}
+	for _, hit := range hits {
+		if hit.Post != nil {
+			hit.Snippet = snippet(hit.Post.Title+" "+hit.Post.Content, set)
+		} else {
+			hit.Snippet = snippet(hit.Comment.Content, set)
+		}
+	}
+
+	return &smodel.SearchPage{
+		Hits:       hits,
+		TotalCount: totalCount,
+	}, nil
+}
+
+// post возвращает копию поста без комментариев
+func (m *MemoryStorage) post(id uint) (*smodel.Post, bool) {
+	m.mu.RLock()
+	shard, ok := m.posts[id]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	return shard.postCopy(), true
+}
+
+// comment возвращает копию комментария без ответов
+func (m *MemoryStorage) comment(id uint) (*smodel.Comment, bool) {
+	shard, ok := m.commentShard(id)
+	if !ok {
+		return nil, false
+	}
+
+	shard.mu.RLock()
+	comm := shard.comments[id]
+	shard.mu.RUnlock()
+
+	comm.ReplyPage = &smodel.CommPage{
+		Comms:      []*smodel.Comment{},
+		TotalCount: 0,
+	}
+
+	return &comm, true
+}
+
+// matchFilters проверяет автора и дату создания найденной записи
+func matchFilters(q smodel.Search, userId uint, createdAt time.Time) bool {
+	if q.AuthorId != nil && *q.AuthorId != userId {
+		return false
+	}
+	if q.From != nil && createdAt.Before(*q.From) {
+		return false
+	}
+	if q.To != nil && !createdAt.Before(*q.To) {
+		return false
+	}
+
+	return true
+}
+
+func hitCreatedAt(hit *smodel.SearchHit) time.Time {
+	if hit.Post != nil {
+		return hit.Post.CreatedAt
+	}
+	return hit.Comment.CreatedAt
+}
+
+func hitId(hit *smodel.SearchHit) uint {
+	if hit.Post != nil {
+		return hit.Post.ID
+	}
+	return hit.Comment.ID
+}
+
+// snippet возвращает фрагмент text вокруг первого найденного слова,
+// найденные слова выделены <b></b>, как в ts_headline из postgres.
+// Остальной текст экранируется как HTML
+func snippet(text string, terms map[string]bool) string {
+	// текст делится на слова и промежутки между ними
+	type segment struct {
+		text string
+		word bool
+	}
+	var segments []segment
+	for len(text) > 0 {
+		isWord := isWordRune(firstRune(text))
+		end := strings.IndexFunc(text, func(r rune) bool { return isWordRune(r) != isWord })
+		if end < 0 {
+			end = len(text)
+		}
+		segments = append(segments, segment{text: text[:end], word: isWord})
+		text = text[end:]
+	}
+
+	// номер сегмента первого найденного слова
+	first, words := 0, 0
+	for i, seg := range segments {
+		if seg.word && terms[strings.ToLower(seg.text)] {
+			first = i
+			break
+		}
+	}
+
+	// начало фрагмента за snippetBefore слов до найденного
+	start := first
+	for start > 0 && words < snippetBefore {
+		start--
+		if segments[start].word {
+			words++
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+
+	words = 0
+	end := start
+	for ; end < len(segments) && words < snippetWords; end++ {
+		seg := segments[end]
+		// текст пользователя экранируется, тегами остаётся только выделение
+		if !seg.word {
+			if end > start {
+				b.WriteString(html.EscapeString(seg.text))
+			}
+			continue
+		}
+
+		words++
+		if terms[strings.ToLower(seg.text)] {
+			b.WriteString("<b>" + html.EscapeString(seg.text) + "</b>")
+		} else {
+			b.WriteString(html.EscapeString(seg.text))
+		}
+	}
+
+	if end < len(segments) {
+		b.WriteString("…")
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
+
+// unique убирает повторы, сохраняя порядок
+func unique(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	res := terms[:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			res = append(res, term)
+		}
+	}
+	return res
+}
@@ -0,0 +1,31 @@
+package memory
+
+import "sync/atomic"
+
+// sequence выдаёт возрастающие id сущностей, как последовательности в postgres.
+// id не зависит от числа сущностей и не выдаётся повторно, даже если сущность
+// удалена или её запись в журнал не удалась. Значение последовательности
+// сохраняется в снимке, поэтому после восстановления выдача продолжается с того же места
+type sequence struct {
+	last atomic.Uint64
+}
+
+// next возвращает следующий id
+func (s *sequence) next() uint {
+	return uint(s.last.Add(1))
+}
+
+// advance сдвигает последовательность так, чтобы она не выдала уже занятый id.
+// Используется при восстановлении из журнала
+func (s *sequence) advance(id uint) {
+	for {
+		last := s.last.Load()
+		if uint64(id) <= last || s.last.CompareAndSwap(last, uint64(id)) {
+			return
+		}
+	}
+}
+
+func (s *sequence) value() uint64 {
+	return s.last.Load()
+}
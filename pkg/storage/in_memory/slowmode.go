@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/ratelimit"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+func (m *MemoryStorage) SetSlowMode(ctx context.Context, s smodel.SetSlowMode) (*smodel.Post, error) {
+	m.mu.RLock()
+	shard, ok := m.posts[s.PostId]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, errors.New(u.ErrorPostId(s.PostId))
+	}
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if err := m.log(record{Op: opSetSlowMode, SlowMode: &s}); err != nil {
+		return nil, err
+	}
+	shard.post.SlowModeSeconds = s.Seconds
+
+	return shard.postCopy(), nil
+}
+
+// restoreSlowMode меняет медленный режим поста при восстановлении
+func (m *MemoryStorage) restoreSlowMode(s smodel.SetSlowMode) error {
+	shard, ok := m.posts[s.PostId]
+	if !ok {
+		return errors.New(u.ErrorPostId(s.PostId))
+	}
+
+	shard.post.SlowModeSeconds = s.Seconds
+	return nil
+}
+
+// checkSlowMode проверяет, что с прошлого комментария пользователя к посту
+// прошёл интервал медленного режима. Вызывается под блокировкой шарда
+func (s *postShard) checkSlowMode(userId uint, now time.Time) error {
+	if s.post.SlowModeSeconds == 0 {
+		return nil
+	}
+
+	last, ok := s.lastComment[userId]
+	if !ok {
+		return nil
+	}
+
+	if wait := last.Add(time.Duration(s.post.SlowModeSeconds) * time.Second).Sub(now); wait > 0 {
+		return &ratelimit.Error{RetryAfter: wait, Reason: u.ErrorSlowMode(s.post.SlowModeSeconds)}
+	}
+	return nil
+}
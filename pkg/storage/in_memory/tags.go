@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// filterPosts возвращает id постов, подходящих под фильтр, в порядке
+// создания. Вызывается под m.mu, возвращённый срез не изменяется
+func (m *MemoryStorage) filterPosts(f smodel.PostFilter) []uint {
+	ids := m.matchPosts(f)
+	if f.WithDeleted || len(m.removedPosts) == 0 {
+		return ids
+	}
+
+	visible := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !m.removedPosts[id] {
+			visible = append(visible, id)
+		}
+	}
+	return visible
+}
+
+// matchPosts возвращает id постов по доске и тегам фильтра, вызывается под m.mu
+func (m *MemoryStorage) matchPosts(f smodel.PostFilter) []uint {
+	all := m.postIds
+	if f.BoardId != nil {
+		all = m.boardPosts[*f.BoardId]
+	}
+
+	if len(f.Tags) == 0 {
+		return all
+	}
+
+	// посты доски, если фильтр и по доске
+	var onBoard map[uint]bool
+	if f.BoardId != nil {
+		onBoard = make(map[uint]bool, len(all))
+		for _, id := range all {
+			onBoard[id] = true
+		}
+	}
+
+	// сколько тегов из фильтра есть у поста
+	matched := make(map[uint]int)
+	for _, name := range f.Tags {
+		for _, id := range m.tagPosts[name] {
+			matched[id]++
+		}
+	}
+
+	ids := make([]uint, 0, len(matched))
+	for id, count := range matched {
+		if onBoard != nil && !onBoard[id] {
+			continue
+		}
+		if !f.AllTags || count == len(f.Tags) {
+			ids = append(ids, id)
+		}
+	}
+	// id постов выдаются по возрастанию, поэтому это порядок создания
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+func (m *MemoryStorage) GetTags(ctx context.Context, limit, offset int) ([]*smodel.TagCount, error) {
+	m.mu.RLock()
+	tags := make([]*smodel.TagCount, 0, len(m.tagPosts))
+	for name, ids := range m.tagPosts {
+		tags = append(tags, &smodel.TagCount{Name: name, PostCount: len(ids)})
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].PostCount != tags[j].PostCount {
+			return tags[i].PostCount > tags[j].PostCount
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	start, end := bounds(len(tags), limit, offset)
+	return tags[start:end], nil
+}
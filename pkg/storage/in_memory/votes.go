@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+func (m *MemoryStorage) Vote(ctx context.Context, v smodel.CastVote) (*smodel.VoteSummary, error) {
+	if v.Value < -1 || v.Value > 1 {
+		return nil, errors.New(u.ErrorVoteValue(v.Value))
+	}
+
+	m.mu.RLock()
+	_, userExist := m.users[v.UserId]
+	m.mu.RUnlock()
+
+	if !userExist {
+		return nil, errors.New(u.ErrorUserId(v.UserId))
+	}
+
+	shard, err := m.targetShard(v.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	// голос и количества голосов записи меняются под одной блокировкой
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	summary := &smodel.VoteSummary{
+		PostID:  shard.id,
+		Changed: shard.votes[v.Target][v.UserId].Value != v.Value,
+	}
+
+	if summary.Changed {
+		vote := smodel.Vote{
+			UserID:     v.UserId,
+			TargetType: v.Target.Type,
+			TargetID:   v.Target.ID,
+			Value:      v.Value,
+			CreatedAt:  time.Now(),
+		}
+
+		if err := m.log(record{Op: opVote, Vote: &vote}); err != nil {
+			return nil, err
+		}
+		shard.applyVote(vote)
+	}
+
+	summary.Votes = *shard.targetVotes(v.Target)
+	return summary, nil
+}
+
+// applyVote заменяет голос пользователя, Value 0 снимает голос.
+// Вызывается под блокировкой шарда
+func (s *postShard) applyVote(vote smodel.Vote) {
+	target := smodel.Target{Type: vote.TargetType, ID: vote.TargetID}
+
+	users, ok := s.votes[target]
+	if !ok {
+		users = make(map[uint]smodel.Vote)
+		s.votes[target] = users
+	}
+
+	old := users[vote.UserID].Value
+	if vote.Value == 0 {
+		delete(users, vote.UserID)
+	} else {
+		users[vote.UserID] = vote
+	}
+
+	if target.Type == smodel.TargetPost {
+		s.post.Votes.Change(old, vote.Value, s.post.CreatedAt)
+		return
+	}
+
+	comment := s.comments[target.ID]
+	comment.Votes.Change(old, vote.Value, comment.CreatedAt)
+	s.comments[target.ID] = comment
+}
+
+// targetVotes - голоса поста или комментария шарда, вызывается под блокировкой шарда
+func (s *postShard) targetVotes(target smodel.Target) *smodel.Votes {
+	if target.Type == smodel.TargetPost {
+		return &s.post.Votes
+	}
+
+	comment := s.comments[target.ID]
+	return &comment.Votes
+}
+
+func (m *MemoryStorage) GetUserVote(ctx context.Context, userId uint, target smodel.Target) (int, error) {
+	shard, err := m.targetShard(target)
+	if err != nil {
+		return 0, err
+	}
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	return shard.votes[target][userId].Value, nil
+}
+
+// restoreVote применяет голос при восстановлении
+func (m *MemoryStorage) restoreVote(vote smodel.Vote) error {
+	shard, err := m.targetShard(smodel.Target{Type: vote.TargetType, ID: vote.TargetID})
+	if err != nil {
+		return err
+	}
+
+	shard.applyVote(vote)
+	return nil
+}
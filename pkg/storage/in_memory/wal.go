@@ -0,0 +1,194 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// Журнал упреждающей записи (write-ahead log).
+// Каждая запись в файле: длина данных (4 байта), crc32 данных (4 байта), данные.
+// По длине и контрольной сумме при чтении находится оборванный или
+// повреждённый хвост журнала, например после падения во время записи
+
+// размер заголовка записи: длина и crc32
+const frameHeaderSize = 8
+
+// запись длиннее этого считается повреждённой
+const maxFrameSize = 64 << 20
+
+type FsyncPolicy string
+
+const (
+	// FsyncAlways - fsync после каждой записи, изменения не теряются
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncInterval - fsync раз в интервал, при сбое питания теряются изменения за интервал
+	FsyncInterval FsyncPolicy = "interval"
+	// FsyncNever - fsync выполняет ОС, при сбое питания теряется неизвестно сколько
+	FsyncNever FsyncPolicy = "never"
+)
+
+var (
+	errCorruptFrame = errors.New("corrupt wal frame")
+	errCorruptWAL   = errors.New("corrupt wal")
+)
+
+type wal struct {
+	mu     sync.Mutex
+	f      *os.File
+	policy FsyncPolicy
+	// были ли записи после последнего fsync
+	dirty bool
+}
+
+// openWAL открывает журнал для дописывания. Данные после offset
+// (повреждённый хвост) отбрасываются
+func openWAL(path string, offset int64, policy FsyncPolicy) (*wal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &wal{f: f, policy: policy}, nil
+}
+
+// append дописывает запись в журнал
+func (w *wal) append(data []byte) error {
+	frame := make([]byte, frameHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(data))
+	copy(frame[frameHeaderSize:], data)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.f.Write(frame); err != nil {
+		return err
+	}
+
+	if w.policy == FsyncAlways {
+		return w.f.Sync()
+	}
+
+	w.dirty = true
+	return nil
+}
+
+// sync сбрасывает журнал на диск, если были новые записи
+func (w *wal) sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.dirty {
+		return nil
+	}
+
+	w.dirty = false
+	return w.f.Sync()
+}
+
+// reset очищает журнал, когда все его записи попали в снимок
+func (w *wal) reset() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	w.dirty = false
+	return w.f.Sync()
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.f.Sync(); err != nil {
+		w.f.Close()
+		return err
+	}
+
+	return w.f.Close()
+}
+
+// readWAL читает все целые записи журнала и вызывает для них fn.
+// Возвращает смещение конца последней целой записи. Если оборвана или
+// повреждена последняя запись журнала, как при падении во время записи,
+// то возвращается errCorruptFrame вместе со смещением, до которого журнал
+// можно обрезать. Повреждение перед последней записью обрезкой не исправить,
+// записи после него были подтверждены, поэтому тогда возвращается errCorruptWAL
+func readWAL(path string, fn func(data []byte) error) (int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	r := bufio.NewReader(f)
+	header := make([]byte, frameHeaderSize)
+	var offset int64
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return offset, nil
+			}
+			// оборванный заголовок
+			return offset, fmt.Errorf("%w at offset %d: %v", errCorruptFrame, offset, err)
+		}
+
+		size := binary.LittleEndian.Uint32(header[0:4])
+		sum := binary.LittleEndian.Uint32(header[4:8])
+		if size > maxFrameSize {
+			return offset, fmt.Errorf("%w at offset %d: frame size %d", errCorruptWAL, offset, size)
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return offset, fmt.Errorf("%w at offset %d: %v", errCorruptFrame, offset, err)
+		}
+
+		if crc32.ChecksumIEEE(data) != sum {
+			// после записи есть другие, значит она не оборвана, а испорчена
+			end := offset + int64(frameHeaderSize) + int64(size)
+			if end < info.Size() {
+				return offset, fmt.Errorf("%w at offset %d: checksum mismatch, %d bytes follow", errCorruptWAL, offset, info.Size()-end)
+			}
+			return offset, fmt.Errorf("%w at offset %d: checksum mismatch", errCorruptFrame, offset)
+		}
+
+		if err := fn(data); err != nil {
+			return offset, err
+		}
+
+		offset += int64(frameHeaderSize) + int64(size)
+	}
+}
@@ -0,0 +1,256 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+func (m *MemoryStorage) CreateWebhook(ctx context.Context, w smodel.CreateWebhook) (*smodel.Webhook, error) {
+	events := make([]string, len(w.Events))
+	for i, event := range w.Events {
+		events[i] = string(event)
+	}
+
+	m.webhooksMu.Lock()
+	defer m.webhooksMu.Unlock()
+
+	webhook := smodel.Webhook{
+		ID:        m.webhookSeq.next(),
+		URL:       w.URL,
+		Events:    strings.Join(events, ","),
+		Secret:    w.Secret,
+		CreatedAt: time.Now(),
+	}
+	if err := m.log(record{Op: opCreateWebhook, Webhook: &webhook}); err != nil {
+		return nil, err
+	}
+	m.applyWebhook(webhook)
+
+	return &webhook, nil
+}
+
+// applyWebhook добавляет вебхук, вызывается под webhooksMu
+func (m *MemoryStorage) applyWebhook(webhook smodel.Webhook) {
+	m.webhookSeq.advance(webhook.ID)
+	m.webhooks[webhook.ID] = webhook
+	m.webhookIds = append(m.webhookIds, webhook.ID)
+}
+
+func (m *MemoryStorage) DeleteWebhook(ctx context.Context, id uint) error {
+	m.webhooksMu.Lock()
+	defer m.webhooksMu.Unlock()
+
+	if _, ok := m.webhooks[id]; !ok {
+		return errors.New(u.ErrorWebhookId(id))
+	}
+
+	if err := m.log(record{Op: opDeleteWebhook, WebhookId: id}); err != nil {
+		return err
+	}
+	m.applyDeleteWebhook(id)
+
+	return nil
+}
+
+// applyDeleteWebhook удаляет вебхук и его доставки, вызывается под webhooksMu
+func (m *MemoryStorage) applyDeleteWebhook(id uint) {
+	delete(m.webhooks, id)
+	m.webhookIds = removeId(m.webhookIds, id)
+
+	ids := m.deliveryIds[:0]
+	for _, did := range m.deliveryIds {
+		if m.deliveries[did].WebhookID == id {
+			delete(m.deliveries, did)
+			delete(m.deliveryLeases, did)
+			m.pendingDeliveries = removeId(m.pendingDeliveries, did)
+			continue
+		}
+		ids = append(ids, did)
+	}
+	m.deliveryIds = ids
+}
+
+// applyDropUserDeliveries удаляет доставки событий о записях пользователя userId,
+// вызывается под webhooksMu
+func (m *MemoryStorage) applyDropUserDeliveries(userId uint) {
+	ids := m.deliveryIds[:0]
+	for _, id := range m.deliveryIds {
+		if m.deliveries[id].UserID == userId {
+			delete(m.deliveries, id)
+			delete(m.deliveryLeases, id)
+			m.pendingDeliveries = removeId(m.pendingDeliveries, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	m.deliveryIds = ids
+}
+
+func (m *MemoryStorage) GetWebhooks(ctx context.Context) ([]*smodel.Webhook, error) {
+	m.webhooksMu.RLock()
+	defer m.webhooksMu.RUnlock()
+
+	webhooks := make([]*smodel.Webhook, 0, len(m.webhookIds))
+	for _, id := range m.webhookIds {
+		webhook := m.webhooks[id]
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, nil
+}
+
+func (m *MemoryStorage) CreateWebhookDeliveries(ctx context.Context, d smodel.CreateDeliveries) ([]*smodel.WebhookDelivery, error) {
+	// m.mu держится до конца, чтобы аккаунт не удалили между проверкой и созданием
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	m.webhooksMu.Lock()
+	defer m.webhooksMu.Unlock()
+
+	// копии записей удалённого аккаунта не сохраняются
+	if user, ok := m.users[d.UserId]; ok && user.AnonymizedAt != nil {
+		return make([]*smodel.WebhookDelivery, 0), nil
+	}
+
+	// доставки этого события уже созданы
+	if d.EventId != 0 {
+		for _, id := range m.deliveryIds {
+			if m.deliveries[id].EventID == d.EventId {
+				return make([]*smodel.WebhookDelivery, 0), nil
+			}
+		}
+	}
+
+	var created []smodel.WebhookDelivery
+	for _, id := range m.webhookIds {
+		webhook := m.webhooks[id]
+		if !webhook.Subscribed(d.Event) {
+			continue
+		}
+
+		created = append(created, smodel.WebhookDelivery{
+			ID:            m.deliverySeq.next(),
+			WebhookID:     webhook.ID,
+			Event:         d.Event,
+			Payload:       d.Payload,
+			Status:        smodel.DeliveryPending,
+			EventID:       d.EventId,
+			UserID:        d.UserId,
+			NextAttemptAt: d.At,
+			CreatedAt:     d.At,
+		})
+	}
+
+	deliveries := make([]*smodel.WebhookDelivery, 0, len(created))
+	if len(created) == 0 {
+		return deliveries, nil
+	}
+
+	if err := m.log(record{Op: opCreateDeliveries, Deliveries: created}); err != nil {
+		return nil, err
+	}
+	for i := range created {
+		m.applyDelivery(created[i])
+		deliveries = append(deliveries, &created[i])
+	}
+
+	return deliveries, nil
+}
+
+// applyDelivery добавляет или заменяет доставку, вызывается под webhooksMu
+func (m *MemoryStorage) applyDelivery(delivery smodel.WebhookDelivery) {
+	if _, ok := m.deliveries[delivery.ID]; !ok {
+		m.deliverySeq.advance(delivery.ID)
+		m.deliveryIds = insertSorted(m.deliveryIds, delivery.ID)
+	}
+	m.deliveries[delivery.ID] = delivery
+	// результат попытки записан, доставка больше не выбрана
+	delete(m.deliveryLeases, delivery.ID)
+
+	m.pendingDeliveries = removeId(m.pendingDeliveries, delivery.ID)
+	if delivery.Status == smodel.DeliveryPending {
+		m.pendingDeliveries = insertSorted(m.pendingDeliveries, delivery.ID)
+	}
+}
+
+func (m *MemoryStorage) ClaimWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]*smodel.WebhookDelivery, error) {
+	m.webhooksMu.Lock()
+	defer m.webhooksMu.Unlock()
+
+	deliveries := make([]*smodel.WebhookDelivery, 0)
+	for _, id := range m.pendingDeliveries {
+		if len(deliveries) == limit {
+			break
+		}
+		delivery := m.deliveries[id]
+		if delivery.NextAttemptAt.After(now) || m.deliveryLeases[id].After(now) {
+			continue
+		}
+		// отложенное время попытки не сохраняется: после перезапуска
+		// отправка прерванных доставок повторяется сразу
+		m.deliveryLeases[id] = until
+		delivery.NextAttemptAt = until
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, nil
+}
+
+func (m *MemoryStorage) RecordDeliveryAttempt(ctx context.Context, a smodel.DeliveryAttempt) (*smodel.WebhookDelivery, error) {
+	m.webhooksMu.Lock()
+	defer m.webhooksMu.Unlock()
+
+	delivery, ok := m.deliveries[a.DeliveryId]
+	if !ok {
+		return nil, errors.New(u.ErrorDeliveryId(a.DeliveryId))
+	}
+
+	delivery.Status = a.Status
+	delivery.Attempts++
+	delivery.NextAttemptAt = a.NextAttemptAt
+	delivery.ResponseStatus = a.ResponseStatus
+	delivery.LastError = a.Error
+	if a.Status == smodel.DeliveryDelivered {
+		at := a.At
+		delivery.DeliveredAt = &at
+	}
+
+	if err := m.log(record{Op: opDeliveryAttempt, Delivery: &delivery}); err != nil {
+		return nil, err
+	}
+	m.applyDelivery(delivery)
+
+	return &delivery, nil
+}
+
+func (m *MemoryStorage) GetWebhookDeliveries(ctx context.Context, limit, offset int, filter smodel.DeliveryFilter) (*smodel.WebhookDeliveryPage, error) {
+	m.webhooksMu.RLock()
+	defer m.webhooksMu.RUnlock()
+
+	ids := make([]uint, 0)
+	for _, id := range m.deliveryIds {
+		delivery := m.deliveries[id]
+		if filter.WebhookId != nil && delivery.WebhookID != *filter.WebhookId {
+			continue
+		}
+		if filter.Status != nil && delivery.Status != *filter.Status {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	deliveries := make([]*smodel.WebhookDelivery, 0)
+	for _, id := range pageDesc(ids, limit, offset) {
+		delivery := m.deliveries[id]
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return &smodel.WebhookDeliveryPage{
+		Deliveries: deliveries,
+		TotalCount: len(ids),
+	}, nil
+}
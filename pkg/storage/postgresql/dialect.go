@@ -0,0 +1,75 @@
+package postgresql
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/storage/sqlstore"
+	"github.com/lib/pq"
+)
+
+// коды ошибок postgres, после которых транзакцию можно повторить
+const (
+	serializationFailure pq.ErrorCode = "40001"
+	deadlockDetected     pq.ErrorCode = "40P01"
+)
+
+// класс ошибок postgres "Integrity Constraint Violation"
+const integrityViolation pq.ErrorClass = "23"
+
+// ключ advisory-блокировки выдачи мест событиям outbox
+const outboxLockKey = 7401
+
+type postgresDialect struct{}
+
+func (postgresDialect) Migrate(db *gorm.DB) error {
+	for _, c := range sqlstore.Constraints {
+		var count int
+		if err := db.Raw("SELECT count(*) FROM pg_constraint WHERE conname = ?", c.Name).Row().Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		if err := db.Exec("ALTER TABLE " + c.Table + " ADD CONSTRAINT " + c.Name + " " + c.Def).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range searchColumns {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	// в postgres NULL больше любого значения, поэтому без NULLS LAST
+	// посты без комментариев оказались бы в начале
+	return db.Exec("CREATE INDEX IF NOT EXISTS posts_last_comment_at_idx ON posts (last_comment_at DESC NULLS LAST, id DESC)").Error
+}
+
+func (postgresDialect) Constraint(err error) (string, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code.Class() != integrityViolation {
+		return "", false
+	}
+
+	return pqErr.Constraint, true
+}
+
+func (postgresDialect) Retryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+}
+
+func (postgresDialect) LockOutbox(db *gorm.DB) error {
+	return db.Exec("SELECT pg_advisory_xact_lock(?)", outboxLockKey).Error
+}
+
+func (postgresDialect) SkipLocked() string {
+	return " FOR UPDATE SKIP LOCKED"
+}
@@ -0,0 +1,63 @@
+package postgresql
+
+import (
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/storage/sqlstore"
+)
+
+// в postgres у постов и комментариев есть колонка search_vector с tsvector текста,
+// она генерируется базой и обновляется при каждой записи. Слова не приводятся
+// к основе (конфигурация simple), как и в in-memory хранилище
+var searchColumns = []string{
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', coalesce(title, '')), 'A') || setweight(to_tsvector('simple', coalesce(content, '')), 'B')
+	) STORED`,
+	`ALTER TABLE comments ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		to_tsvector('simple', coalesce(content, ''))
+	) STORED`,
+	"CREATE INDEX IF NOT EXISTS posts_search_vector_idx ON posts USING GIN (search_vector)",
+	"CREATE INDEX IF NOT EXISTS comments_search_vector_idx ON comments USING GIN (search_vector)",
+}
+
+// выделение найденных слов в ts_headline, см. sqlstore.SnippetStart
+var headlineOptions = `StartSel="` + sqlstore.SnippetStart + `", StopSel="` + sqlstore.SnippetStop + `"`
+
+func (postgresDialect) Search(db *gorm.DB, q smodel.Search) ([]sqlstore.SearchRow, int, error) {
+	var parts []string
+	var args []interface{}
+	if q.Type != smodel.SearchComments {
+		filters, filterArgs := sqlstore.SearchFilters(q, "posts")
+		parts = append(parts, `SELECT 'post' AS kind, posts.id, posts.created_at, posts.title || ' ' || posts.content AS body,
+			ts_rank(posts.search_vector, plainto_tsquery('simple', ?)) AS rank
+			FROM posts WHERE posts.search_vector @@ plainto_tsquery('simple', ?)`+filters)
+		args = append(append(args, q.Query, q.Query), filterArgs...)
+	}
+	if q.Type != smodel.SearchPosts {
+		filters, filterArgs := sqlstore.SearchFilters(q, "comments")
+		parts = append(parts, `SELECT 'comment' AS kind, comments.id, comments.created_at, comments.content AS body,
+			ts_rank(comments.search_vector, plainto_tsquery('simple', ?)) AS rank
+			FROM comments JOIN posts ON posts.id = comments.post_id
+			WHERE comments.search_vector @@ plainto_tsquery('simple', ?)`+filters)
+		args = append(append(args, q.Query, q.Query), filterArgs...)
+	}
+	hits := strings.Join(parts, " UNION ALL ")
+
+	var totalCount int
+	if err := db.Raw("SELECT COUNT(*) FROM ("+hits+") hits", args...).Row().Scan(&totalCount); err != nil {
+		return nil, 0, err
+	}
+
+	// фрагменты с подсветкой строятся только для строк страницы
+	pageArgs := append([]interface{}{q.Query, headlineOptions}, args...)
+	pageArgs = append(pageArgs, q.Limit, q.Offset)
+	rows, err := db.Raw(`SELECT kind, id, rank, ts_headline('simple', body, plainto_tsquery('simple', ?), ?) FROM (`+hits+`) hits
+		ORDER BY rank DESC, created_at DESC, id DESC LIMIT ? OFFSET ?`, pageArgs...).Rows()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return sqlstore.ScanSearchRows(rows, totalCount)
+}
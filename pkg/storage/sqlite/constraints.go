@@ -0,0 +1,166 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/storage/sqlstore"
+)
+
+// ALTER TABLE в sqlite не умеет добавлять ограничения к существующей таблице.
+// Поэтому таблица, в которой не хватает ограничений из sqlstore.Constraints,
+// пересоздаётся с ними: строки копируются в новую таблицу, старая удаляется,
+// а новая получает её имя, индексы и триггеры. Пересоздание идёт на отдельном
+// соединении с выключенной проверкой внешних ключей, после чего все строки
+// проверяются PRAGMA foreign_key_check
+
+// суффикс имени новой таблицы на время пересоздания
+const rebuildSuffix = "_rebuild"
+
+func migrateConstraints(db *gorm.DB) error {
+	ctx := context.Background()
+	conn, err := db.DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// недостающие ограничения по таблицам, таблицы в порядке sqlstore.Constraints
+	missing := make(map[string][]sqlstore.Constraint)
+	var tables []string
+	for _, c := range sqlstore.Constraints {
+		def, err := tableSQL(ctx, conn, c.Table)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(def, "CONSTRAINT "+c.Name+" ") {
+			continue
+		}
+
+		if _, ok := missing[c.Table]; !ok {
+			tables = append(tables, c.Table)
+		}
+		missing[c.Table] = append(missing[c.Table], c)
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	// PRAGMA foreign_keys внутри транзакции не действует
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range tables {
+		if err := rebuild(ctx, tx, table, missing[table]); err != nil {
+			return fmt.Errorf("add constraints to %s: %w", table, err)
+		}
+	}
+
+	if err := checkForeignKeys(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rebuild пересоздаёт таблицу table с ограничениями constraints
+func rebuild(ctx context.Context, tx *sql.Tx, table string, constraints []sqlstore.Constraint) error {
+	def, err := tableSQL(ctx, tx, table)
+	if err != nil {
+		return err
+	}
+
+	// индексы и триггеры удаляются вместе с таблицей
+	var extras []string
+	rows, err := tx.QueryContext(ctx, "SELECT sql FROM sqlite_master WHERE type IN ('index', 'trigger') AND tbl_name = ? AND sql IS NOT NULL", table)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			rows.Close()
+			return err
+		}
+		extras = append(extras, stmt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// последнее выданное значение AUTOINCREMENT, чтобы id не выдавались повторно
+	var seq sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT seq FROM sqlite_sequence WHERE name = ?", table).Scan(&seq)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	// колонки и ограничения таблицы - всё между первой и последней скобкой
+	body := def[strings.Index(def, "(")+1 : strings.LastIndex(def, ")")]
+	for _, c := range constraints {
+		body += ", CONSTRAINT " + c.Name + " " + c.Def
+	}
+
+	stmts := []string{
+		`CREATE TABLE "` + table + rebuildSuffix + `" (` + body + `)`,
+		`INSERT INTO "` + table + rebuildSuffix + `" SELECT * FROM "` + table + `"`,
+		`DROP TABLE "` + table + `"`,
+		`ALTER TABLE "` + table + rebuildSuffix + `" RENAME TO "` + table + `"`,
+	}
+	stmts = append(stmts, extras...)
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	if seq.Valid {
+		_, err := tx.ExecContext(ctx, "UPDATE sqlite_sequence SET seq = MAX(seq, ?) WHERE name = ?", seq.Int64, table)
+		return err
+	}
+	return nil
+}
+
+// checkForeignKeys возвращает ошибку, если есть строки с нарушением внешнего ключа
+func checkForeignKeys(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return err
+		}
+		return fmt.Errorf("row %d of %s references missing row of %s, fix the data and restart", rowid.Int64, table, parent)
+	}
+
+	return rows.Err()
+}
+
+// общие методы *sql.Conn и *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func tableSQL(ctx context.Context, q querier, table string) (string, error) {
+	var def string
+	err := q.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&def)
+	return def, err
+}
@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/storage/sqlstore"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Поиск в sqlite идёт по таблицам FTS5 posts_fts и comments_fts. Они хранят
+// только индекс, а текст берут из posts и comments (external content),
+// и обновляются триггерами при каждой записи
+
+var ftsTables = []struct {
+	table   string
+	columns []string
+}{
+	{"posts", []string{"title", "content"}},
+	{"comments", []string{"content"}},
+}
+
+func migrateSearch(db *gorm.DB) error {
+	for _, t := range ftsTables {
+		fts := t.table + "_fts"
+		cols := strings.Join(t.columns, ", ")
+		newCols := "new." + strings.Join(t.columns, ", new.")
+		oldCols := "old." + strings.Join(t.columns, ", old.")
+
+		var exists int
+		if err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE name = ?", fts).Row().Scan(&exists); err != nil {
+			return err
+		}
+
+		stmts := []string{
+			"CREATE VIRTUAL TABLE IF NOT EXISTS " + fts + " USING fts5(" + cols +
+				", content='" + t.table + "', content_rowid='id', tokenize='unicode61')",
+			"CREATE TRIGGER IF NOT EXISTS " + fts + "_insert AFTER INSERT ON " + t.table + " BEGIN " +
+				"INSERT INTO " + fts + " (rowid, " + cols + ") VALUES (new.id, " + newCols + "); END",
+			"CREATE TRIGGER IF NOT EXISTS " + fts + "_update AFTER UPDATE OF " + cols + " ON " + t.table + " BEGIN " +
+				"INSERT INTO " + fts + " (" + fts + ", rowid, " + cols + ") VALUES ('delete', old.id, " + oldCols + "); " +
+				"INSERT INTO " + fts + " (rowid, " + cols + ") VALUES (new.id, " + newCols + "); END",
+			"CREATE TRIGGER IF NOT EXISTS " + fts + "_delete AFTER DELETE ON " + t.table + " BEGIN " +
+				"INSERT INTO " + fts + " (" + fts + ", rowid, " + cols + ") VALUES ('delete', old.id, " + oldCols + "); END",
+		}
+		// индекс по записям, созданным до появления поиска
+		if exists == 0 {
+			stmts = append(stmts, "INSERT INTO "+fts+" ("+fts+") VALUES ('rebuild')")
+		}
+
+		for _, stmt := range stmts {
+			if err := db.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (dialect) Search(db *gorm.DB, q smodel.Search) ([]sqlstore.SearchRow, int, error) {
+	// слова запроса берутся в кавычки, чтобы символы синтаксиса FTS5
+	// в тексте пользователя не ломали запрос. Слова через пробел - все должны быть
+	tokens := u.Tokenize(q.Query)
+	if len(tokens) == 0 {
+		return nil, 0, nil
+	}
+	for i, token := range tokens {
+		tokens[i] = `"` + token + `"`
+	}
+	match := strings.Join(tokens, " ")
+
+	// bm25 тем меньше, чем лучше совпадение, поэтому ранг - bm25 со знаком минус.
+	// Совпадение в заголовке поста весит вдвое больше
+	var parts []string
+	var args []interface{}
+	if q.Type != smodel.SearchComments {
+		filters, filterArgs := sqlstore.SearchFilters(q, "posts")
+		parts = append(parts, `SELECT 'post' AS kind, posts.id AS id, posts.created_at AS created_at,
+			-bm25(posts_fts, 2.0, 1.0) AS rank, snippet(posts_fts, -1, ?, ?, '…', 16) AS snippet
+			FROM posts_fts JOIN posts ON posts.id = posts_fts.rowid WHERE posts_fts MATCH ?`+filters)
+		args = append(append(args, sqlstore.SnippetStart, sqlstore.SnippetStop, match), filterArgs...)
+	}
+	if q.Type != smodel.SearchPosts {
+		filters, filterArgs := sqlstore.SearchFilters(q, "comments")
+		parts = append(parts, `SELECT 'comment' AS kind, comments.id AS id, comments.created_at AS created_at,
+			-bm25(comments_fts) AS rank, snippet(comments_fts, -1, ?, ?, '…', 16) AS snippet
+			FROM comments_fts JOIN comments ON comments.id = comments_fts.rowid
+			JOIN posts ON posts.id = comments.post_id WHERE comments_fts MATCH ?`+filters)
+		args = append(append(args, sqlstore.SnippetStart, sqlstore.SnippetStop, match), filterArgs...)
+	}
+	hits := strings.Join(parts, " UNION ALL ")
+
+	var totalCount int
+	if err := db.Raw("SELECT COUNT(*) FROM ("+hits+")", args...).Row().Scan(&totalCount); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Raw(`SELECT kind, id, rank, snippet FROM (`+hits+`)
+		ORDER BY rank DESC, created_at DESC, id DESC LIMIT ? OFFSET ?`, append(args, q.Limit, q.Offset)...).Rows()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return sqlstore.ScanSearchRows(rows, totalCount)
+}
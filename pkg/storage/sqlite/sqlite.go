@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/storage/sqlstore"
+	"modernc.org/sqlite"
+)
+
+// Хранилище во встроенной базе SQLite в одном файле.
+// Запросы те же, что у postgres (см. sqlstore), отличия описывает диалект ниже.
+// Используется драйвер modernc.org/sqlite без cgo, поэтому сборка
+// с CGO_ENABLED=0 продолжает работать
+type SQLiteStorage struct {
+	*sqlstore.Storage
+}
+
+// параметры соединения:
+// busy_timeout - ожидание блокировки другой записи вместо ошибки SQLITE_BUSY,
+// journal_mode(WAL) - чтение не блокируется записью,
+// foreign_keys(1) - проверка внешних ключей, по умолчанию она выключена,
+// _txlock=immediate - транзакция сразу берёт блокировку на запись,
+// поэтому записывающие транзакции выполняются строго по очереди
+const params = "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate&_time_format=sqlite"
+
+// коды ошибок sqlite, после которых транзакцию можно повторить
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+func NewSQLiteStore(path string) (*SQLiteStorage, error) {
+	sqlDB, err := sql.Open("sqlite", path+params)
+	if err != nil {
+		return nil, err
+	}
+
+	// диалект sqlite3 в gorm не привязан к драйверу, ему передаётся готовое соединение
+	db, err := gorm.Open("sqlite3", sqlDB)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := sqlstore.NewStore(db, dialect{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteStorage{Storage: store}, nil
+}
+
+type dialect struct{}
+
+// Ограничения те же, что в postgres, см. constraints.go.
+// В sqlite NULL меньше любого значения и при DESC и так оказывается в конце,
+// а NULLS LAST в индексе не поддерживается
+func (dialect) Migrate(db *gorm.DB) error {
+	if err := migrateConstraints(db); err != nil {
+		return err
+	}
+	if err := migrateSearch(db); err != nil {
+		return err
+	}
+
+	return db.Exec("CREATE INDEX IF NOT EXISTS posts_last_comment_at_idx ON posts (last_comment_at DESC, id DESC)").Error
+}
+
+// sqlite не сообщает имя нарушенного ограничения
+func (dialect) Constraint(err error) (string, bool) {
+	return "", false
+}
+
+// записывающие транзакции в sqlite и так выполняются по очереди
+func (dialect) LockOutbox(db *gorm.DB) error {
+	return nil
+}
+
+// запись в sqlite блокирует всю базу, поэтому выбранные строки
+// другая транзакция не увидит до фиксации
+func (dialect) SkipLocked() string {
+	return ""
+}
+
+func (dialect) Retryable(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	// младший байт - основной код ошибки без уточнения
+	code := sqliteErr.Code() & 0xff
+	return code == sqliteBusy || code == sqliteLocked
+}
@@ -0,0 +1,100 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Удалённый аккаунт остаётся строкой в users с анонимным username, поэтому
+// авторы постов и комментариев загружаются как раньше. Записи пользователя
+// при удалении с очисткой помечаются удалёнными и сразу очищаются. События
+// outbox и доставки вебхуков хранят копии записей вместе с username автора,
+// поэтому они удаляются, а новые доставки для удалённого аккаунта не создаются
+
+func (s *Storage) AnonymizeUser(ctx context.Context, a smodel.AnonymizeUser) (*smodel.User, error) {
+	var user smodel.User
+
+	err := s.inTx(ctx, sql.LevelSerializable, func(tx *Storage) error {
+		db := tx.withContext(ctx)
+
+		user = smodel.User{}
+		if err := db.First(&user, a.UserId).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return errors.New(u.ErrorUserId(a.UserId))
+			}
+			return err
+		}
+		if user.AnonymizedAt != nil {
+			return errors.New(u.ErrorUserDeleted(a.UserId))
+		}
+
+		now := time.Now()
+		user.Username, user.AnonymizedAt = smodel.AnonymousUsername(user.ID), &now
+		err := db.Model(&smodel.User{}).Where("id = ?", user.ID).UpdateColumns(map[string]interface{}{
+			"username":      user.Username,
+			"anonymized_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := db.Where("user_id = ?", user.ID).Delete(&smodel.OutboxEvent{}).Error; err != nil {
+			return err
+		}
+		if err := db.Where("user_id = ?", user.ID).Delete(&smodel.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+
+		if !a.RemoveContent {
+			return nil
+		}
+
+		for _, typ := range []smodel.TargetType{smodel.TargetPost, smodel.TargetComment} {
+			table := targetTables[typ]
+
+			err := db.Table(table).Where("user_id = ? AND removed_at IS NULL", user.ID).UpdateColumn("removed_at", now).Error
+			if err != nil {
+				return err
+			}
+
+			var ids []uint
+			if err := db.Table(table).Where("user_id = ? AND purged_at IS NULL", user.ID).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			if err := purgeTargets(db, typ, ids, now); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// checkActiveUser проверяет, что пользователь существует и его аккаунт не удалён
+func (s *Storage) checkActiveUser(ctx context.Context, userID uint) error {
+	var user smodel.User
+	if err := s.withContext(ctx).Select("id, anonymized_at").First(&user, userID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New(u.ErrorUserId(userID))
+		}
+		return err
+	}
+	if user.AnonymizedAt != nil {
+		return errors.New(u.ErrorUserDeleted(userID))
+	}
+	return nil
+}
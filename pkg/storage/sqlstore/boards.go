@@ -0,0 +1,144 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Доски хранятся в таблице boards, пользователи, которые могут
+// создавать посты на доске, - в board_posters (many-to-many)
+
+func (s *Storage) CreateBoard(ctx context.Context, b smodel.CreateBoard) (*smodel.Board, error) {
+	var board smodel.Board
+
+	err := s.inTx(ctx, sql.LevelDefault, func(tx *Storage) error {
+		for _, id := range b.AllowedPosters {
+			if err := tx.checkUserExists(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		// пользователи привязываются ниже, поэтому при вставке их нет
+		// и gorm не пытается сохранить их сам
+		board = smodel.Board{
+			Name:                     b.Name,
+			Description:              b.Description,
+			CommentsEnabledByDefault: b.CommentsEnabledByDefault,
+			MaxCommentLength:         b.MaxCommentLength,
+		}
+
+		db := tx.withContext(ctx)
+		if err := db.Create(&board).Error; err != nil {
+			return err
+		}
+
+		for _, id := range b.AllowedPosters {
+			if err := db.Exec("INSERT INTO board_posters (board_id, user_id) VALUES (?, ?)", board.ID, id).Error; err != nil {
+				return err
+			}
+		}
+
+		board.AllowedPosters = []smodel.User{}
+		if len(b.AllowedPosters) == 0 {
+			return nil
+		}
+		return db.Where("id IN (?)", b.AllowedPosters).Order("id").Find(&board.AllowedPosters).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &board, nil
+}
+
+func (s *Storage) GetBoard(ctx context.Context, id uint) (*smodel.Board, error) {
+	var board smodel.Board
+	if err := preloadPosters(s.withContext(ctx)).First(&board, id).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New(u.ErrorBoardId(id))
+		}
+		return nil, err
+	}
+
+	return &board, nil
+}
+
+func (s *Storage) GetBoards(ctx context.Context, limit, offset int) (*smodel.BoardPage, error) {
+	var boards []*smodel.Board
+	var totalCount int
+	db := s.withContext(ctx)
+
+	if err := db.Model(&smodel.Board{}).Count(&totalCount).Error; err != nil {
+		return nil, err
+	}
+
+	if err := preloadPosters(db).Order("id").Limit(limit).Offset(offset).Find(&boards).Error; err != nil {
+		return nil, err
+	}
+
+	return &smodel.BoardPage{
+		Boards:     boards,
+		TotalCount: totalCount,
+	}, nil
+}
+
+// preloadPosters загружает пользователей, которые могут создавать посты на доске
+func preloadPosters(db *gorm.DB) *gorm.DB {
+	return db.Preload("AllowedPosters", func(db *gorm.DB) *gorm.DB {
+		return db.Order("users.id")
+	})
+}
+
+// checkPoster проверяет, что доска существует и пользователь может создавать на ней посты
+func (s *Storage) checkPoster(ctx context.Context, boardId, userId uint) error {
+	db := s.withContext(ctx)
+
+	var board smodel.Board
+	if err := db.First(&board, boardId).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New(u.ErrorBoardId(boardId))
+		}
+		return err
+	}
+
+	var posters, allowed int
+	if err := db.Table("board_posters").Where("board_id = ?", boardId).Count(&posters).Error; err != nil {
+		return err
+	}
+	// ограничений нет
+	if posters == 0 {
+		return nil
+	}
+
+	if err := db.Table("board_posters").Where("board_id = ? AND user_id = ?", boardId, userId).Count(&allowed).Error; err != nil {
+		return err
+	}
+	if allowed == 0 {
+		return errors.New(u.ErrorPosterNotAllowed(userId, boardId))
+	}
+
+	return nil
+}
+
+// checkCommentLength проверяет длину комментария по настройкам доски поста
+func (s *Storage) checkCommentLength(ctx context.Context, post smodel.Post, content string) error {
+	if post.BoardID == nil {
+		return nil
+	}
+
+	var board smodel.Board
+	if err := s.withContext(ctx).First(&board, *post.BoardID).Error; err != nil {
+		return err
+	}
+
+	if length := len([]rune(content)); board.MaxCommentLength > 0 && length > board.MaxCommentLength {
+		return errors.New(u.ErrorCommentTooLong(length, board.MaxCommentLength))
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+)
+
+// jinzhu/gorm не передаёт context.Context в database/sql. Поэтому запросы
+// идут через обёртку над соединением: gorm вызывает Exec и Query,
+// а обёртка выполняет ExecContext и QueryContext с контекстом операции
+
+// общие методы *sql.DB и *sql.Tx
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type ctxConn struct {
+	ctx  context.Context
+	conn conn
+}
+
+func (c ctxConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.conn.ExecContext(c.ctx, query, args...)
+}
+
+func (c ctxConn) Prepare(query string) (*sql.Stmt, error) {
+	return c.conn.PrepareContext(c.ctx, query)
+}
+
+func (c ctxConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.conn.QueryContext(c.ctx, query, args...)
+}
+
+func (c ctxConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.conn.QueryRowContext(c.ctx, query, args...)
+}
+
+// withContext возвращает gorm.DB, запросы которого выполняются с контекстом ctx.
+// Внутри единицы работы (см. inTx) запросы идут в её транзакции
+func (s *Storage) withContext(ctx context.Context) *gorm.DB {
+	var c conn = s.DB.DB()
+	if s.tx != nil {
+		c = s.tx
+	}
+
+	// gorm.Open с готовым соединением только создаёт обёртку, не подключаясь к бд
+	db, _ := gorm.Open(s.DB.Dialect().GetName(), ctxConn{ctx: ctx, conn: c})
+	return db
+}
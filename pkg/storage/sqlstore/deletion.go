@@ -0,0 +1,156 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Удалённая запись помечается временем удаления в removed_at и остаётся
+// в таблице. Пометка ставится и снимается условным UPDATE, поэтому
+// одновременные удаление и восстановление не требуют транзакции. При очистке
+// текст записи стирается, а время очистки пишется в purged_at
+
+// removal - автор записи и её состояние удаления
+type removal struct {
+	UserID    uint
+	RemovedAt *time.Time
+	PurgedAt  *time.Time
+}
+
+func (s *Storage) removal(ctx context.Context, target smodel.Target) (*removal, error) {
+	var row removal
+	err := s.withContext(ctx).Table(targetTables[target.Type]).Select("user_id, removed_at, purged_at").
+		Where("id = ?", target.ID).Scan(&row).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			if target.Type == smodel.TargetPost {
+				return nil, errors.New(u.ErrorPostId(target.ID))
+			}
+			return nil, errors.New(u.ErrorCommId(target.ID))
+		}
+		return nil, err
+	}
+
+	return &row, nil
+}
+
+func (s *Storage) SoftDelete(ctx context.Context, d smodel.SoftDelete) error {
+	row, err := s.removal(ctx, d.Target)
+	if err != nil {
+		return err
+	}
+	if !d.Moderator && row.UserID != d.UserId {
+		return errors.New(u.ErrorNotAuthor(d.UserId, d.Target))
+	}
+
+	res := s.withContext(ctx).Table(targetTables[d.Target.Type]).
+		Where("id = ? AND removed_at IS NULL", d.Target.ID).
+		UpdateColumn("removed_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	// запись уже удалена
+	if res.RowsAffected == 0 {
+		return errors.New(u.ErrorRemoved(d.Target))
+	}
+
+	return nil
+}
+
+func (s *Storage) Restore(ctx context.Context, r smodel.Restore) error {
+	res := s.withContext(ctx).Table(targetTables[r.Target.Type]).
+		Where("id = ? AND removed_at > ? AND purged_at IS NULL", r.Target.ID, r.DeletedAfter).
+		UpdateColumn("removed_at", gorm.Expr("NULL"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+
+	// запись не восстановлена, причина определяется по её состоянию
+	row, err := s.removal(ctx, r.Target)
+	if err != nil {
+		return err
+	}
+	if row.RemovedAt == nil {
+		return errors.New(u.ErrorNotRemoved(r.Target))
+	}
+	return errors.New(u.ErrorRestoreExpired(r.Target))
+}
+
+// сколько записей очищается в одной транзакции
+const purgeBatchSize = 500
+
+// PurgeDeleted очищает записи пачками по purgeBatchSize, каждую в своей
+// транзакции, поэтому блокировки держатся недолго. Выбранные строки
+// блокируются до конца транзакции: восстановление ждёт очистки и после неё
+// запись не восстанавливает, а строки, которые очищает другой экземпляр,
+// пропускаются
+func (s *Storage) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	purged := 0
+
+	for _, typ := range []smodel.TargetType{smodel.TargetPost, smodel.TargetComment} {
+		for {
+			n, err := s.purgeBatch(ctx, typ, before)
+			if err != nil {
+				return purged, err
+			}
+			purged += n
+			if n < purgeBatchSize {
+				break
+			}
+		}
+	}
+
+	return purged, nil
+}
+
+// purgeBatch очищает не больше purgeBatchSize записей типа typ, удалённых раньше before
+func (s *Storage) purgeBatch(ctx context.Context, typ smodel.TargetType, before time.Time) (int, error) {
+	var ids []uint
+
+	err := s.inTx(ctx, sql.LevelDefault, func(tx *Storage) error {
+		db := tx.withContext(ctx)
+		ids = ids[:0]
+
+		var rows []struct{ ID uint }
+		err := db.Raw("SELECT id FROM "+targetTables[typ]+" WHERE removed_at < ? AND purged_at IS NULL ORDER BY id LIMIT ?"+tx.dialect.SkipLocked(),
+			before, purgeBatchSize).Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		return purgeTargets(db, typ, ids, time.Now())
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// purgeTargets стирает текст и версии записей типа typ с id из ids
+func purgeTargets(db *gorm.DB, typ smodel.TargetType, ids []uint, now time.Time) error {
+	updates := map[string]interface{}{"content": "", "purged_at": now}
+	if typ == smodel.TargetPost {
+		updates["title"] = ""
+	}
+	if err := db.Table(targetTables[typ]).Where("id IN (?)", ids).UpdateColumns(updates).Error; err != nil {
+		return err
+	}
+
+	return db.Where("target_type = ? AND target_id IN (?)", typ, ids).Delete(&smodel.Revision{}).Error
+}
@@ -0,0 +1,63 @@
+package sqlstore
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// Dialect описывает, чем отличается база данных, с которой хранилище
+// работает через gorm. Запросы хранилища от базы не зависят, а ограничения,
+// коды ошибок и повтор транзакций у каждой базы свои
+type Dialect interface {
+	// Migrate создаёт то, что не создаёт AutoMigrate и что зависит от базы
+	Migrate(db *gorm.DB) error
+	// Constraint возвращает имя ограничения, если err - его нарушение
+	Constraint(err error) (string, bool)
+	// Retryable сообщает, что транзакцию можно повторить после ошибки err
+	Retryable(err error) bool
+	// Search ищет посты и комментарии по тексту и возвращает страницу
+	// найденных записей, сначала наиболее подходящие, и их общее количество
+	Search(db *gorm.DB, q smodel.Search) ([]SearchRow, int, error)
+	// LockOutbox ждёт, пока завершатся другие транзакции, выдающие места
+	// событиям outbox. Блокировка держится до конца транзакции db, поэтому
+	// места выдаются в порядке фиксации. Транзакции изменений её не берут
+	LockOutbox(db *gorm.DB) error
+	// SkipLocked возвращает окончание SELECT, с которым строки, заблокированные
+	// другими транзакциями, пропускаются. Пустая строка, если база блокирует
+	// не строки, а всю базу
+	SkipLocked() string
+}
+
+// Constraint - ограничение Name таблицы Table с определением Def
+type Constraint struct {
+	Name  string
+	Table string
+	Def   string
+}
+
+// Constraints - ограничения таблиц, диалект создаёт их, если они ещё не существуют
+var Constraints = []Constraint{
+	{"posts_user_id_fkey", "posts", "FOREIGN KEY (user_id) REFERENCES users (id)"},
+	{"comments_user_id_fkey", "comments", "FOREIGN KEY (user_id) REFERENCES users (id)"},
+	{"comments_post_id_fkey", "comments", "FOREIGN KEY (post_id) REFERENCES posts (id)"},
+	{"posts_board_id_fkey", "posts", "FOREIGN KEY (board_id) REFERENCES boards (id)"},
+	{"board_posters_board_id_fkey", "board_posters", "FOREIGN KEY (board_id) REFERENCES boards (id)"},
+	{"board_posters_user_id_fkey", "board_posters", "FOREIGN KEY (user_id) REFERENCES users (id)"},
+	{"post_tags_post_id_fkey", "post_tags", "FOREIGN KEY (post_id) REFERENCES posts (id)"},
+	{"post_tags_tag_id_fkey", "post_tags", "FOREIGN KEY (tag_id) REFERENCES tags (id)"},
+	// цель реакции - пост или комментарий, она проверяется хранилищем
+	{"reactions_user_id_fkey", "reactions", "FOREIGN KEY (user_id) REFERENCES users (id)"},
+	{"votes_user_id_fkey", "votes", "FOREIGN KEY (user_id) REFERENCES users (id)"},
+	{"reports_reporter_id_fkey", "reports", "FOREIGN KEY (reporter_id) REFERENCES users (id)"},
+	{"reports_moderator_id_fkey", "reports", "FOREIGN KEY (moderator_id) REFERENCES users (id)"},
+	{"idempotency_keys_user_id_fkey", "idempotency_keys", "FOREIGN KEY (user_id) REFERENCES users (id)"},
+	{"notifications_user_id_fkey", "notifications", "FOREIGN KEY (user_id) REFERENCES users (id)"},
+	{"notifications_actor_id_fkey", "notifications", "FOREIGN KEY (actor_id) REFERENCES users (id)"},
+	{"notifications_comment_id_fkey", "notifications", "FOREIGN KEY (comment_id) REFERENCES comments (id)"},
+	{"webhook_deliveries_webhook_id_fkey", "webhook_deliveries", "FOREIGN KEY (webhook_id) REFERENCES webhooks (id)"},
+	// нужен для внешнего ключа родителя ниже
+	{"comments_id_post_id_key", "comments", "UNIQUE (id, post_id)"},
+	// родительский комментарий должен существовать и относиться к тому же посту.
+	// при parent_id IS NULL ограничение не проверяется
+	{"comments_parent_fkey", "comments", "FOREIGN KEY (parent_id, post_id) REFERENCES comments (id, post_id)"},
+}
@@ -0,0 +1,17 @@
+package sqlstore
+
+// constraintError переводит нарушение ограничения бд в доменную ошибку.
+// errs сопоставляет имя ограничения с функцией, которая строит ошибку.
+// Если ограничение не из списка, то ошибка возвращается как есть
+func (s *Storage) constraintError(err error, errs map[string]func() error) error {
+	name, ok := s.dialect.Constraint(err)
+	if !ok {
+		return err
+	}
+
+	if toDomain, ok := errs[name]; ok {
+		return toDomain()
+	}
+
+	return err
+}
@@ -0,0 +1,48 @@
+package sqlstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Ключи идемпотентности хранятся в таблице idempotency_keys. Ключ занимается
+// в транзакции создания записи до самого создания: параллельный запрос с тем же
+// ключом ждёт конца этой транзакции и затем возвращает созданную запись
+
+// claimKey занимает ключ пользователя для записи типа typ. Если ключ уже занят,
+// то возвращает id созданной по нему записи, а если данные запроса другие - ошибку.
+// Истёкшие ключи пользователя удаляются
+func (s *Storage) claimKey(ctx context.Context, userId uint, key smodel.Idempotency, typ smodel.TargetType, now time.Time) (uint, error) {
+	db := s.withContext(ctx)
+
+	if err := db.Exec("DELETE FROM idempotency_keys WHERE user_id = ? AND expires_at <= ?", userId, now).Error; err != nil {
+		return 0, err
+	}
+
+	res := db.Exec(`INSERT INTO idempotency_keys (user_id, key, hash, target_type, target_id, expires_at)
+		VALUES (?, ?, ?, ?, 0, ?) ON CONFLICT DO NOTHING`,
+		userId, key.Key, key.Hash, typ, now.Add(key.TTL))
+	if res.Error != nil || res.RowsAffected > 0 {
+		return 0, res.Error
+	}
+
+	var saved smodel.IdempotencyKey
+	if err := db.Where("user_id = ? AND key = ?", userId, key.Key).First(&saved).Error; err != nil {
+		return 0, err
+	}
+	if saved.Hash != key.Hash || saved.TargetType != typ {
+		return 0, errors.New(u.ErrorIdempotencyKeyReused(key.Key))
+	}
+
+	return saved.TargetID, nil
+}
+
+// setKeyTarget запоминает запись, созданную по занятому ключу
+func (s *Storage) setKeyTarget(ctx context.Context, userId uint, key smodel.Idempotency, id uint) error {
+	return s.withContext(ctx).Exec("UPDATE idempotency_keys SET target_id = ? WHERE user_id = ? AND key = ?",
+		id, userId, key.Key).Error
+}
@@ -0,0 +1,215 @@
+package sqlstore
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/globalid"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/ranking"
+)
+
+// AutoMigrate из jinzhu/gorm создаёт только таблицы и колонки,
+// индексы создаются здесь, а ограничения - в Migrate диалекта
+
+// created_at добавлен в существующие таблицы позже,
+// у старых записей он заполняется временем миграции
+var backfills = []string{
+	"UPDATE posts SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL",
+	"UPDATE comments SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL",
+}
+
+// счётчики комментариев добавлены в существующую таблицу постов позже,
+// при добавлении колонок они заполняются по уже созданным комментариям
+const countersBackfill = `UPDATE posts SET
+	comment_count = (SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id),
+	top_level_comment_count = (SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id AND comments.parent_id IS NULL),
+	last_comment_at = (SELECT MAX(created_at) FROM comments WHERE comments.post_id = posts.id)`
+
+// индексы для внешних ключей и частых выборок
+var indexes = []string{
+	// посты и комментарии пользователя, сначала новые
+	"CREATE INDEX IF NOT EXISTS posts_user_id_created_at_idx ON posts (user_id, created_at DESC, id DESC)",
+	"CREATE INDEX IF NOT EXISTS comments_user_id_created_at_idx ON comments (user_id, created_at DESC, id DESC)",
+	// покрываются индексами выше
+	"DROP INDEX IF EXISTS posts_user_id_idx",
+	"DROP INDEX IF EXISTS comments_user_id_idx",
+	"CREATE INDEX IF NOT EXISTS users_username_idx ON users (username)",
+	"CREATE INDEX IF NOT EXISTS posts_board_id_idx ON posts (board_id, id)",
+	// первичный ключ post_tags (post_id, tag_id), посты по тегу ищутся по этому
+	"CREATE INDEX IF NOT EXISTS post_tags_tag_id_idx ON post_tags (tag_id, post_id)",
+	// сортировки getPosts, индекс по last_comment_at зависит от базы
+	"CREATE INDEX IF NOT EXISTS posts_comment_count_idx ON posts (comment_count DESC, id DESC)",
+	"CREATE INDEX IF NOT EXISTS posts_top_level_comment_count_idx ON posts (top_level_comment_count DESC, id DESC)",
+	"CREATE INDEX IF NOT EXISTS posts_top_rank_idx ON posts (top_rank DESC, id DESC)",
+	"CREATE INDEX IF NOT EXISTS posts_hot_rank_idx ON posts (hot_rank DESC, id DESC)",
+	"CREATE INDEX IF NOT EXISTS comments_post_id_parent_id_idx ON comments (post_id, parent_id)",
+	"CREATE INDEX IF NOT EXISTS comments_parent_id_idx ON comments (parent_id)",
+	// очередь модерации и открытые жалобы на запись
+	"CREATE INDEX IF NOT EXISTS reports_status_idx ON reports (status, id)",
+	"CREATE INDEX IF NOT EXISTS reports_target_idx ON reports (target_type, target_id, status)",
+	// уведомления пользователя, сначала новые
+	"CREATE INDEX IF NOT EXISTS notifications_user_id_idx ON notifications (user_id, id DESC)",
+	// доставки, которые пора отправить, и доставки вебхука
+	"CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at)",
+	"CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id DESC)",
+	// версии записи, номер версии уникален
+	"CREATE UNIQUE INDEX IF NOT EXISTS revisions_target_number_key ON revisions (target_type, target_id, number)",
+	// удалённые записи, которые пора очистить
+	"CREATE INDEX IF NOT EXISTS posts_removed_at_idx ON posts (removed_at) WHERE removed_at IS NOT NULL",
+	"CREATE INDEX IF NOT EXISTS comments_removed_at_idx ON comments (removed_at) WHERE removed_at IS NOT NULL",
+	// доставки события outbox создаются один раз на вебхук
+	"CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_id_key ON webhook_deliveries (event_id, webhook_id) WHERE event_id <> 0",
+}
+
+func migrate(db *gorm.DB, d Dialect) error {
+	fillCounters := db.HasTable(&smodel.Post{}) && !db.Dialect().HasColumn("posts", "comment_count")
+	fillRanks := db.HasTable(&smodel.Post{}) && !db.Dialect().HasColumn("posts", "hot_rank")
+	fillPositions := db.HasTable(&smodel.OutboxEvent{}) && !db.Dialect().HasColumn("outbox_events", "position")
+	fillEventAuthors := db.HasTable(&smodel.OutboxEvent{}) && !db.Dialect().HasColumn("outbox_events", "user_id")
+	fillDeliveryAuthors := db.HasTable(&smodel.WebhookDelivery{}) && !db.Dialect().HasColumn("webhook_deliveries", "user_id")
+
+	if err := db.AutoMigrate(&smodel.User{}, &smodel.Board{}, &smodel.Tag{}, &smodel.Post{}, &smodel.Comment{}, &smodel.Reaction{}, &smodel.ReactionCount{}, &smodel.Vote{}, &smodel.Report{}, &smodel.IdempotencyKey{}, &smodel.Notification{}, &smodel.Webhook{}, &smodel.WebhookDelivery{}, &smodel.OutboxEvent{}, &smodel.OutboxOffset{}, &smodel.Revision{}).Error; err != nil {
+		return err
+	}
+
+	if err := d.Migrate(db); err != nil {
+		return err
+	}
+
+	for _, backfill := range backfills {
+		if err := db.Exec(backfill).Error; err != nil {
+			return err
+		}
+	}
+
+	if fillCounters {
+		if err := db.Exec(countersBackfill).Error; err != nil {
+			return err
+		}
+	}
+
+	if fillRanks {
+		if err := backfillRanks(db); err != nil {
+			return err
+		}
+	}
+
+	// раньше события читались по id, поэтому места старых событий
+	// совпадают с id и сохранённые смещения потребителей не меняются
+	if fillPositions {
+		if err := db.Exec("UPDATE outbox_events SET position = id").Error; err != nil {
+			return err
+		}
+	}
+
+	if fillEventAuthors {
+		if err := backfillEventAuthors(db); err != nil {
+			return err
+		}
+	}
+	if fillDeliveryAuthors {
+		if err := backfillDeliveryAuthors(db); err != nil {
+			return err
+		}
+	}
+
+	for _, index := range indexes {
+		if err := db.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// голоса добавлены в существующие таблицы позже. Голосов у старых записей нет,
+// а ранг hot без голосов зависит от времени создания и считается в приложении
+func backfillRanks(db *gorm.DB) error {
+	for _, table := range []string{"posts", "comments"} {
+		if err := db.Exec("UPDATE " + table + " SET upvotes = 0, downvotes = 0, top_rank = 0, hot_rank = 0").Error; err != nil {
+			return err
+		}
+
+		var rows []struct {
+			ID        uint
+			CreatedAt time.Time
+		}
+		if err := db.Table(table).Select("id, created_at").Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := db.Exec("UPDATE "+table+" SET hot_rank = ? WHERE id = ?", ranking.Hot(0, 0, row.CreatedAt), row.ID).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// авторы событий outbox добавлены позже, у старых событий автор
+// берётся из сохранённой записи
+func backfillEventAuthors(db *gorm.DB) error {
+	var events []smodel.OutboxEvent
+	if err := db.Select("id, type, payload").Find(&events).Error; err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		var userId uint
+		switch event.Type {
+		case smodel.EventPostCreated:
+			var post smodel.Post
+			if err := json.Unmarshal([]byte(event.Payload), &post); err != nil {
+				return err
+			}
+			userId = post.UserID
+		case smodel.EventCommentCreated:
+			var data smodel.CommentCreated
+			if err := json.Unmarshal([]byte(event.Payload), &data); err != nil {
+				return err
+			}
+			userId = data.Comment.UserID
+		}
+
+		if err := db.Exec("UPDATE outbox_events SET user_id = ? WHERE id = ?", userId, event.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// авторы доставок вебхуков добавлены позже, у старых доставок автор
+// берётся из глобального id в теле запроса
+func backfillDeliveryAuthors(db *gorm.DB) error {
+	var deliveries []smodel.WebhookDelivery
+	if err := db.Select("id, payload").Find(&deliveries).Error; err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		var payload struct {
+			Data struct {
+				UserID string `json:"userId"`
+			} `json:"data"`
+		}
+		// тело без автора, например созданное вручную, оставляется как есть
+		if err := json.Unmarshal([]byte(delivery.Payload), &payload); err != nil {
+			continue
+		}
+		userId, err := globalid.DecodeAs(payload.Data.UserID, globalid.User)
+		if err != nil {
+			continue
+		}
+
+		if err := db.Exec("UPDATE webhook_deliveries SET user_id = ? WHERE id = ?", userId, delivery.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// notify создаёт уведомления о новом комментарии: автору родительского комментария
+// и упомянутым через @username пользователям, самому автору уведомления не приходят.
+// Вызывается в транзакции создания комментария
+func (s *Storage) notify(ctx context.Context, comment *smodel.Comment) error {
+	db := s.withContext(ctx)
+
+	notified := map[uint]bool{comment.UserID: true}
+	var notifications []smodel.Notification
+	add := func(userId uint, kind smodel.NotificationKind) {
+		if notified[userId] {
+			return
+		}
+		notified[userId] = true
+		notifications = append(notifications, smodel.Notification{
+			UserID:    userId,
+			Kind:      kind,
+			ActorID:   comment.UserID,
+			PostID:    comment.PostID,
+			CommentID: comment.ID,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+
+	if comment.ParentID != nil {
+		var parent smodel.Comment
+		if err := db.Select("user_id").First(&parent, *comment.ParentID).Error; err != nil {
+			return err
+		}
+		add(parent.UserID, smodel.NotificationReply)
+	}
+
+	if names := u.Mentions(comment.Content); len(names) > 0 {
+		var users []smodel.User
+		if err := db.Where("username IN (?)", names).Order("id").Find(&users).Error; err != nil {
+			return err
+		}
+
+		// как и в userByUsername, из пользователей с одним username упоминается первый
+		ids := make(map[string]uint, len(users))
+		for _, user := range users {
+			if _, ok := ids[user.Username]; !ok {
+				ids[user.Username] = user.ID
+			}
+		}
+		for _, name := range names {
+			if id, ok := ids[name]; ok {
+				add(id, smodel.NotificationMention)
+			}
+		}
+	}
+
+	for i := range notifications {
+		if err := db.Create(&notifications[i]).Error; err != nil {
+			return err
+		}
+	}
+	comment.Notifications = notifications
+
+	return nil
+}
+
+func (s *Storage) GetNotifications(ctx context.Context, userId uint, limit, offset int, unreadOnly bool) (*smodel.NotificationPage, error) {
+	var page smodel.NotificationPage
+	db := s.withContext(ctx).Model(&smodel.Notification{}).Where("user_id = ?", userId)
+
+	if err := db.Where("read = ?", false).Count(&page.UnreadCount).Error; err != nil {
+		return nil, err
+	}
+
+	if unreadOnly {
+		db = db.Where("read = ?", false)
+	}
+	if err := db.Count(&page.TotalCount).Error; err != nil {
+		return nil, err
+	}
+
+	page.Notifications = make([]*smodel.Notification, 0)
+	if err := db.Order("id DESC").Limit(limit).Offset(offset).Find(&page.Notifications).Error; err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (s *Storage) MarkNotificationsRead(ctx context.Context, userId uint, ids []uint) (int, error) {
+	// пустой список, а не nil - отмечать нечего
+	if ids != nil && len(ids) == 0 {
+		return 0, nil
+	}
+
+	db := s.withContext(ctx).Model(&smodel.Notification{}).Where("user_id = ? AND read = ?", userId, false)
+	if ids != nil {
+		db = db.Where("id IN (?)", ids)
+	}
+
+	res := db.UpdateColumn("read", true)
+	return int(res.RowsAffected), res.Error
+}
@@ -0,0 +1,108 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// Доменные события записываются в таблицу outbox_events в транзакции
+// изменения, поэтому событие есть тогда и только тогда, когда изменение
+// зафиксировано. Транзакции изменений друг друга не ждут, поэтому событие
+// с меньшим id может зафиксироваться позже. Порядок чтения задаёт место
+// события (position), которое читатель выдаёт уже зафиксированным событиям
+// под блокировкой Dialect.LockOutbox: места выдаются в порядке фиксации
+// и зафиксированное событие без места всегда получит место больше уже
+// выданных. outbox.Relay читает события по порядку мест и хранит
+// для каждого потребителя последнее обработанное место в outbox_offsets
+
+// addEvent записывает событие о записи пользователя userId, вызывается в транзакции изменения
+func (s *Storage) addEvent(ctx context.Context, typ smodel.OutboxEventType, userId uint, data interface{}, now time.Time) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return s.withContext(ctx).Create(&smodel.OutboxEvent{
+		Type:      typ,
+		Payload:   string(payload),
+		UserID:    userId,
+		CreatedAt: now,
+	}).Error
+}
+
+// assignPositions выдаёт места зафиксированным событиям без места, не больше limit
+func (s *Storage) assignPositions(ctx context.Context, limit int) error {
+	return s.inTx(ctx, sql.LevelDefault, func(tx *Storage) error {
+		db := tx.withContext(ctx)
+		// запросы после блокировки видят места, выданные предыдущим читателем
+		if err := tx.dialect.LockOutbox(db); err != nil {
+			return err
+		}
+
+		var last uint
+		if err := db.Model(&smodel.OutboxEvent{}).Select("COALESCE(MAX(position), 0)").Row().Scan(&last); err != nil {
+			return err
+		}
+
+		return db.Exec(`UPDATE outbox_events SET position = ? + numbered.n
+			FROM (SELECT id, row_number() OVER (ORDER BY id) AS n FROM outbox_events
+				WHERE position IS NULL ORDER BY id LIMIT ?) AS numbered
+			WHERE outbox_events.id = numbered.id`,
+			last, limit).Error
+	})
+}
+
+func (s *Storage) GetOutboxEvents(ctx context.Context, after uint, limit int) ([]*smodel.OutboxEvent, error) {
+	if err := s.assignPositions(ctx, limit); err != nil {
+		return nil, err
+	}
+
+	events := make([]*smodel.OutboxEvent, 0)
+	if err := s.withContext(ctx).Where("position > ?", after).Order("position").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (s *Storage) LastOutboxPosition(ctx context.Context) (uint, error) {
+	var position uint
+	if err := s.withContext(ctx).Model(&smodel.OutboxEvent{}).Select("COALESCE(MAX(position), 0)").Row().Scan(&position); err != nil {
+		return 0, err
+	}
+
+	return position, nil
+}
+
+func (s *Storage) GetOutboxOffset(ctx context.Context, consumer string) (uint, bool, error) {
+	var offsets []smodel.OutboxOffset
+	if err := s.withContext(ctx).Where("consumer = ?", consumer).Find(&offsets).Error; err != nil {
+		return 0, false, err
+	}
+	if len(offsets) == 0 {
+		return 0, false, nil
+	}
+
+	return offsets[0].LastEventID, true, nil
+}
+
+// SetOutboxOffset только сдвигает смещение вперёд
+func (s *Storage) SetOutboxOffset(ctx context.Context, consumer string, position uint) error {
+	return s.withContext(ctx).Exec(`INSERT INTO outbox_offsets (consumer, last_event_id) VALUES (?, ?)
+		ON CONFLICT (consumer) DO UPDATE SET last_event_id = excluded.last_event_id
+		WHERE outbox_offsets.last_event_id < excluded.last_event_id`,
+		consumer, position).Error
+}
+
+func (s *Storage) PruneOutbox(ctx context.Context, upTo uint, before time.Time) (int, error) {
+	res := s.withContext(ctx).Where("position <= ? AND created_at < ?", upTo, before).Delete(&smodel.OutboxEvent{})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+
+	return int(res.RowsAffected), nil
+}
@@ -0,0 +1,144 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Реакции хранятся в таблице reactions, по одной строке на пользователя,
+// цель и реакцию (первичный ключ). Количества лежат в reaction_counts
+// и меняются в той же транзакции, что и реакции: строка реакции вставляется
+// или удаляется одним запросом, и только если это произошло, меняется
+// количество. Поэтому повторы и одновременные запросы не сбивают количества
+
+func (s *Storage) React(ctx context.Context, r smodel.React) (*smodel.ReactionSummary, error) {
+	return s.changeReaction(ctx, r, func(db *gorm.DB) (bool, error) {
+		res := db.Exec(`INSERT INTO reactions (user_id, target_type, target_id, reaction, created_at)
+			VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+			r.UserId, r.Target.Type, r.Target.ID, r.Reaction, time.Now())
+		if res.Error != nil || res.RowsAffected == 0 {
+			return false, res.Error
+		}
+
+		err := db.Exec(`INSERT INTO reaction_counts (target_type, target_id, reaction, count) VALUES (?, ?, ?, 1)
+			ON CONFLICT (target_type, target_id, reaction) DO UPDATE SET count = reaction_counts.count + 1`,
+			r.Target.Type, r.Target.ID, r.Reaction).Error
+		return true, err
+	})
+}
+
+func (s *Storage) Unreact(ctx context.Context, r smodel.React) (*smodel.ReactionSummary, error) {
+	return s.changeReaction(ctx, r, func(db *gorm.DB) (bool, error) {
+		res := db.Exec("DELETE FROM reactions WHERE user_id = ? AND target_type = ? AND target_id = ? AND reaction = ?",
+			r.UserId, r.Target.Type, r.Target.ID, r.Reaction)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return false, res.Error
+		}
+
+		where := "target_type = ? AND target_id = ? AND reaction = ?"
+		if err := db.Exec("UPDATE reaction_counts SET count = count - 1 WHERE "+where,
+			r.Target.Type, r.Target.ID, r.Reaction).Error; err != nil {
+			return false, err
+		}
+		// реакций больше нет
+		err := db.Exec("DELETE FROM reaction_counts WHERE count <= 0 AND "+where,
+			r.Target.Type, r.Target.ID, r.Reaction).Error
+		return true, err
+	})
+}
+
+// changeReaction проверяет пользователя и цель и в той же транзакции
+// выполняет change, который возвращает, изменилась ли реакция
+func (s *Storage) changeReaction(ctx context.Context, r smodel.React, change func(db *gorm.DB) (bool, error)) (*smodel.ReactionSummary, error) {
+	var summary smodel.ReactionSummary
+
+	err := s.inTx(ctx, sql.LevelDefault, func(tx *Storage) error {
+		if err := tx.checkUserExists(ctx, r.UserId); err != nil {
+			return err
+		}
+
+		postID, err := tx.targetPost(ctx, r.Target)
+		if err != nil {
+			return err
+		}
+
+		changed, err := change(tx.withContext(ctx))
+		if err != nil {
+			return err
+		}
+
+		counts, err := tx.GetReactions(ctx, r.Target)
+		if err != nil {
+			return err
+		}
+
+		summary = smodel.ReactionSummary{
+			PostID:  postID,
+			Changed: changed,
+			Counts:  counts,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+// targetPost проверяет существование поста или комментария и возвращает id поста
+func (s *Storage) targetPost(ctx context.Context, target smodel.Target) (uint, error) {
+	db := s.withContext(ctx)
+
+	if target.Type == smodel.TargetPost {
+		var post smodel.Post
+		if err := db.Select("id").First(&post, target.ID).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return 0, errors.New(u.ErrorPostId(target.ID))
+			}
+			return 0, err
+		}
+		return post.ID, nil
+	}
+
+	var comment smodel.Comment
+	if err := db.Select("id, post_id").First(&comment, target.ID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, errors.New(u.ErrorCommId(target.ID))
+		}
+		return 0, err
+	}
+	return comment.PostID, nil
+}
+
+func (s *Storage) GetReactions(ctx context.Context, target smodel.Target) ([]*smodel.ReactionCount, error) {
+	counts := []*smodel.ReactionCount{}
+	err := s.withContext(ctx).
+		Where("target_type = ? AND target_id = ?", target.Type, target.ID).
+		Order("reaction").
+		Find(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func (s *Storage) GetUserReactions(ctx context.Context, userId uint, target smodel.Target) ([]string, error) {
+	reactions := []string{}
+	err := s.withContext(ctx).Model(&smodel.Reaction{}).
+		Where("user_id = ? AND target_type = ? AND target_id = ?", userId, target.Type, target.ID).
+		Order("reaction").
+		Pluck("reaction", &reactions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return reactions, nil
+}
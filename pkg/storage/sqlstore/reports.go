@@ -0,0 +1,141 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Жалобы хранятся в таблице reports, решение модератора - в колонке status
+// поста или комментария. Решение и закрытие жалоб на запись выполняются
+// в одной транзакции
+
+// статусы жалоб, по которым можно выбирать очередь
+var reportStatuses = map[smodel.ReportStatus]bool{
+	smodel.ReportOpen:     true,
+	smodel.ReportApproved: true,
+	smodel.ReportHidden:   true,
+	smodel.ReportDeleted:  true,
+}
+
+func (s *Storage) CreateReport(ctx context.Context, r smodel.CreateReport) (*smodel.Report, error) {
+	var report smodel.Report
+
+	err := s.inTx(ctx, sql.LevelDefault, func(tx *Storage) error {
+		if r.ReporterId != nil {
+			if err := tx.checkUserExists(ctx, *r.ReporterId); err != nil {
+				return err
+			}
+		}
+
+		postID, err := tx.targetPost(ctx, r.Target)
+		if err != nil {
+			return err
+		}
+
+		report = smodel.Report{
+			TargetType: r.Target.Type,
+			TargetID:   r.Target.ID,
+			PostID:     postID,
+			ReporterID: r.ReporterId,
+			Reason:     r.Reason,
+			Status:     smodel.ReportOpen,
+		}
+		return tx.withContext(ctx).Create(&report).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+func (s *Storage) GetReports(ctx context.Context, limit, offset int, status smodel.ReportStatus) (*smodel.ReportPage, error) {
+	if !reportStatuses[status] {
+		return nil, errors.New(u.ErrorReportStatus(string(status)))
+	}
+
+	var reports []*smodel.Report
+	var totalCount int
+	db := s.withContext(ctx).Where("status = ?", status)
+
+	if err := db.Model(&smodel.Report{}).Count(&totalCount).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Order("id").Limit(limit).Offset(offset).Find(&reports).Error; err != nil {
+		return nil, err
+	}
+
+	return &smodel.ReportPage{
+		Reports:    reports,
+		TotalCount: totalCount,
+	}, nil
+}
+
+func (s *Storage) ResolveReport(ctx context.Context, r smodel.ResolveReport) (*smodel.Report, error) {
+	result, ok := smodel.ModerationResults[r.Action]
+	if !ok {
+		return nil, errors.New(u.ErrorModerationAction(string(r.Action)))
+	}
+
+	var report smodel.Report
+
+	// два модератора не могут одновременно закрыть одну жалобу:
+	// проверка и закрытие в одной транзакции, которая повторяется при конфликте
+	err := s.inTx(ctx, sql.LevelSerializable, func(tx *Storage) error {
+		db := tx.withContext(ctx)
+
+		if err := db.First(&report, r.ReportId).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return errors.New(u.ErrorReportId(r.ReportId))
+			}
+			return err
+		}
+		if report.Status != smodel.ReportOpen {
+			return errors.New(u.ErrorReportResolved(r.ReportId))
+		}
+
+		if err := tx.checkUserExists(ctx, r.ModeratorId); err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		// при удалении текст стирается, а прежний остаётся в версиях записи
+		updates := map[string]interface{}{"status": result.Content}
+		if result.Content == smodel.ContentDeleted {
+			target := smodel.Target{Type: report.TargetType, ID: report.TargetID}
+			if err := tx.addRevision(ctx, target, smodel.Revision{EditorID: r.ModeratorId, CreatedAt: now}); err != nil {
+				return err
+			}
+
+			updates["content"] = ""
+			if report.TargetType == smodel.TargetPost {
+				updates["title"] = ""
+			}
+		}
+		if err := db.Table(targetTables[report.TargetType]).Where("id = ?", report.TargetID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if err := db.Exec(`UPDATE reports SET status = ?, moderator_id = ?, resolution = ?, resolved_at = ?
+			WHERE target_type = ? AND target_id = ? AND status = ?`,
+			result.Report, r.ModeratorId, r.Reason, now,
+			report.TargetType, report.TargetID, smodel.ReportOpen).Error; err != nil {
+			return err
+		}
+
+		return db.First(&report, r.ReportId).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,244 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Версии постов и комментариев хранятся в таблице revisions. Версии создаются
+// в транзакции изменения записи, номер следующей версии - наибольший номер
+// плюс один, а уникальный индекс (target_type, target_id, number) не даёт
+// двум параллельным изменениям получить один номер
+
+func (s *Storage) EditPost(ctx context.Context, e smodel.EditPost) (*smodel.Post, error) {
+	var post smodel.Post
+	target := smodel.Target{Type: smodel.TargetPost, ID: e.PostId}
+
+	err := s.inTx(ctx, sql.LevelSerializable, func(tx *Storage) error {
+		db := tx.withContext(ctx)
+
+		post = smodel.Post{}
+		if err := preloadTags(db).Preload("User").First(&post, e.PostId).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return errors.New(u.ErrorPostId(e.PostId))
+			}
+			return err
+		}
+		if err := u.CheckEdit(target, post.UserID, post.Status, post.RemovedAt, e.EditorId); err != nil {
+			return err
+		}
+
+		title, content, err := u.MergeEdit(target, post.Title, post.Content, e)
+		if err != nil {
+			return err
+		}
+		// текст не изменился, новая версия не нужна
+		if title == post.Title && content == post.Content {
+			return nil
+		}
+
+		now := time.Now()
+		err = tx.addRevision(ctx, target, smodel.Revision{Title: title, Content: content, EditorID: e.EditorId, CreatedAt: now})
+		if err != nil {
+			return err
+		}
+
+		err = db.Model(&smodel.Post{}).Where("id = ?", e.PostId).UpdateColumns(map[string]interface{}{
+			"title":     title,
+			"content":   content,
+			"edited_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		post.Title, post.Content, post.EditedAt = title, content, &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
+
+func (s *Storage) EditComment(ctx context.Context, e smodel.EditComment) (*smodel.Comment, error) {
+	var comment smodel.Comment
+	target := smodel.Target{Type: smodel.TargetComment, ID: e.CommentId}
+
+	err := s.inTx(ctx, sql.LevelSerializable, func(tx *Storage) error {
+		db := tx.withContext(ctx)
+
+		comment = smodel.Comment{}
+		if err := db.Preload("User").First(&comment, e.CommentId).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return errors.New(u.ErrorCommId(e.CommentId))
+			}
+			return err
+		}
+		if err := u.CheckEdit(target, comment.UserID, comment.Status, comment.RemovedAt, e.EditorId); err != nil {
+			return err
+		}
+		if e.Content == comment.Content {
+			return nil
+		}
+
+		// длина проверяется по настройкам доски, как при создании
+		var post smodel.Post
+		if err := db.Select("id, board_id").First(&post, comment.PostID).Error; err != nil {
+			return err
+		}
+		if err := tx.checkCommentLength(ctx, post, e.Content); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.addRevision(ctx, target, smodel.Revision{Content: e.Content, EditorID: e.EditorId, CreatedAt: now}); err != nil {
+			return err
+		}
+
+		err := db.Model(&smodel.Comment{}).Where("id = ?", e.CommentId).UpdateColumns(map[string]interface{}{
+			"content":   e.Content,
+			"edited_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		comment.Content, comment.EditedAt = e.Content, &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &comment, nil
+}
+
+// addRevision сохраняет новую версию записи, если её текст отличается
+// от последней версии. При первом изменении сначала сохраняется исходная
+// версия из текущего текста записи. Вызывается в транзакции изменения
+// до записи нового текста
+func (s *Storage) addRevision(ctx context.Context, target smodel.Target, next smodel.Revision) error {
+	db := s.withContext(ctx)
+
+	var latest []*smodel.Revision
+	err := db.Where("target_type = ? AND target_id = ?", target.Type, target.ID).Order("number DESC").Limit(1).Find(&latest).Error
+	if err != nil {
+		return err
+	}
+
+	var last *smodel.Revision
+	if len(latest) > 0 {
+		last = latest[0]
+	} else {
+		if last, err = s.currentRevision(ctx, target); err != nil {
+			return err
+		}
+	}
+	if last.Title == next.Title && last.Content == next.Content {
+		return nil
+	}
+
+	// исходная версия ещё не сохранена
+	if last.ID == 0 {
+		if err := db.Create(last).Error; err != nil {
+			return err
+		}
+	}
+
+	next.TargetType, next.TargetID, next.Number = target.Type, target.ID, last.Number+1
+	return db.Create(&next).Error
+}
+
+// currentRevision возвращает текущий текст записи, у которой ещё нет версий, как версию 1
+func (s *Storage) currentRevision(ctx context.Context, target smodel.Target) (*smodel.Revision, error) {
+	db := s.withContext(ctx)
+	revision := smodel.Revision{TargetType: target.Type, TargetID: target.ID, Number: 1}
+
+	if target.Type == smodel.TargetPost {
+		var post smodel.Post
+		if err := db.Select("id, title, content, user_id, created_at").First(&post, target.ID).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return nil, errors.New(u.ErrorPostId(target.ID))
+			}
+			return nil, err
+		}
+		revision.Title, revision.Content = post.Title, post.Content
+		revision.EditorID, revision.CreatedAt = post.UserID, post.CreatedAt
+	} else {
+		var comment smodel.Comment
+		if err := db.Select("id, content, user_id, created_at").First(&comment, target.ID).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return nil, errors.New(u.ErrorCommId(target.ID))
+			}
+			return nil, err
+		}
+		revision.Content = comment.Content
+		revision.EditorID, revision.CreatedAt = comment.UserID, comment.CreatedAt
+	}
+
+	return &revision, nil
+}
+
+func (s *Storage) GetRevisions(ctx context.Context, target smodel.Target, limit, offset int) (*smodel.RevisionPage, error) {
+	page := smodel.RevisionPage{Revisions: make([]*smodel.Revision, 0)}
+	db := s.withContext(ctx).Model(&smodel.Revision{}).Where("target_type = ? AND target_id = ?", target.Type, target.ID)
+
+	if err := db.Count(&page.TotalCount).Error; err != nil {
+		return nil, err
+	}
+
+	// запись не менялась
+	if page.TotalCount == 0 {
+		revision, err := s.currentRevision(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		page.TotalCount = 1
+		if offset == 0 && limit > 0 {
+			page.Revisions = append(page.Revisions, revision)
+		}
+		return &page, nil
+	}
+
+	if err := db.Order("number").Limit(limit).Offset(offset).Find(&page.Revisions).Error; err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (s *Storage) GetRevision(ctx context.Context, target smodel.Target, number int) (*smodel.Revision, error) {
+	db := s.withContext(ctx)
+
+	var revisions []*smodel.Revision
+	err := db.Where("target_type = ? AND target_id = ? AND number = ?", target.Type, target.ID, number).Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) > 0 {
+		return revisions[0], nil
+	}
+
+	var count int
+	if err := db.Model(&smodel.Revision{}).Where("target_type = ? AND target_id = ?", target.Type, target.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	// ошибка, если записи нет. У записи без версий есть только версия 1 - текущий текст
+	revision, err := s.currentRevision(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 || number != 1 {
+		return nil, errors.New(u.ErrorRevision(target, number))
+	}
+
+	return revision, nil
+}
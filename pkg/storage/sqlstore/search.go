@@ -0,0 +1,147 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"strings"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// Полнотекстовый поиск. Поиск по индексу зависит от базы и выполняется
+// диалектом (см. Dialect.Search), а хранилище загружает найденные записи
+
+// виды найденных записей в SearchRow
+const (
+	SearchPost    = "post"
+	SearchComment = "comment"
+)
+
+// Диалект выделяет найденные слова во фрагменте текста управляющими
+// символами SnippetStart и SnippetStop, а не тегами. Так текст пользователя
+// экранируется как HTML целиком, и только после этого выделение
+// заменяется на <b></b>
+const (
+	SnippetStart = "\x01"
+	SnippetStop  = "\x02"
+)
+
+var snippetMarkup = strings.NewReplacer(SnippetStart, "<b>", SnippetStop, "</b>")
+
+// SearchRow - найденная запись: её вид и id, ранг и фрагмент текста с подсветкой
+type SearchRow struct {
+	Kind    string
+	ID      uint
+	Rank    float64
+	Snippet string
+}
+
+// SearchFilters возвращает условия фильтров поиска записей таблицы table
+// по автору и дате создания, которые дописываются к WHERE, и их аргументы.
+// Скрытые модератором и удалённые записи не ищутся. Комментарии скрытых
+// и удалённых постов тоже, поэтому запрос по comments соединяется с posts
+func SearchFilters(q smodel.Search, table string) (string, []interface{}) {
+	var filters strings.Builder
+	args := []interface{}{smodel.ContentVisible}
+	filters.WriteString(" AND " + table + ".status = ? AND " + table + ".removed_at IS NULL")
+	if table == "comments" {
+		filters.WriteString(" AND posts.status = ? AND posts.removed_at IS NULL")
+		args = append(args, smodel.ContentVisible)
+	}
+
+	if q.AuthorId != nil {
+		filters.WriteString(" AND " + table + ".user_id = ?")
+		args = append(args, *q.AuthorId)
+	}
+	if q.From != nil {
+		filters.WriteString(" AND " + table + ".created_at >= ?")
+		args = append(args, *q.From)
+	}
+	if q.To != nil {
+		filters.WriteString(" AND " + table + ".created_at < ?")
+		args = append(args, *q.To)
+	}
+
+	return filters.String(), args
+}
+
+func (s *Storage) Search(ctx context.Context, q smodel.Search) (*smodel.SearchPage, error) {
+	db := s.withContext(ctx)
+
+	rows, totalCount, err := s.dialect.Search(db, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var postIds, commIds []uint
+	for _, row := range rows {
+		if row.Kind == SearchPost {
+			postIds = append(postIds, row.ID)
+		} else {
+			commIds = append(commIds, row.ID)
+		}
+	}
+
+	// найденные записи загружаются двумя запросами, а не по одной
+	posts := make(map[uint]*smodel.Post, len(postIds))
+	if len(postIds) > 0 {
+		var found []*smodel.Post
+		if err := preloadTags(db).Preload("User").Where("id IN (?)", postIds).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		for _, post := range found {
+			posts[post.ID] = post
+		}
+	}
+
+	comms := make(map[uint]*smodel.Comment, len(commIds))
+	if len(commIds) > 0 {
+		var found []*smodel.Comment
+		if err := db.Preload("User").Where("id IN (?)", commIds).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		for _, comm := range found {
+			comms[comm.ID] = comm
+		}
+	}
+
+	hits := make([]*smodel.SearchHit, 0, len(rows))
+	for _, row := range rows {
+		hit := &smodel.SearchHit{Rank: row.Rank, Snippet: row.Snippet}
+		if row.Kind == SearchPost {
+			hit.Post = posts[row.ID]
+		} else {
+			hit.Comment = comms[row.ID]
+		}
+
+		// запись удалена между поиском и загрузкой
+		if hit.Post == nil && hit.Comment == nil {
+			continue
+		}
+		hits = append(hits, hit)
+	}
+
+	return &smodel.SearchPage{
+		Hits:       hits,
+		TotalCount: totalCount,
+	}, nil
+}
+
+// ScanSearchRows читает строки kind, id, rank, snippet и закрывает rows.
+// Фрагмент экранируется, а выделение в нём заменяется на <b></b>
+func ScanSearchRows(rows *sql.Rows, totalCount int) ([]SearchRow, int, error) {
+	defer rows.Close()
+
+	var res []SearchRow
+	for rows.Next() {
+		var row SearchRow
+		if err := rows.Scan(&row.Kind, &row.ID, &row.Rank, &row.Snippet); err != nil {
+			return nil, 0, err
+		}
+		row.Snippet = snippetMarkup.Replace(html.EscapeString(row.Snippet))
+		res = append(res, row)
+	}
+
+	return res, totalCount, rows.Err()
+}
@@ -0,0 +1,52 @@
+package sqlstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/ratelimit"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+func (s *Storage) SetSlowMode(ctx context.Context, m smodel.SetSlowMode) (*smodel.Post, error) {
+	db := s.withContext(ctx).Model(&smodel.Post{}).Where("id = ?", m.PostId).UpdateColumn("slow_mode_seconds", m.Seconds)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	if db.RowsAffected == 0 {
+		return nil, errors.New(u.ErrorPostId(m.PostId))
+	}
+
+	var post smodel.Post
+	if err := preloadTags(s.withContext(ctx)).Preload("User").First(&post, m.PostId).Error; err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
+
+// checkSlowMode проверяет, что с прошлого комментария пользователя к посту
+// прошёл интервал медленного режима. Вызывается в транзакции создания комментария
+func (s *Storage) checkSlowMode(ctx context.Context, post smodel.Post, userId uint, now time.Time) error {
+	if post.SlowModeSeconds == 0 {
+		return nil
+	}
+
+	var last smodel.Comment
+	err := s.withContext(ctx).Select("created_at").Where("post_id = ? AND user_id = ?", post.ID, userId).
+		Order("created_at DESC").First(&last).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if wait := last.CreatedAt.Add(time.Duration(post.SlowModeSeconds) * time.Second).Sub(now); wait > 0 {
+		return &ratelimit.Error{RetryAfter: wait, Reason: u.ErrorSlowMode(post.SlowModeSeconds)}
+	}
+	return nil
+}
@@ -0,0 +1,524 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/ranking"
+
+	"github.com/jinzhu/gorm"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Storage - хранилище в реляционной базе через gorm. Запросы общие для всех
+// баз, а то, чем базы отличаются, описывает Dialect
+type Storage struct {
+	DB *gorm.DB
+
+	dialect Dialect
+	// транзакция единицы работы, nil вне inTx
+	tx *sql.Tx
+}
+
+// NewStore создаёт хранилище поверх открытого соединения gorm с базой,
+// отличия которой описывает d
+func NewStore(db *gorm.DB, d Dialect) (*Storage, error) {
+	if err := migrate(db, d); err != nil {
+		return nil, err
+	}
+	return &Storage{DB: db, dialect: d}, nil
+}
+
+func (s *Storage) CreatePost(ctx context.Context, p smodel.CreatePost) (*smodel.Post, error) {
+	err := s.checkActiveUser(ctx, p.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	var post smodel.Post
+
+	// пост и его теги создаются вместе
+	err = s.inTx(ctx, sql.LevelDefault, func(tx *Storage) error {
+		now := time.Now()
+
+		// повтор по ключу идемпотентности возвращает созданный пост
+		if p.Idempotency != nil {
+			id, err := tx.claimKey(ctx, p.UserId, *p.Idempotency, smodel.TargetPost, now)
+			if err != nil {
+				return err
+			}
+			if id != 0 {
+				post = smodel.Post{}
+				if err := preloadTags(tx.withContext(ctx)).Preload("User").First(&post, id).Error; err != nil {
+					return err
+				}
+				post.Replayed = true
+				return nil
+			}
+		}
+
+		// проверка настроек доски
+		if p.BoardId != nil {
+			if err := tx.checkPoster(ctx, *p.BoardId, p.UserId); err != nil {
+				return err
+			}
+		}
+
+		post = smodel.Post{
+			Title:           p.Title,
+			Content:         p.Content,
+			UserID:          p.UserId,
+			CommentsEnabled: p.CommentsEnabled,
+			BoardID:         p.BoardId,
+			SlowModeSeconds: p.SlowModeSeconds,
+			CreatedAt:       now,
+			// ранг без голосов зависит только от времени создания
+			Votes: smodel.Votes{HotRank: ranking.Hot(0, 0, now)},
+		}
+
+		if err := tx.withContext(ctx).Create(&post).Error; err != nil {
+			return err
+		}
+
+		if err := tx.attachTags(ctx, &post, p.Tags); err != nil {
+			return err
+		}
+
+		// событие фиксируется вместе с постом
+		if err := tx.addEvent(ctx, smodel.EventPostCreated, post.UserID, post, now); err != nil {
+			return err
+		}
+
+		if p.Idempotency != nil {
+			return tx.setKeyTarget(ctx, p.UserId, *p.Idempotency, post.ID)
+		}
+		return nil
+	})
+	// проверка выше не защищает от параллельных изменений,
+	// поэтому нарушение внешнего ключа тоже переводится в доменную ошибку
+	if err != nil {
+		return nil, s.constraintError(err, map[string]func() error{
+			"posts_user_id_fkey":            func() error { return errors.New(u.ErrorUserId(p.UserId)) },
+			"idempotency_keys_user_id_fkey": func() error { return errors.New(u.ErrorUserId(p.UserId)) },
+			"posts_board_id_fkey":           func() error { return errors.New(u.ErrorBoardId(*p.BoardId)) },
+		})
+	}
+
+	return &post, nil
+}
+
+func (s *Storage) CreateComment(ctx context.Context, c smodel.CreateComment) (*smodel.Comment, error) {
+	var comment smodel.Comment
+
+	// проверки и вставка выполняются в одной транзакции, чтобы между ними
+	// у поста не отключили комментарии и не пропал родительский комментарий
+	err := s.inTx(ctx, sql.LevelSerializable, func(tx *Storage) error {
+		// проверка существования автора
+		err := tx.checkActiveUser(ctx, c.UserId)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		// повтор по ключу идемпотентности возвращает созданный комментарий
+		if c.Idempotency != nil {
+			id, err := tx.claimKey(ctx, c.UserId, *c.Idempotency, smodel.TargetComment, now)
+			if err != nil {
+				return err
+			}
+			if id != 0 {
+				comment = smodel.Comment{}
+				if err := tx.withContext(ctx).Preload("User").First(&comment, id).Error; err != nil {
+					return err
+				}
+				comment.Replayed = true
+				return nil
+			}
+		}
+
+		// проверка существованя поста и что можно оставлять комментарии
+		err = tx.checkPost(ctx, c.PostId, c.UserId, c.Content, now)
+		if err != nil {
+			return err
+		}
+
+		// если ответ на другой комментарий
+		if c.ParentId != nil {
+			// проверка существования родительского поста и совпадения их id поста
+			err = tx.checkParentId(ctx, c.PostId, *c.ParentId)
+			if err != nil {
+				return err
+			}
+		}
+
+		comment = smodel.Comment{
+			PostID:    c.PostId,
+			ParentID:  c.ParentId,
+			UserID:    c.UserId,
+			Content:   c.Content,
+			CreatedAt: now,
+			Votes:     smodel.Votes{HotRank: ranking.Hot(0, 0, now)},
+		}
+
+		if err := tx.withContext(ctx).Create(&comment).Error; err != nil {
+			return err
+		}
+
+		// уведомления создаются вместе с комментарием
+		if err := tx.notify(ctx, &comment); err != nil {
+			return err
+		}
+
+		// счётчики поста обновляются в той же транзакции, что и вставка
+		topLevel := 0
+		if c.ParentId == nil {
+			topLevel = 1
+		}
+		err = tx.withContext(ctx).Model(&smodel.Post{}).Where("id = ?", c.PostId).UpdateColumns(map[string]interface{}{
+			"comment_count":           gorm.Expr("comment_count + 1"),
+			"top_level_comment_count": gorm.Expr("top_level_comment_count + ?", topLevel),
+			"last_comment_at":         comment.CreatedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		// событие фиксируется вместе с комментарием и уведомлениями
+		event := smodel.CommentCreated{Comment: comment, Notifications: comment.Notifications}
+		if err := tx.addEvent(ctx, smodel.EventCommentCreated, comment.UserID, event, now); err != nil {
+			return err
+		}
+
+		if c.Idempotency != nil {
+			return tx.setKeyTarget(ctx, c.UserId, *c.Idempotency, comment.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		// ограничения бд остаются последней защитой, их нарушения тоже
+		// переводятся в доменные ошибки. Транзакция к этому моменту
+		// уже откатена, поэтому родитель проверяется вне её
+		return nil, s.constraintError(err, map[string]func() error{
+			"comments_user_id_fkey":         func() error { return errors.New(u.ErrorUserId(c.UserId)) },
+			"idempotency_keys_user_id_fkey": func() error { return errors.New(u.ErrorUserId(c.UserId)) },
+			"comments_post_id_fkey":         func() error { return errors.New(u.ErrorPostId(c.PostId)) },
+			"comments_parent_fkey": func() error {
+				// по ограничению не понять, нет родителя или у него другой пост
+				if parentErr := s.checkParentId(ctx, c.PostId, *c.ParentId); parentErr != nil {
+					return parentErr
+				}
+				return err
+			},
+		})
+	}
+
+	return &comment, nil
+}
+
+func (s *Storage) CreateUser(ctx context.Context, u smodel.CreateUser) (*smodel.User, error) {
+	user := smodel.User{
+		Username: u.Username,
+	}
+
+	if err := s.withContext(ctx).Create(&user).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *Storage) GetUser(ctx context.Context, id uint) (*smodel.User, error) {
+	var user smodel.User
+	if err := s.withContext(ctx).First(&user, id).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New(u.ErrorUserId(id))
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetUserByUsername возвращает первого созданного пользователя с username
+func (s *Storage) GetUserByUsername(ctx context.Context, username string) (*smodel.User, error) {
+	var user smodel.User
+	if err := s.withContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New(u.ErrorUsername(username))
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *Storage) GetUserPosts(ctx context.Context, limit, offset int, id uint, withDeleted bool) (*smodel.PostPage, error) {
+	if err := s.checkUserExists(ctx, id); err != nil {
+		return nil, err
+	}
+
+	var posts []*smodel.Post
+	var totalCount int
+	db := s.withContext(ctx).Where("user_id = ?", id)
+	if !withDeleted {
+		db = db.Where("removed_at IS NULL")
+	}
+
+	if err := db.Model(&smodel.Post{}).Count(&totalCount).Error; err != nil {
+		return nil, err
+	}
+
+	if err := preloadTags(db).Preload("User").Order("created_at DESC, id DESC").
+		Limit(limit).Offset(offset).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	return &smodel.PostPage{
+		Posts:      posts,
+		TotalCount: totalCount,
+	}, nil
+}
+
+func (s *Storage) GetUserComments(ctx context.Context, limit, offset int, id uint, withDeleted bool) (*smodel.CommPage, error) {
+	if err := s.checkUserExists(ctx, id); err != nil {
+		return nil, err
+	}
+
+	comms := make([]*smodel.Comment, 0)
+	var totalCount int
+	db := s.withContext(ctx).Where("user_id = ?", id)
+	if !withDeleted {
+		db = db.Where("status = ? AND removed_at IS NULL", smodel.ContentVisible).
+			Where("NOT EXISTS (SELECT 1 FROM posts WHERE posts.id = comments.post_id AND posts.removed_at IS NOT NULL)")
+	}
+
+	if err := db.Model(&smodel.Comment{}).Count(&totalCount).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Preload("User").Order("created_at DESC, id DESC").
+		Limit(limit).Offset(offset).Find(&comms).Error; err != nil {
+		return nil, err
+	}
+
+	return &smodel.CommPage{
+		Comms:      comms,
+		TotalCount: totalCount,
+	}, nil
+}
+
+// порядок постов для каждого варианта сортировки, под каждый есть индекс
+var postOrders = map[smodel.PostSort]string{
+	smodel.PostSortDefault:              "id",
+	smodel.PostSortCommentCount:         "comment_count DESC, id DESC",
+	smodel.PostSortTopLevelCommentCount: "top_level_comment_count DESC, id DESC",
+	smodel.PostSortLastCommentAt:        "last_comment_at DESC NULLS LAST, id DESC",
+	smodel.PostSortTop:                  "top_rank DESC, id DESC",
+	smodel.PostSortHot:                  "hot_rank DESC, id DESC",
+}
+
+// порядок комментариев на каждом уровне дерева
+var commentOrders = map[smodel.CommentSort]string{
+	smodel.CommentSortDefault: "id",
+	smodel.CommentSortTop:     "top_rank DESC, id DESC",
+	smodel.CommentSortHot:     "hot_rank DESC, id DESC",
+}
+
+func (s *Storage) GetPosts(ctx context.Context, limit, offset int, sort smodel.PostSort, filter smodel.PostFilter) (*smodel.PostPage, error) {
+	order, ok := postOrders[sort]
+	if !ok {
+		return nil, errors.New(u.ErrorPostSort(string(sort)))
+	}
+
+	var posts []*smodel.Post
+	var totalCount int
+	db := s.withContext(ctx)
+
+	if err := filterPosts(db.Model(&smodel.Post{}), filter).Count(&totalCount).Error; err != nil {
+		return nil, err
+	}
+
+	if err := filterPosts(preloadTags(db).Preload("User"), filter).Order(order).Limit(limit).Offset(offset).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	return &smodel.PostPage{
+		Posts:      posts,
+		TotalCount: totalCount,
+	}, nil
+}
+
+func (s *Storage) GetPost(ctx context.Context, limit, offset int, sort smodel.CommentSort, id uint) (*smodel.Post, error) {
+	order, ok := commentOrders[sort]
+	if !ok {
+		return nil, errors.New(u.ErrorCommentSort(string(sort)))
+	}
+
+	var post smodel.Post
+	db := s.withContext(ctx)
+
+	if err := preloadTags(db).Preload("User").Preload("Comments", func(db *gorm.DB) *gorm.DB {
+		return db.Where("parent_id IS NULL").Order(order).Offset(offset).Limit(limit)
+	}).Preload("Comments.User").First(&post, id).Error; err != nil {
+		// проверка существования поста
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New(u.ErrorPostId(id))
+		}
+		return nil, err
+	}
+
+	// получение комментариев к посту
+	// начинаем с глубины 1, так как уже есть ответы на пост
+	comms := post.Comments
+	for _, comm := range comms {
+		subComms, err := s.getComments(ctx, limit, offset, order, (*comm).ID, 1)
+		if err != nil {
+			return nil, err
+		}
+		(*comm).ReplyPage = subComms
+	}
+
+	var totalCount int
+	if err := db.Model(&smodel.Comment{}).Where("post_id = ? AND parent_id IS NULL", id).Count(&totalCount).Error; err != nil {
+		return nil, err
+	}
+
+	post.CommPage = &smodel.CommPage{
+		Comms:      comms,
+		TotalCount: totalCount,
+	}
+
+	return &post, nil
+}
+
+func (s *Storage) GetComments(ctx context.Context, limit, offset int, sort smodel.CommentSort, id uint) (*smodel.Comment, error) {
+	order, ok := commentOrders[sort]
+	if !ok {
+		return nil, errors.New(u.ErrorCommentSort(string(sort)))
+	}
+
+	var comm smodel.Comment
+
+	if err := s.withContext(ctx).Preload("User").First(&comm, id).Error; err != nil {
+		// проверка существования комментария
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New(u.ErrorCommId(id))
+		}
+		return nil, err
+	}
+
+	// получение комментариев
+	// начинаем с глубины 1, так как уже есть сам комментарий
+	var err error
+	comm.ReplyPage, err = s.getComments(ctx, limit, offset, order, id, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comm, nil
+}
+
+// рекурсивно получает комментарии, order - порядок на каждом уровне
+func (s *Storage) getComments(ctx context.Context, limit, offset int, order string, id uint, depth int) (*smodel.CommPage, error) {
+	commPage := smodel.CommPage{
+		Comms:      make([]*smodel.Comment, 0),
+		TotalCount: 0,
+	}
+
+	var comms []*smodel.Comment
+	db := s.withContext(ctx)
+
+	if depth > 4 {
+		return &commPage, nil
+	}
+
+	if err := db.Preload("User").Where("parent_id = ?", id).Order(order).Offset(offset).Limit(limit).Find(&comms).Error; err != nil {
+		return nil, err
+	}
+
+	if len(comms) == 0 {
+		return &commPage, nil
+	}
+
+	var totalCount int
+	if err := db.Model(&smodel.Comment{}).Where("parent_id = ?", id).Count(&totalCount).Error; err != nil {
+		return nil, err
+	}
+	commPage.TotalCount = totalCount
+
+	for i := range comms {
+		childComments, err := s.getComments(ctx, limit, offset, order, comms[i].ID, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		comms[i].ReplyPage = childComments
+	}
+
+	commPage.Comms = comms
+
+	return &commPage, nil
+}
+
+func (s *Storage) checkUserExists(ctx context.Context, userID uint) error {
+	var user smodel.User
+	if err := s.withContext(ctx).First(&user, userID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New(u.ErrorUserId(userID))
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) checkPost(ctx context.Context, postID, userID uint, content string, now time.Time) error {
+	var post smodel.Post
+
+	// проверка существования поста
+	if err := s.withContext(ctx).First(&post, postID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New(u.ErrorPostId(postID))
+		}
+		return err
+	}
+
+	// удалённый пост не комментируется
+	if post.RemovedAt != nil {
+		return errors.New(u.ErrorRemoved(smodel.Target{Type: smodel.TargetPost, ID: postID}))
+	}
+
+	// проверка что можно оставлять комментарии
+	if !post.CommentsEnabled {
+		return errors.New(u.ErrorCommDisable())
+	}
+
+	// проверка длины комментария по настройкам доски
+	if err := s.checkCommentLength(ctx, post, content); err != nil {
+		return err
+	}
+
+	// проверка медленного режима
+	return s.checkSlowMode(ctx, post, userID, now)
+}
+
+func (s *Storage) checkParentId(ctx context.Context, postId, parentId uint) error {
+	var comm smodel.Comment
+
+	// проверка существования родительского поста
+	if err := s.withContext(ctx).First(&comm, parentId).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New(u.ErrorParentIdForReply(parentId))
+		}
+		return err
+	}
+
+	// проверка чтобы ответ на комментарий был под тем же постом
+	if postId != comm.PostID {
+		return errors.New(u.ErorrMismatchPostId(postId, comm.PostID))
+	}
+
+	return nil
+}
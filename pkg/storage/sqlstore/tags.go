@@ -0,0 +1,91 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// Теги хранятся в таблице tags, связь с постами - в post_tags (many-to-many)
+
+// attachTags создаёт недостающие теги и привязывает их к посту,
+// вызывается в транзакции создания поста
+func (s *Storage) attachTags(ctx context.Context, post *smodel.Post, names []string) error {
+	post.Tags = []smodel.Tag{}
+	if len(names) == 0 {
+		return nil
+	}
+
+	db := s.withContext(ctx)
+
+	// тег мог создать параллельный запрос, тогда вставка пропускается
+	for _, name := range names {
+		if err := db.Exec("INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING", name).Error; err != nil {
+			return err
+		}
+	}
+
+	var tags []smodel.Tag
+	if err := db.Where("name IN (?)", names).Order("name").Find(&tags).Error; err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if err := db.Exec("INSERT INTO post_tags (post_id, tag_id) VALUES (?, ?)", post.ID, tag.ID).Error; err != nil {
+			return err
+		}
+	}
+	post.Tags = tags
+
+	return nil
+}
+
+// preloadTags загружает теги постов в порядке имён
+func preloadTags(db *gorm.DB) *gorm.DB {
+	return db.Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tags.name")
+	})
+}
+
+// filterPosts добавляет к запросу постов условия фильтра
+func filterPosts(db *gorm.DB, f smodel.PostFilter) *gorm.DB {
+	if !f.WithDeleted {
+		db = db.Where("posts.removed_at IS NULL")
+	}
+	if f.BoardId != nil {
+		db = db.Where("posts.board_id = ?", *f.BoardId)
+	}
+
+	if len(f.Tags) == 0 {
+		return db
+	}
+
+	tagged := "SELECT post_tags.post_id FROM post_tags JOIN tags ON tags.id = post_tags.tag_id WHERE tags.name IN (?)"
+	if f.AllTags {
+		return db.Where("posts.id IN ("+tagged+" GROUP BY post_tags.post_id HAVING COUNT(*) = ?)", f.Tags, len(f.Tags))
+	}
+
+	return db.Where("posts.id IN ("+tagged+")", f.Tags)
+}
+
+func (s *Storage) GetTags(ctx context.Context, limit, offset int) ([]*smodel.TagCount, error) {
+	rows, err := s.withContext(ctx).Raw(`SELECT tags.name, COUNT(*) AS post_count
+		FROM tags JOIN post_tags ON post_tags.tag_id = tags.id
+		GROUP BY tags.name ORDER BY post_count DESC, tags.name LIMIT ? OFFSET ?`, limit, offset).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]*smodel.TagCount, 0)
+	for rows.Next() {
+		var tag smodel.TagCount
+		if err := rows.Scan(&tag.Name, &tag.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+
+	return tags, rows.Err()
+}
@@ -0,0 +1,56 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	// сколько раз выполняется транзакция, прежде чем вернуть ошибку
+	txAttempts = 5
+	// пауза перед повтором, растёт с каждой попыткой
+	txRetryDelay = 10 * time.Millisecond
+)
+
+// inTx выполняет fn как единицу работы: все запросы tx идут в одной
+// транзакции с уровнем изоляции level. Если fn вернула ошибку, то транзакция
+// откатывается. При временной ошибке (ошибка сериализации, взаимная блокировка,
+// см. Dialect.Retryable) вся единица работы повторяется заново,
+// поэтому fn не должна иметь побочных эффектов вне бд
+func (s *Storage) inTx(ctx context.Context, level sql.IsolationLevel, fn func(tx *Storage) error) error {
+	var err error
+	for attempt := 1; attempt <= txAttempts; attempt++ {
+		err = s.runTx(ctx, level, fn)
+		// после последней попытки ждать незачем
+		if !s.dialect.Retryable(err) || attempt == txAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * txRetryDelay):
+		}
+	}
+
+	return err
+}
+
+func (s *Storage) runTx(ctx context.Context, level sql.IsolationLevel, fn func(tx *Storage) error) error {
+	sqlTx, err := s.DB.DB().BeginTx(ctx, &sql.TxOptions{Isolation: level})
+	if err != nil {
+		return err
+	}
+
+	// хранилище, все запросы которого выполняются в транзакции
+	tx := *s
+	tx.tx = sqlTx
+
+	if err := fn(&tx); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+
+	return sqlTx.Commit()
+}
@@ -0,0 +1,117 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Голоса хранятся в таблице votes, по одной строке на пользователя и запись.
+// Количества голосов и ранги лежат в самих постах и комментариях
+// и меняются в одной транзакции с голосом
+
+// таблицы постов и комментариев по типу записи
+var targetTables = map[smodel.TargetType]string{
+	smodel.TargetPost:    "posts",
+	smodel.TargetComment: "comments",
+}
+
+func (s *Storage) Vote(ctx context.Context, v smodel.CastVote) (*smodel.VoteSummary, error) {
+	if v.Value < -1 || v.Value > 1 {
+		return nil, errors.New(u.ErrorVoteValue(v.Value))
+	}
+
+	var summary smodel.VoteSummary
+	table := targetTables[v.Target.Type]
+
+	// старый голос читается и заменяется в одной транзакции,
+	// при одновременном голосовании она повторяется
+	err := s.inTx(ctx, sql.LevelSerializable, func(tx *Storage) error {
+		if err := tx.checkUserExists(ctx, v.UserId); err != nil {
+			return err
+		}
+
+		postID, err := tx.targetPost(ctx, v.Target)
+		if err != nil {
+			return err
+		}
+
+		old, err := tx.GetUserVote(ctx, v.UserId, v.Target)
+		if err != nil {
+			return err
+		}
+
+		summary = smodel.VoteSummary{
+			PostID:  postID,
+			Changed: old != v.Value,
+		}
+
+		if !summary.Changed {
+			return tx.withContext(ctx).Table(table).Select("upvotes, downvotes, top_rank, hot_rank").
+				Where("id = ?", v.Target.ID).Scan(&summary.Votes).Error
+		}
+
+		db := tx.withContext(ctx)
+		if err := saveVote(db, v, old); err != nil {
+			return err
+		}
+
+		var row struct {
+			smodel.Votes
+			CreatedAt time.Time
+		}
+		if err := db.Table(table).Select("upvotes, downvotes, created_at").Where("id = ?", v.Target.ID).Scan(&row).Error; err != nil {
+			return err
+		}
+
+		summary.Votes = row.Votes
+		summary.Change(old, v.Value, row.CreatedAt)
+
+		return db.Exec("UPDATE "+table+" SET upvotes = ?, downvotes = ?, top_rank = ?, hot_rank = ? WHERE id = ?",
+			summary.Upvotes, summary.Downvotes, summary.TopRank, summary.HotRank, v.Target.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+// saveVote заменяет старый голос old новым
+func saveVote(db *gorm.DB, v smodel.CastVote, old int) error {
+	where := "user_id = ? AND target_type = ? AND target_id = ?"
+
+	switch {
+	case v.Value == 0:
+		return db.Exec("DELETE FROM votes WHERE "+where, v.UserId, v.Target.Type, v.Target.ID).Error
+	case old == 0:
+		return db.Create(&smodel.Vote{
+			UserID:     v.UserId,
+			TargetType: v.Target.Type,
+			TargetID:   v.Target.ID,
+			Value:      v.Value,
+		}).Error
+	default:
+		return db.Exec("UPDATE votes SET value = ? WHERE "+where, v.Value, v.UserId, v.Target.Type, v.Target.ID).Error
+	}
+}
+
+func (s *Storage) GetUserVote(ctx context.Context, userId uint, target smodel.Target) (int, error) {
+	var vote smodel.Vote
+	err := s.withContext(ctx).
+		Where("user_id = ? AND target_type = ? AND target_id = ?", userId, target.Type, target.ID).
+		First(&vote).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return vote.Value, nil
+}
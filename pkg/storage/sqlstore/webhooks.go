@@ -0,0 +1,199 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	u "github.com/leonideliseev/ozonTestTask/pkg/storage/utils"
+)
+
+// Вебхуки хранятся в таблице webhooks, доставки событий - в webhook_deliveries.
+// Доставки создаются сразу для всех подписанных вебхуков, а отправляет их
+// webhook.Dispatcher, записывая результат каждой попытки
+
+func (s *Storage) CreateWebhook(ctx context.Context, w smodel.CreateWebhook) (*smodel.Webhook, error) {
+	events := make([]string, len(w.Events))
+	for i, event := range w.Events {
+		events[i] = string(event)
+	}
+
+	webhook := smodel.Webhook{
+		URL:       w.URL,
+		Events:    strings.Join(events, ","),
+		Secret:    w.Secret,
+		CreatedAt: time.Now(),
+	}
+	if err := s.withContext(ctx).Create(&webhook).Error; err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (s *Storage) DeleteWebhook(ctx context.Context, id uint) error {
+	return s.inTx(ctx, sql.LevelDefault, func(tx *Storage) error {
+		db := tx.withContext(ctx)
+
+		if err := db.Where("webhook_id = ?", id).Delete(&smodel.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+
+		res := db.Where("id = ?", id).Delete(&smodel.Webhook{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New(u.ErrorWebhookId(id))
+		}
+		return nil
+	})
+}
+
+func (s *Storage) GetWebhooks(ctx context.Context) ([]*smodel.Webhook, error) {
+	webhooks := make([]*smodel.Webhook, 0)
+	if err := s.withContext(ctx).Order("id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (s *Storage) CreateWebhookDeliveries(ctx context.Context, d smodel.CreateDeliveries) ([]*smodel.WebhookDelivery, error) {
+	deliveries := make([]*smodel.WebhookDelivery, 0)
+
+	err := s.inTx(ctx, sql.LevelDefault, func(tx *Storage) error {
+		deliveries = deliveries[:0]
+
+		// копии записей удалённого аккаунта не сохраняются
+		if d.UserId != 0 {
+			var count int
+			if err := tx.withContext(ctx).Model(&smodel.User{}).Where("id = ? AND anonymized_at IS NOT NULL", d.UserId).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+		}
+
+		// доставки этого события уже созданы
+		if d.EventId != 0 {
+			var count int
+			if err := tx.withContext(ctx).Model(&smodel.WebhookDelivery{}).Where("event_id = ?", d.EventId).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+		}
+
+		webhooks, err := tx.GetWebhooks(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, webhook := range webhooks {
+			if !webhook.Subscribed(d.Event) {
+				continue
+			}
+
+			delivery := smodel.WebhookDelivery{
+				WebhookID:     webhook.ID,
+				Event:         d.Event,
+				Payload:       d.Payload,
+				Status:        smodel.DeliveryPending,
+				EventID:       d.EventId,
+				UserID:        d.UserId,
+				NextAttemptAt: d.At,
+				CreatedAt:     d.At,
+			}
+			if err := tx.withContext(ctx).Create(&delivery).Error; err != nil {
+				return err
+			}
+			deliveries = append(deliveries, &delivery)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// ClaimWebhookDeliveries выбирает и откладывает доставки одним запросом.
+// Строки, которые сейчас выбирает другой экземпляр, пропускаются, а если
+// база не умеет их пропускать, запрос ждёт его и заново проверяет условие
+// внешнего UPDATE, поэтому доставку получает только один экземпляр
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]*smodel.WebhookDelivery, error) {
+	deliveries := make([]*smodel.WebhookDelivery, 0)
+	err := s.withContext(ctx).Raw(`UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE status = ? AND next_attempt_at <= ? AND id IN (
+			SELECT id FROM webhook_deliveries WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY id LIMIT ?`+s.dialect.SkipLocked()+`)
+		RETURNING *`,
+		until, smodel.DeliveryPending, now, smodel.DeliveryPending, now, limit).Scan(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING возвращает строки в произвольном порядке
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+func (s *Storage) RecordDeliveryAttempt(ctx context.Context, a smodel.DeliveryAttempt) (*smodel.WebhookDelivery, error) {
+	columns := map[string]interface{}{
+		"status":          a.Status,
+		"attempts":        gorm.Expr("attempts + 1"),
+		"next_attempt_at": a.NextAttemptAt,
+		"response_status": a.ResponseStatus,
+		"last_error":      a.Error,
+	}
+	if a.Status == smodel.DeliveryDelivered {
+		columns["delivered_at"] = a.At
+	}
+
+	db := s.withContext(ctx)
+	res := db.Model(&smodel.WebhookDelivery{}).Where("id = ?", a.DeliveryId).UpdateColumns(columns)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.New(u.ErrorDeliveryId(a.DeliveryId))
+	}
+
+	var delivery smodel.WebhookDelivery
+	if err := db.First(&delivery, a.DeliveryId).Error; err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func (s *Storage) GetWebhookDeliveries(ctx context.Context, limit, offset int, filter smodel.DeliveryFilter) (*smodel.WebhookDeliveryPage, error) {
+	page := smodel.WebhookDeliveryPage{Deliveries: make([]*smodel.WebhookDelivery, 0)}
+	db := s.withContext(ctx).Model(&smodel.WebhookDelivery{})
+
+	if filter.WebhookId != nil {
+		db = db.Where("webhook_id = ?", *filter.WebhookId)
+	}
+	if filter.Status != nil {
+		db = db.Where("status = ?", *filter.Status)
+	}
+
+	if err := db.Count(&page.TotalCount).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Order("id DESC").Limit(limit).Offset(offset).Find(&page.Deliveries).Error; err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
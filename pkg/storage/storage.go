@@ -1,15 +1,88 @@
 package storage
 
 import (
+	"context"
+	"time"
+
 	"github.com/leonideliseev/ozonTestTask/pkg/model"
 )
 
 // интерфейс для памяти
+// ctx передаётся до запросов в хранилище: при отмене запроса или закрытии
+// соединения операция прерывается
 type Storage interface {
-	CreatePost(p smodel.CreatePost) (*smodel.Post, error)
-	CreateComment(c smodel.CreateComment) (*smodel.Comment, error)
-	CreateUser(u smodel.CreateUser) (*smodel.User, error)
-	GetPosts(limit, offset int) (*smodel.PostPage, error)
-	GetPost(limit, offset int, id uint) (*smodel.Post, error)
-	GetComments(limit, offset int, id uint) (*smodel.Comment, error)
+	CreatePost(ctx context.Context, p smodel.CreatePost) (*smodel.Post, error)
+	CreateComment(ctx context.Context, c smodel.CreateComment) (*smodel.Comment, error)
+	CreateUser(ctx context.Context, u smodel.CreateUser) (*smodel.User, error)
+	GetPosts(ctx context.Context, limit, offset int, sort smodel.PostSort, filter smodel.PostFilter) (*smodel.PostPage, error)
+	// sort - порядок комментариев на каждом уровне дерева
+	GetPost(ctx context.Context, limit, offset int, sort smodel.CommentSort, id uint) (*smodel.Post, error)
+	GetComments(ctx context.Context, limit, offset int, sort smodel.CommentSort, id uint) (*smodel.Comment, error)
+	GetUser(ctx context.Context, id uint) (*smodel.User, error)
+	CreateBoard(ctx context.Context, b smodel.CreateBoard) (*smodel.Board, error)
+	GetBoard(ctx context.Context, id uint) (*smodel.Board, error)
+	GetBoards(ctx context.Context, limit, offset int) (*smodel.BoardPage, error)
+	// теги по убыванию количества постов с ними
+	GetTags(ctx context.Context, limit, offset int) ([]*smodel.TagCount, error)
+	GetUserByUsername(ctx context.Context, username string) (*smodel.User, error)
+	// посты и комментарии пользователя, сначала новые. Удалённые посты, а также скрытые,
+	// удалённые комментарии и комментарии под удалёнными постами - только с withDeleted
+	GetUserPosts(ctx context.Context, limit, offset int, id uint, withDeleted bool) (*smodel.PostPage, error)
+	GetUserComments(ctx context.Context, limit, offset int, id uint, withDeleted bool) (*smodel.CommPage, error)
+	// полнотекстовый поиск, сначала наиболее подходящие
+	Search(ctx context.Context, q smodel.Search) (*smodel.SearchPage, error)
+	// ставят и снимают реакцию, повтор ничего не меняет
+	React(ctx context.Context, r smodel.React) (*smodel.ReactionSummary, error)
+	Unreact(ctx context.Context, r smodel.React) (*smodel.ReactionSummary, error)
+	// количества реакций в порядке их имён
+	GetReactions(ctx context.Context, target smodel.Target) ([]*smodel.ReactionCount, error)
+	// реакции пользователя в порядке имён
+	GetUserReactions(ctx context.Context, userId uint, target smodel.Target) ([]string, error)
+	// ставит, меняет или снимает (Value 0) голос пользователя
+	Vote(ctx context.Context, v smodel.CastVote) (*smodel.VoteSummary, error)
+	// голос пользователя: 1, -1 или 0, если голоса нет
+	GetUserVote(ctx context.Context, userId uint, target smodel.Target) (int, error)
+	// жалоба пользователя или запись, отправленная в очередь фильтром модерации
+	CreateReport(ctx context.Context, r smodel.CreateReport) (*smodel.Report, error)
+	// жалобы со статусом, сначала старые
+	GetReports(ctx context.Context, limit, offset int, status smodel.ReportStatus) (*smodel.ReportPage, error)
+	// применяет решение модератора к записи и закрывает все открытые жалобы на неё
+	ResolveReport(ctx context.Context, r smodel.ResolveReport) (*smodel.Report, error)
+	// меняет интервал медленного режима поста
+	SetSlowMode(ctx context.Context, s smodel.SetSlowMode) (*smodel.Post, error)
+	// меняют текст записи её автором и сохраняют новую версию
+	EditPost(ctx context.Context, e smodel.EditPost) (*smodel.Post, error)
+	EditComment(ctx context.Context, e smodel.EditComment) (*smodel.Comment, error)
+	// версии записи по возрастанию номера
+	GetRevisions(ctx context.Context, target smodel.Target, limit, offset int) (*smodel.RevisionPage, error)
+	GetRevision(ctx context.Context, target smodel.Target, number int) (*smodel.Revision, error)
+	// помечает запись удалённой, удалить чужую запись может только модератор
+	SoftDelete(ctx context.Context, d smodel.SoftDelete) error
+	// снимает пометку об удалении, если запись удалена позже r.DeletedAfter
+	Restore(ctx context.Context, r smodel.Restore) error
+	// стирает текст и версии записей, удалённых раньше before. Записи остаются
+	// заглушками, чтобы не терялись ответы на них. Возвращает количество стёртых
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
+	// удаляет аккаунт: пользователь становится анонимным и больше не может
+	// создавать записи, а при a.RemoveContent его записи сразу очищаются
+	AnonymizeUser(ctx context.Context, a smodel.AnonymizeUser) (*smodel.User, error)
+	// уведомления пользователя, сначала новые
+	GetNotifications(ctx context.Context, userId uint, limit, offset int, unreadOnly bool) (*smodel.NotificationPage, error)
+	// отмечает прочитанными уведомления пользователя с ids, nil - все.
+	// Возвращает количество отмеченных
+	MarkNotificationsRead(ctx context.Context, userId uint, ids []uint) (int, error)
+	CreateWebhook(ctx context.Context, w smodel.CreateWebhook) (*smodel.Webhook, error)
+	// удаляет вебхук вместе с его доставками
+	DeleteWebhook(ctx context.Context, id uint) error
+	// вебхуки в порядке создания
+	GetWebhooks(ctx context.Context) ([]*smodel.Webhook, error)
+	// создаёт доставки события всем подписанным на него вебхукам
+	CreateWebhookDeliveries(ctx context.Context, d smodel.CreateDeliveries) ([]*smodel.WebhookDelivery, error)
+	// выбирает доставки PENDING, время попытки которых наступило к now, в порядке создания
+	// и откладывает их до until, чтобы их не выбрали другие экземпляры приложения
+	ClaimWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]*smodel.WebhookDelivery, error)
+	// сохраняет результат попытки доставки
+	RecordDeliveryAttempt(ctx context.Context, a smodel.DeliveryAttempt) (*smodel.WebhookDelivery, error)
+	// доставки, сначала новые
+	GetWebhookDeliveries(ctx context.Context, limit, offset int, filter smodel.DeliveryFilter) (*smodel.WebhookDeliveryPage, error)
 }
\ No newline at end of file
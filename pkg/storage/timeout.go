@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// Timeouts задаёт таймауты операций хранилища.
+// Methods содержит таймауты отдельных методов Storage по их имени,
+// для остальных методов используется Default. Нулевой таймаут означает его отсутствие
+type Timeouts struct {
+	Default time.Duration
+	Methods map[string]time.Duration
+}
+
+// ParseTimeouts разбирает таймаут по умолчанию и список таймаутов методов
+// вида "GetPost=2s,CreateComment=500ms"
+func ParseTimeouts(def, methods string) (Timeouts, error) {
+	var t Timeouts
+	var err error
+
+	t.Default, err = time.ParseDuration(def)
+	if err != nil {
+		return t, fmt.Errorf("invalid default timeout %q: %w", def, err)
+	}
+
+	t.Methods = make(map[string]time.Duration)
+	for _, pair := range strings.Split(methods, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		method, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return t, fmt.Errorf("invalid method timeout %q, expected Method=duration", pair)
+		}
+
+		t.Methods[method], err = time.ParseDuration(value)
+		if err != nil {
+			return t, fmt.Errorf("invalid timeout for %s: %w", method, err)
+		}
+	}
+
+	return t, nil
+}
+
+// Context возвращает контекст для вызова method с его таймаутом
+func (t Timeouts) Context(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	timeout, ok := t.Methods[method]
+	if !ok {
+		timeout = t.Default
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// WithTimeouts оборачивает хранилище так, что каждая операция
+// выполняется не дольше своего таймаута
+func WithTimeouts(s Storage, t Timeouts) Storage {
+	return &timeoutStorage{storage: s, timeouts: t}
+}
+
+type timeoutStorage struct {
+	storage  Storage
+	timeouts Timeouts
+}
+
+func (s *timeoutStorage) CreatePost(ctx context.Context, p smodel.CreatePost) (*smodel.Post, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "CreatePost")
+	defer cancel()
+	return s.storage.CreatePost(ctx, p)
+}
+
+func (s *timeoutStorage) CreateComment(ctx context.Context, c smodel.CreateComment) (*smodel.Comment, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "CreateComment")
+	defer cancel()
+	return s.storage.CreateComment(ctx, c)
+}
+
+func (s *timeoutStorage) CreateUser(ctx context.Context, u smodel.CreateUser) (*smodel.User, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "CreateUser")
+	defer cancel()
+	return s.storage.CreateUser(ctx, u)
+}
+
+func (s *timeoutStorage) GetPosts(ctx context.Context, limit, offset int, sort smodel.PostSort, filter smodel.PostFilter) (*smodel.PostPage, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetPosts")
+	defer cancel()
+	return s.storage.GetPosts(ctx, limit, offset, sort, filter)
+}
+
+func (s *timeoutStorage) GetPost(ctx context.Context, limit, offset int, sort smodel.CommentSort, id uint) (*smodel.Post, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetPost")
+	defer cancel()
+	return s.storage.GetPost(ctx, limit, offset, sort, id)
+}
+
+func (s *timeoutStorage) GetComments(ctx context.Context, limit, offset int, sort smodel.CommentSort, id uint) (*smodel.Comment, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetComments")
+	defer cancel()
+	return s.storage.GetComments(ctx, limit, offset, sort, id)
+}
+
+func (s *timeoutStorage) GetUser(ctx context.Context, id uint) (*smodel.User, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetUser")
+	defer cancel()
+	return s.storage.GetUser(ctx, id)
+}
+
+func (s *timeoutStorage) GetUserByUsername(ctx context.Context, username string) (*smodel.User, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetUserByUsername")
+	defer cancel()
+	return s.storage.GetUserByUsername(ctx, username)
+}
+
+func (s *timeoutStorage) GetUserPosts(ctx context.Context, limit, offset int, id uint, withDeleted bool) (*smodel.PostPage, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetUserPosts")
+	defer cancel()
+	return s.storage.GetUserPosts(ctx, limit, offset, id, withDeleted)
+}
+
+func (s *timeoutStorage) GetUserComments(ctx context.Context, limit, offset int, id uint, withDeleted bool) (*smodel.CommPage, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetUserComments")
+	defer cancel()
+	return s.storage.GetUserComments(ctx, limit, offset, id, withDeleted)
+}
+
+func (s *timeoutStorage) Search(ctx context.Context, q smodel.Search) (*smodel.SearchPage, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "Search")
+	defer cancel()
+	return s.storage.Search(ctx, q)
+}
+
+func (s *timeoutStorage) GetTags(ctx context.Context, limit, offset int) ([]*smodel.TagCount, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetTags")
+	defer cancel()
+	return s.storage.GetTags(ctx, limit, offset)
+}
+
+func (s *timeoutStorage) CreateBoard(ctx context.Context, b smodel.CreateBoard) (*smodel.Board, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "CreateBoard")
+	defer cancel()
+	return s.storage.CreateBoard(ctx, b)
+}
+
+func (s *timeoutStorage) GetBoard(ctx context.Context, id uint) (*smodel.Board, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetBoard")
+	defer cancel()
+	return s.storage.GetBoard(ctx, id)
+}
+
+func (s *timeoutStorage) GetBoards(ctx context.Context, limit, offset int) (*smodel.BoardPage, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetBoards")
+	defer cancel()
+	return s.storage.GetBoards(ctx, limit, offset)
+}
+
+func (s *timeoutStorage) React(ctx context.Context, r smodel.React) (*smodel.ReactionSummary, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "React")
+	defer cancel()
+	return s.storage.React(ctx, r)
+}
+
+func (s *timeoutStorage) Unreact(ctx context.Context, r smodel.React) (*smodel.ReactionSummary, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "Unreact")
+	defer cancel()
+	return s.storage.Unreact(ctx, r)
+}
+
+func (s *timeoutStorage) GetReactions(ctx context.Context, target smodel.Target) ([]*smodel.ReactionCount, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetReactions")
+	defer cancel()
+	return s.storage.GetReactions(ctx, target)
+}
+
+func (s *timeoutStorage) GetUserReactions(ctx context.Context, userId uint, target smodel.Target) ([]string, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetUserReactions")
+	defer cancel()
+	return s.storage.GetUserReactions(ctx, userId, target)
+}
+
+func (s *timeoutStorage) Vote(ctx context.Context, v smodel.CastVote) (*smodel.VoteSummary, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "Vote")
+	defer cancel()
+	return s.storage.Vote(ctx, v)
+}
+
+func (s *timeoutStorage) GetUserVote(ctx context.Context, userId uint, target smodel.Target) (int, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetUserVote")
+	defer cancel()
+	return s.storage.GetUserVote(ctx, userId, target)
+}
+
+func (s *timeoutStorage) CreateReport(ctx context.Context, r smodel.CreateReport) (*smodel.Report, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "CreateReport")
+	defer cancel()
+	return s.storage.CreateReport(ctx, r)
+}
+
+func (s *timeoutStorage) GetReports(ctx context.Context, limit, offset int, status smodel.ReportStatus) (*smodel.ReportPage, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetReports")
+	defer cancel()
+	return s.storage.GetReports(ctx, limit, offset, status)
+}
+
+func (s *timeoutStorage) ResolveReport(ctx context.Context, r smodel.ResolveReport) (*smodel.Report, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "ResolveReport")
+	defer cancel()
+	return s.storage.ResolveReport(ctx, r)
+}
+
+func (s *timeoutStorage) SetSlowMode(ctx context.Context, m smodel.SetSlowMode) (*smodel.Post, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "SetSlowMode")
+	defer cancel()
+	return s.storage.SetSlowMode(ctx, m)
+}
+
+func (s *timeoutStorage) EditPost(ctx context.Context, e smodel.EditPost) (*smodel.Post, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "EditPost")
+	defer cancel()
+	return s.storage.EditPost(ctx, e)
+}
+
+func (s *timeoutStorage) EditComment(ctx context.Context, e smodel.EditComment) (*smodel.Comment, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "EditComment")
+	defer cancel()
+	return s.storage.EditComment(ctx, e)
+}
+
+func (s *timeoutStorage) GetRevisions(ctx context.Context, target smodel.Target, limit, offset int) (*smodel.RevisionPage, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetRevisions")
+	defer cancel()
+	return s.storage.GetRevisions(ctx, target, limit, offset)
+}
+
+func (s *timeoutStorage) GetRevision(ctx context.Context, target smodel.Target, number int) (*smodel.Revision, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetRevision")
+	defer cancel()
+	return s.storage.GetRevision(ctx, target, number)
+}
+
+func (s *timeoutStorage) SoftDelete(ctx context.Context, d smodel.SoftDelete) error {
+	ctx, cancel := s.timeouts.Context(ctx, "SoftDelete")
+	defer cancel()
+	return s.storage.SoftDelete(ctx, d)
+}
+
+func (s *timeoutStorage) Restore(ctx context.Context, r smodel.Restore) error {
+	ctx, cancel := s.timeouts.Context(ctx, "Restore")
+	defer cancel()
+	return s.storage.Restore(ctx, r)
+}
+
+func (s *timeoutStorage) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "PurgeDeleted")
+	defer cancel()
+	return s.storage.PurgeDeleted(ctx, before)
+}
+
+func (s *timeoutStorage) AnonymizeUser(ctx context.Context, a smodel.AnonymizeUser) (*smodel.User, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "AnonymizeUser")
+	defer cancel()
+	return s.storage.AnonymizeUser(ctx, a)
+}
+
+func (s *timeoutStorage) GetNotifications(ctx context.Context, userId uint, limit, offset int, unreadOnly bool) (*smodel.NotificationPage, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetNotifications")
+	defer cancel()
+	return s.storage.GetNotifications(ctx, userId, limit, offset, unreadOnly)
+}
+
+func (s *timeoutStorage) MarkNotificationsRead(ctx context.Context, userId uint, ids []uint) (int, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "MarkNotificationsRead")
+	defer cancel()
+	return s.storage.MarkNotificationsRead(ctx, userId, ids)
+}
+
+func (s *timeoutStorage) CreateWebhook(ctx context.Context, w smodel.CreateWebhook) (*smodel.Webhook, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "CreateWebhook")
+	defer cancel()
+	return s.storage.CreateWebhook(ctx, w)
+}
+
+func (s *timeoutStorage) DeleteWebhook(ctx context.Context, id uint) error {
+	ctx, cancel := s.timeouts.Context(ctx, "DeleteWebhook")
+	defer cancel()
+	return s.storage.DeleteWebhook(ctx, id)
+}
+
+func (s *timeoutStorage) GetWebhooks(ctx context.Context) ([]*smodel.Webhook, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetWebhooks")
+	defer cancel()
+	return s.storage.GetWebhooks(ctx)
+}
+
+func (s *timeoutStorage) CreateWebhookDeliveries(ctx context.Context, d smodel.CreateDeliveries) ([]*smodel.WebhookDelivery, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "CreateWebhookDeliveries")
+	defer cancel()
+	return s.storage.CreateWebhookDeliveries(ctx, d)
+}
+
+func (s *timeoutStorage) ClaimWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]*smodel.WebhookDelivery, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "ClaimWebhookDeliveries")
+	defer cancel()
+	return s.storage.ClaimWebhookDeliveries(ctx, now, until, limit)
+}
+
+func (s *timeoutStorage) RecordDeliveryAttempt(ctx context.Context, a smodel.DeliveryAttempt) (*smodel.WebhookDelivery, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "RecordDeliveryAttempt")
+	defer cancel()
+	return s.storage.RecordDeliveryAttempt(ctx, a)
+}
+
+func (s *timeoutStorage) GetWebhookDeliveries(ctx context.Context, limit, offset int, filter smodel.DeliveryFilter) (*smodel.WebhookDeliveryPage, error) {
+	ctx, cancel := s.timeouts.Context(ctx, "GetWebhookDeliveries")
+	defer cancel()
+	return s.storage.GetWebhookDeliveries(ctx, limit, offset, filter)
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// CheckEdit проверяет, что запись может изменить пользователь editorId
+func CheckEdit(target smodel.Target, authorId uint, status smodel.ContentStatus, removedAt *time.Time, editorId uint) error {
+	if authorId != editorId {
+		return errors.New(ErrorNotAuthor(editorId, target))
+	}
+	if status == smodel.ContentDeleted {
+		return errors.New(ErrorEditDeleted(target))
+	}
+	if removedAt != nil {
+		return errors.New(ErrorRemoved(target))
+	}
+	return nil
+}
+
+// MergeEdit возвращает текст поста после изменения e. Поле, которое не меняется,
+// остаётся текущим, а если оно уже не то, с которым текст проверила модерация,
+// то возвращается ошибка
+func MergeEdit(target smodel.Target, title, content string, e smodel.EditPost) (string, string, error) {
+	if e.Title != nil {
+		title = *e.Title
+	} else if e.BaseTitle != nil && *e.BaseTitle != title {
+		return "", "", errors.New(ErrorEditConflict(target))
+	}
+
+	if e.Content != nil {
+		content = *e.Content
+	} else if e.BaseContent != nil && *e.BaseContent != content {
+		return "", "", errors.New(ErrorEditConflict(target))
+	}
+
+	return title, content, nil
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize разбивает текст на слова в нижнем регистре,
+// словом считается последовательность букв и цифр
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// MaxMentions - сколько упоминаний комментария превращаются в уведомления
+const MaxMentions = 10
+
+// Mentions возвращает username из упоминаний @username в тексте без повторов,
+// в порядке появления и не больше MaxMentions. Username - буквы, цифры и _.-,
+// точка в конце не считается. @ после буквы или цифры, как в почте, не упоминание
+func Mentions(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	runes := []rune(text)
+
+	for i := 0; i < len(runes) && len(names) < MaxMentions; i++ {
+		if runes[i] != '@' || (i > 0 && isNameRune(runes[i-1])) {
+			continue
+		}
+
+		j := i + 1
+		for j < len(runes) && isNameRune(runes[j]) {
+			j++
+		}
+		name := strings.TrimRight(string(runes[i+1:j]), ".")
+		i = j - 1
+
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
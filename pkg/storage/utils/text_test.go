@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	many := make([]string, 0, MaxMentions+2)
+	for i := 0; i < MaxMentions+2; i++ {
+		many = append(many, "@user"+strconv.Itoa(i))
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"none", "no mentions here", nil},
+		{"single", "hi @alice", []string{"alice"}},
+		{"punctuation after", "@alice, @bob! (@carol) @dave?", []string{"alice", "bob", "carol", "dave"}},
+		{"trailing dot", "thanks @alice.", []string{"alice"}},
+		{"dot inside", "@john.doe wrote", []string{"john.doe"}},
+		{"name characters", "@a_b-c.d9", []string{"a_b-c.d9"}},
+		{"duplicates", "@alice @bob @alice", []string{"alice", "bob"}},
+		{"email", "write to x@alice or alice@example.com", nil},
+		{"after punctuation", "(@alice)", []string{"alice"}},
+		{"bare at", "@ @. @!", nil},
+		{"unicode", "привет @мария", []string{"мария"}},
+		// пользователи не проверяются, несуществующих отбрасывает хранилище
+		{"unknown", "@nobody", []string{"nobody"}},
+		{"limit", strings.Join(many, " "), []string{"user0", "user1", "user2", "user3", "user4", "user5", "user6", "user7", "user8", "user9"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -35,6 +35,14 @@ func ErrorUserId(id uint) string {
 	return fmt.Sprintf("author with id = %d not found", id)
 }
 
+func ErrorUserDeleted(id uint) string {
+	return fmt.Sprintf("user with id = %d is deleted", id)
+}
+
+func ErrorUsername(username string) string {
+	return fmt.Sprintf("author with username = %q not found", username)
+}
+
 func ErrorPostId(id uint) string {
 	return fmt.Sprintf("post with id = %d not found", id)
 }
@@ -43,6 +51,46 @@ func ErrorCommId(id uint) string {
 	return fmt.Sprintf("comment with id = %d not found", id)
 }
 
+func ErrorPostSort(sort string) string {
+	return fmt.Sprintf("unknown posts sort %q", sort)
+}
+
+func ErrorCommentSort(sort string) string {
+	return fmt.Sprintf("unknown comments sort %q", sort)
+}
+
+func ErrorVoteValue(value int) string {
+	return fmt.Sprintf("unknown vote value %d, expected 1, -1 or 0", value)
+}
+
+func ErrorReportId(id uint) string {
+	return fmt.Sprintf("report with id = %d not found", id)
+}
+
+func ErrorReportResolved(id uint) string {
+	return fmt.Sprintf("report with id = %d is already resolved", id)
+}
+
+func ErrorReportStatus(status string) string {
+	return fmt.Sprintf("unknown report status %q", status)
+}
+
+func ErrorModerationAction(action string) string {
+	return fmt.Sprintf("unknown moderation action %q", action)
+}
+
+func ErrorBoardId(id uint) string {
+	return fmt.Sprintf("board with id = %d not found", id)
+}
+
+func ErrorPosterNotAllowed(userId, boardId uint) string {
+	return fmt.Sprintf("author with id = %d can't create posts on board with id = %d", userId, boardId)
+}
+
+func ErrorCommentTooLong(length, max int) string {
+	return fmt.Sprintf("very long comment, simvol lenght = %d > %d", length, max)
+}
+
 func ErrorCommDisable() string {
 	return "comment not enable for this post"
 }
@@ -54,3 +102,47 @@ func ErrorParentIdForReply(id uint) string {
 func ErorrMismatchPostId(replyPostId, commPostId uint) string {
 	return fmt.Sprintf("reply post id = %d doesn't match the comment post id = %d being replied to", replyPostId, commPostId)
 }
+
+func ErrorSlowMode(seconds int) string {
+	return fmt.Sprintf("slow mode: one comment per %d seconds on this post", seconds)
+}
+
+func ErrorIdempotencyKeyReused(key string) string {
+	return fmt.Sprintf("idempotency key %q was already used with a different request", key)
+}
+
+func ErrorWebhookId(id uint) string {
+	return fmt.Sprintf("webhook with id = %d not found", id)
+}
+
+func ErrorDeliveryId(id uint) string {
+	return fmt.Sprintf("webhook delivery with id = %d not found", id)
+}
+
+func ErrorNotAuthor(userId uint, target smodel.Target) string {
+	return fmt.Sprintf("user with id = %d is not the author of %s with id = %d", userId, target.Type, target.ID)
+}
+
+func ErrorEditDeleted(target smodel.Target) string {
+	return fmt.Sprintf("%s with id = %d was deleted by moderator and can't be edited", target.Type, target.ID)
+}
+
+func ErrorEditConflict(target smodel.Target) string {
+	return fmt.Sprintf("%s with id = %d was changed by another request, retry the edit", target.Type, target.ID)
+}
+
+func ErrorRemoved(target smodel.Target) string {
+	return fmt.Sprintf("%s with id = %d is deleted", target.Type, target.ID)
+}
+
+func ErrorNotRemoved(target smodel.Target) string {
+	return fmt.Sprintf("%s with id = %d is not deleted", target.Type, target.ID)
+}
+
+func ErrorRestoreExpired(target smodel.Target) string {
+	return fmt.Sprintf("%s with id = %d was deleted too long ago and can't be restored", target.Type, target.ID)
+}
+
+func ErrorRevision(target smodel.Target, number int) string {
+	return fmt.Sprintf("revision %d of %s with id = %d not found", number, target.Type, target.ID)
+}
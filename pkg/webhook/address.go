@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Адрес вебхука задаёт модератор, а запрос к нему делает сервер, поэтому
+// вебхук мог бы обращаться к сервисам внутренней сети сервера (SSRF).
+// Адреса loopback, link-local, частных и служебных сетей запрещены.
+// Они проверяются при создании вебхука по всем адресам его хоста и ещё раз
+// при каждом подключении, уже после DNS, чтобы хост нельзя было перенаправить
+// во внутреннюю сеть после проверки
+
+// служебные сети, которых нет среди проверок net.IP
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+	// NAT64, ведёт к IPv4-адресу в последних байтах
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// forbiddenIP сообщает, что по адресу ip вебхук отправлять нельзя
+func forbiddenIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL проверяет, что raw - http или https адрес, все адреса хоста
+// которого не во внутренней сети
+func CheckURL(ctx context.Context, raw string) error {
+	return checkURL(ctx, raw, false)
+}
+
+// CheckURL как пакетная CheckURL, но с AllowPrivateNetworks адреса не проверяются
+func (d *Dispatcher) CheckURL(ctx context.Context, raw string) error {
+	return checkURL(ctx, raw, d.cfg.AllowPrivateNetworks)
+}
+
+func checkURL(ctx context.Context, raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("invalid webhook url %q, expected http or https url", raw)
+	}
+	if allowPrivate {
+		return nil
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if forbiddenIP(ip) {
+			return fmt.Errorf("webhook url %q points to a private network", raw)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed resolve webhook host %q: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if forbiddenIP(addr.IP) {
+			return fmt.Errorf("webhook url %q points to a private network", raw)
+		}
+	}
+	return nil
+}
+
+// newClient возвращает клиент, который подключается только к разрешённым адресам
+func newClient(cfg Config) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.Timeout, KeepAlive: 30 * time.Second}
+	if !cfg.AllowPrivateNetworks {
+		// address - уже найденный через DNS адрес, к которому идёт подключение
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || forbiddenIP(ip) {
+				return fmt.Errorf("webhook address %s is in a private network", host)
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// через прокси подключение шло бы к прокси, а не к получателю
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	// подключения после перенаправлений тоже проходят через dialer
+	return &http.Client{Timeout: cfg.Timeout, Transport: transport}
+}
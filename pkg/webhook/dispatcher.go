@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/sirupsen/logrus"
+)
+
+// сколько символов ошибки попытки сохраняется в доставке
+const maxErrorLength = 500
+
+// Store - методы хранилища, которые нужны для доставки, их реализует storage.Storage
+type Store interface {
+	GetWebhooks(ctx context.Context) ([]*smodel.Webhook, error)
+	CreateWebhookDeliveries(ctx context.Context, d smodel.CreateDeliveries) ([]*smodel.WebhookDelivery, error)
+	ClaimWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]*smodel.WebhookDelivery, error)
+	RecordDeliveryAttempt(ctx context.Context, a smodel.DeliveryAttempt) (*smodel.WebhookDelivery, error)
+}
+
+// Dispatcher сохраняет события и отправляет их вебхукам в фоне (Run).
+// Доставки хранятся в хранилище, поэтому после перезапуска отправка продолжается
+type Dispatcher struct {
+	store  Store
+	cfg    Config
+	client *http.Client
+	// будит Run после новых событий, не дожидаясь PollInterval
+	wake chan struct{}
+}
+
+func NewDispatcher(store Store, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		cfg:    cfg,
+		client: newClient(cfg),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Publish сохраняет событие о записи пользователя userId доставками
+// всем подписанным на него вебхукам
+func (d *Dispatcher) Publish(ctx context.Context, event smodel.WebhookEvent, userId uint, data interface{}) error {
+	return d.PublishOnce(ctx, 0, event, userId, data)
+}
+
+// PublishOnce как Publish, но доставки события outbox eventId создаются
+// только при первом вызове, повтор после сбоя ничего не делает
+func (d *Dispatcher) PublishOnce(ctx context.Context, eventId uint, event smodel.WebhookEvent, userId uint, data interface{}) error {
+	now := time.Now()
+	body, err := json.Marshal(Payload{Event: event, CreatedAt: now, Data: data})
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := d.store.CreateWebhookDeliveries(ctx, smodel.CreateDeliveries{
+		Event:   event,
+		Payload: string(body),
+		At:      now,
+		EventId: eventId,
+		UserId:  userId,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(deliveries) > 0 {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run отправляет доставки, пока не завершится ctx
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Flush(ctx); err != nil && ctx.Err() == nil {
+			logrus.Errorf("failed send webhooks: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// Flush отправляет все доставки, время попытки которых наступило.
+// Доставки выбираются на время Lease, поэтому несколько экземпляров
+// приложения не отправляют одну доставку одновременно
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	for {
+		now := time.Now()
+		due, err := d.store.ClaimWebhookDeliveries(ctx, now, now.Add(d.cfg.Lease()), d.cfg.BatchSize)
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		list, err := d.store.GetWebhooks(ctx)
+		if err != nil {
+			return err
+		}
+		webhooks := make(map[uint]*smodel.Webhook, len(list))
+		for _, webhook := range list {
+			webhooks[webhook.ID] = webhook
+		}
+
+		errs := make([]error, len(due))
+		var wg sync.WaitGroup
+		for i, delivery := range due {
+			// вебхук удалён вместе с доставками после выборки
+			webhook, ok := webhooks[delivery.WebhookID]
+			if !ok {
+				continue
+			}
+
+			wg.Add(1)
+			go func(i int, webhook *smodel.Webhook, delivery *smodel.WebhookDelivery) {
+				defer wg.Done()
+				errs[i] = d.deliver(ctx, webhook, delivery)
+			}(i, webhook, delivery)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		if len(due) < d.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// deliver делает одну попытку доставки и сохраняет её результат
+func (d *Dispatcher) deliver(ctx context.Context, webhook *smodel.Webhook, delivery *smodel.WebhookDelivery) error {
+	status, err := d.send(ctx, webhook, delivery)
+	if ctx.Err() != nil {
+		// попытка прервана остановкой, а не получателем
+		return ctx.Err()
+	}
+
+	now := time.Now()
+	attempt := smodel.DeliveryAttempt{
+		DeliveryId:     delivery.ID,
+		Status:         smodel.DeliveryDelivered,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		ResponseStatus: status,
+		At:             now,
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+		if runes := []rune(attempt.Error); len(runes) > maxErrorLength {
+			attempt.Error = string(runes[:maxErrorLength])
+		}
+
+		attempts := delivery.Attempts + 1
+		if attempts >= d.cfg.MaxAttempts {
+			attempt.Status = smodel.DeliveryDead
+		} else {
+			attempt.Status = smodel.DeliveryPending
+			attempt.NextAttemptAt = now.Add(d.cfg.Backoff(attempts))
+		}
+	}
+
+	_, err = d.store.RecordDeliveryAttempt(ctx, attempt)
+	return err
+}
+
+// send отправляет запрос и возвращает код ответа, 0 - ответа не было.
+// Успешная доставка - ответ 2xx
+func (d *Dispatcher) send(ctx context.Context, webhook *smodel.Webhook, delivery *smodel.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(delivery.Event))
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// тело ответа не нужно, но дочитывается, чтобы соединение переиспользовалось
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
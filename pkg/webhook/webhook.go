@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+)
+
+// Вебхуки отправляют события о новых постах и комментариях на внешние адреса.
+// Событие сохраняется в хранилище доставкой для каждого подписанного вебхука,
+// Dispatcher отправляет доставки POST-запросом с JSON телом и повторяет
+// неудачные попытки с экспоненциальной задержкой. Когда попытки заканчиваются,
+// доставка переходит в состояние DEAD и больше не отправляется
+
+// заголовки запроса вебхука
+const (
+	HeaderEvent    = "X-Webhook-Event"
+	HeaderDelivery = "X-Webhook-Delivery"
+	// unix-время отправки в секундах, входит в подпись
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// "sha256=" и HMAC-SHA256 в hex от "timestamp.body" с секретом вебхука
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload - тело запроса вебхука
+type Payload struct {
+	Event     smodel.WebhookEvent `json:"event"`
+	CreatedAt time.Time           `json:"createdAt"`
+	// пост или комментарий в том же виде, что и в GraphQL
+	Data interface{} `json:"data"`
+}
+
+// Sign возвращает значение заголовка подписи тела запроса
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись запроса на стороне получателя.
+// Чтобы отбросить повторно отправленные чужие запросы, получателю
+// стоит также проверять, что timestamp недавний
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature))
+}
+
+// Config - настройки отправки
+type Config struct {
+	// сколько всего попыток, после последней доставка становится DEAD
+	MaxAttempts int
+	// задержка после первой неудачной попытки, дальше удваивается до MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// таймаут одного запроса
+	Timeout time.Duration
+	// как часто проверяются доставки, время повтора которых наступило
+	PollInterval time.Duration
+	// сколько доставок отправляется одновременно
+	BatchSize int
+	// разрешить адреса внутренней сети, для разработки и тестов
+	AllowPrivateNetworks bool
+}
+
+// DefaultConfig - 8 попыток в течение примерно 20 минут
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:  8,
+		BaseDelay:    10 * time.Second,
+		MaxDelay:     time.Hour,
+		Timeout:      10 * time.Second,
+		PollInterval: time.Second,
+		BatchSize:    20,
+	}
+}
+
+// Lease - на сколько выбранная доставка скрывается от других экземпляров
+// приложения. За это время попытка должна закончиться, иначе доставка
+// будет отправлена ещё раз
+func (c Config) Lease() time.Duration {
+	return c.Timeout + leaseMargin
+}
+
+// запас на запись результата попытки
+const leaseMargin = time.Minute
+
+// Backoff возвращает задержку перед следующей попыткой после attempts неудачных
+func (c Config) Backoff(attempts int) time.Duration {
+	delay := c.BaseDelay
+	for i := 1; i < attempts && delay < c.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	return delay
+}
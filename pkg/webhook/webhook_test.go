@@ -0,0 +1,250 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leonideliseev/ozonTestTask/pkg/model"
+	"github.com/leonideliseev/ozonTestTask/pkg/storage/in_memory"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"POST_CREATED"}`)
+	signature := Sign("secret", 1700000000, body)
+
+	if !Verify("secret", "1700000000", body, signature) {
+		t.Error("expected valid signature", signature)
+	}
+	if Verify("other", "1700000000", body, signature) {
+		t.Error("expected invalid signature for other secret")
+	}
+	if Verify("secret", "1700000001", body, signature) {
+		t.Error("expected invalid signature for other timestamp")
+	}
+	if Verify("secret", "1700000000", []byte(`{"event":"COMMENT_CREATED"}`), signature) {
+		t.Error("expected invalid signature for other body")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := Config{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 100: 10 * time.Second} {
+		if got := cfg.Backoff(attempts); got != want {
+			t.Errorf("expected %s after %d attempts, got %s", want, attempts, got)
+		}
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := memory.NewInMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// получатель отвечает ошибкой на первую попытку каждой доставки
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	var received []Payload
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify("flaky", r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)) {
+			t.Error("invalid signature", r.Header.Get(HeaderSignature))
+		}
+		if r.Header.Get(HeaderEvent) != string(smodel.WebhookCommentCreated) {
+			t.Error("unexpected event", r.Header.Get(HeaderEvent))
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		delivery := r.Header.Get(HeaderDelivery)
+		attempts[delivery]++
+		if attempts[delivery] == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Error("invalid payload", err)
+		}
+		received = append(received, payload)
+	}))
+	defer receiver.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	flaky, err := store.CreateWebhook(ctx, smodel.CreateWebhook{URL: receiver.URL, Events: []smodel.WebhookEvent{smodel.WebhookCommentCreated}, Secret: "flaky"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead, err := store.CreateWebhook(ctx, smodel.CreateWebhook{URL: broken.URL, Events: []smodel.WebhookEvent{smodel.WebhookCommentCreated}, Secret: "broken"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// на другое событие
+	posts, err := store.CreateWebhook(ctx, smodel.CreateWebhook{URL: broken.URL, Events: []smodel.WebhookEvent{smodel.WebhookPostCreated}, Secret: "posts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(store, Config{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Timeout: time.Second, PollInterval: time.Millisecond, BatchSize: 1, AllowPrivateNetworks: true})
+	if err := d.Publish(ctx, smodel.WebhookCommentCreated, 0, map[string]string{"id": "Q29tbWVudDox"}); err != nil {
+		t.Fatal(err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		d.Run(runCtx)
+		close(done)
+	}()
+
+	pending := smodel.DeliveryPending
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		page, err := store.GetWebhookDeliveries(ctx, 10, 0, smodel.DeliveryFilter{Status: &pending})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.TotalCount == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("deliveries are still pending")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	check := func(webhookId uint, status smodel.DeliveryStatus, attempts, responseStatus int) {
+		t.Helper()
+		page, err := store.GetWebhookDeliveries(ctx, 10, 0, smodel.DeliveryFilter{WebhookId: &webhookId})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.TotalCount != 1 {
+			t.Fatal("expected 1 delivery, got", page.TotalCount)
+		}
+		got := page.Deliveries[0]
+		if got.Status != status || got.Attempts != attempts || got.ResponseStatus != responseStatus {
+			t.Errorf("expected %s after %d attempts with %d, got %s after %d with %d (%s)",
+				status, attempts, responseStatus, got.Status, got.Attempts, got.ResponseStatus, got.LastError)
+		}
+		if (status == smodel.DeliveryDelivered) != (got.DeliveredAt != nil) {
+			t.Error("unexpected delivered at", got.DeliveredAt)
+		}
+	}
+	check(flaky.ID, smodel.DeliveryDelivered, 2, http.StatusOK)
+	check(dead.ID, smodel.DeliveryDead, 3, http.StatusInternalServerError)
+
+	if page, err := store.GetWebhookDeliveries(ctx, 10, 0, smodel.DeliveryFilter{WebhookId: &posts.ID}); err != nil || page.TotalCount != 0 {
+		t.Error("expected no deliveries for other event, got", page, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0].Event != smodel.WebhookCommentCreated {
+		t.Fatal("expected 1 comment event, got", received)
+	}
+	if data, ok := received[0].Data.(map[string]interface{}); !ok || data["id"] != "Q29tbWVudDox" {
+		t.Error("unexpected data", received[0].Data)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		url  string
+		ok   bool
+	}{
+		{"public ip", "https://93.184.216.34/hook", true},
+		{"public ipv6", "http://[2606:2800:220:1::1]:8080/hook", true},
+		{"not http", "ftp://93.184.216.34/hook", false},
+		{"no host", "http:///hook", false},
+		{"loopback", "http://127.0.0.1:8080/hook", false},
+		{"loopback ipv6", "http://[::1]/hook", false},
+		{"mapped loopback", "http://[::ffff:127.0.0.1]/hook", false},
+		{"unspecified", "http://0.0.0.0/hook", false},
+		{"private", "http://10.1.2.3/hook", false},
+		{"private 192.168", "https://192.168.0.10/hook", false},
+		{"link-local metadata", "http://169.254.169.254/latest/meta-data", false},
+		{"shared address space", "http://100.64.0.1/hook", false},
+		{"unique local ipv6", "http://[fd00::1]/hook", false},
+		{"resolves to loopback", "http://localhost:8080/hook", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckURL(ctx, tt.url)
+			if tt.ok && err != nil {
+				t.Error("expected allowed url, got", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("expected rejected url")
+			}
+		})
+	}
+
+	d := NewDispatcher(nil, Config{AllowPrivateNetworks: true})
+	if err := d.CheckURL(ctx, "http://127.0.0.1:8080/hook"); err != nil {
+		t.Error("expected private url to be allowed, got", err)
+	}
+	if err := d.CheckURL(ctx, "ftp://127.0.0.1/hook"); err == nil {
+		t.Error("expected invalid scheme to be rejected")
+	}
+}
+
+func TestDispatcherPrivateAddress(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := memory.NewInMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// адрес прошёл бы проверку при создании, если бы хост тогда вёл наружу
+	var requests int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer receiver.Close()
+
+	hook, err := store.CreateWebhook(ctx, smodel.CreateWebhook{URL: receiver.URL, Events: []smodel.WebhookEvent{smodel.WebhookPostCreated}, Secret: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(store, Config{MaxAttempts: 1, Timeout: time.Second, BatchSize: 10})
+	if err := d.Publish(ctx, smodel.WebhookPostCreated, 0, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := store.GetWebhookDeliveries(ctx, 10, 0, smodel.DeliveryFilter{WebhookId: &hook.ID})
+	if err != nil || page.TotalCount != 1 {
+		t.Fatal("expected 1 delivery, got", page, err)
+	}
+	if got := page.Deliveries[0]; got.Status != smodel.DeliveryDead || !strings.Contains(got.LastError, "private network") {
+		t.Error("expected delivery to private address to fail, got", got.Status, got.LastError)
+	}
+	if requests != 0 {
+		t.Error("expected no requests to private address, got", requests)
+	}
+}